@@ -33,6 +33,16 @@ const (
 	INVALID_PROTOCOL               = 9
 	UNSUPPORTED_CLIENT_TYPE        = 10
 	METHOD_NOT_IMPLEMENTED         = 12
+
+	// The types below are specific to this runtime. They are raised by the
+	// server middlewares, limit and auth, for any function of any service:
+	// as the functions cannot be expected to declare them in their throws
+	// clause, they travel as TApplicationExceptions. Other runtimes decode
+	// them as TApplicationExceptions of an unknown type, carrying the same
+	// message.
+	OVERLOADED        = 13
+	UNAUTHENTICATED   = 14
+	PERMISSION_DENIED = 15
 )
 
 var defaultApplicationExceptionMessage = map[int32]string{
@@ -48,6 +58,9 @@ var defaultApplicationExceptionMessage = map[int32]string{
 	INVALID_PROTOCOL:               "Invalid protocol",
 	UNSUPPORTED_CLIENT_TYPE:        "Unsupported client type",
 	METHOD_NOT_IMPLEMENTED:         "method not implemented",
+	OVERLOADED:                     "overloaded",
+	UNAUTHENTICATED:                "unauthenticated",
+	PERMISSION_DENIED:              "permission denied",
 }
 
 // Application level Thrift exception
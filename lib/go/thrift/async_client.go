@@ -0,0 +1,171 @@
+package thrift
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TFuture is the pending outcome of a call issued by a TAsyncClient.
+type TFuture struct {
+	done chan struct{}
+
+	once sync.Once
+	res  TResponse
+	err  error
+}
+
+func newTFuture() *TFuture {
+	return &TFuture{done: make(chan struct{})}
+}
+
+func (f *TFuture) resolve(res TResponse, err error) {
+	f.once.Do(func() {
+		f.res = res
+		f.err = err
+		close(f.done)
+	})
+}
+
+// Done is closed once the future is resolved.
+func (f *TFuture) Done() <-chan struct{} { return f.done }
+
+// Result blocks until the future is resolved.
+func (f *TFuture) Result() (TResponse, error) {
+	<-f.done
+
+	return f.res, f.err
+}
+
+// Wait blocks until the future is resolved or ctx is done, the call itself
+// is left untouched in the latter case.
+func (f *TFuture) Wait(ctx Context) (TResponse, error) {
+	select {
+	case <-f.done:
+		return f.res, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WaitAll waits for every future to be resolved and returns the first error
+// among them, or the error of ctx if it is done first.
+func WaitAll(ctx Context, fs ...*TFuture) error {
+	var err error
+
+	for _, f := range fs {
+		_, ferr := f.Wait(ctx)
+
+		if ferr == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			err = ferr
+		}
+	}
+
+	return err
+}
+
+// WaitAny waits for the first future to be resolved and returns its index
+// along with its error. It returns -1 and the error of ctx if ctx is done
+// first.
+func WaitAny(ctx Context, fs ...*TFuture) (int, error) {
+	cases := make([]reflect.SelectCase, len(fs)+1)
+
+	for i, f := range fs {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.done)}
+	}
+
+	cases[len(fs)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
+
+	i, _, _ := reflect.Select(cases)
+
+	if i == len(fs) {
+		return -1, ctx.Err()
+	}
+
+	return i, fs[i].err
+}
+
+// TAsyncClient issues the calls of a TClient in their own goroutine and hands
+// back a TFuture. The calls only run concurrently when the underlying client
+// does, as TPipelinedClient, TPoolClient or TBalancedClient do.
+//
+// When ctx is done before the reply came back, the future resolves with the
+// error of ctx right away and the late reply is dropped: a response given to
+// CallBinary as a pointer is never written after that.
+type TAsyncClient struct {
+	client TClient
+}
+
+func NewTAsyncClient(c TClient) *TAsyncClient {
+	return &TAsyncClient{client: c}
+}
+
+func (c *TAsyncClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) *TFuture {
+	var (
+		f  = newTFuture()
+		rv = reflect.ValueOf(res)
+
+		// The reply is decoded in a private response, copied to res only if
+		// the caller did not give up on the call meanwhile.
+		pres = res
+		mu   sync.Mutex
+	)
+
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		pres = reflect.New(rv.Elem().Type()).Interface().(TResponse)
+	}
+
+	go func() {
+		err := c.client.CallBinary(ctx, method, req, pres)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		select {
+		case <-f.done:
+			return
+		default:
+		}
+
+		if err == nil && pres != res {
+			rv.Elem().Set(reflect.ValueOf(pres).Elem())
+		}
+
+		f.resolve(res, err)
+	}()
+
+	go func() {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			mu.Lock()
+			f.resolve(nil, ctx.Err())
+			mu.Unlock()
+		}
+	}()
+
+	return f
+}
+
+func (c *TAsyncClient) CallUnary(ctx Context, method string, req TRequest) *TFuture {
+	f := newTFuture()
+
+	go func() { f.resolve(nil, c.client.CallUnary(ctx, method, req)) }()
+
+	go func() {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			f.resolve(nil, ctx.Err())
+		}
+	}()
+
+	return f
+}
@@ -0,0 +1,147 @@
+package thrift
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// delayedClient answers every call with its payload after the delay named by
+// the method.
+type delayedClient struct{}
+
+func (delayedClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	d, err := time.ParseDuration(method)
+
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(d)
+	*res.(*tpayload) = *req.(*tpayload)
+
+	return nil
+}
+
+func (delayedClient) CallUnary(_ Context, method string, _ TRequest) error {
+	d, err := time.ParseDuration(method)
+
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(d)
+
+	return nil
+}
+
+func TestTAsyncClient_WaitAll(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cl  = NewTAsyncClient(delayedClient{})
+
+		res = make([]tpayload, 3)
+		fs  = make([]*TFuture, 3)
+	)
+
+	for i, v := range []string{"foo", "bar", "biz"} {
+		fs[i] = cl.CallBinary(ctx, "1ms", newTPayload(v), &res[i])
+	}
+
+	require.NoError(t, WaitAll(ctx, fs...))
+	assert.Equal(t, []tpayload{{v: "foo"}, {v: "bar"}, {v: "biz"}}, res)
+
+	r, err := fs[1].Result()
+	assert.NoError(t, err)
+	assert.Equal(t, &res[1], r)
+}
+
+func TestTAsyncClient_WaitAllError(t *testing.T) {
+	var (
+		ctx = context.Background()
+		cl  = NewTAsyncClient(delayedClient{})
+		res = make([]tpayload, 2)
+	)
+
+	err := WaitAll(
+		ctx,
+		cl.CallBinary(ctx, "1ms", newTPayload("foo"), &res[0]),
+		cl.CallBinary(ctx, "invalid", newTPayload("bar"), &res[1]),
+	)
+
+	assert.Error(t, err)
+}
+
+func TestTAsyncClient_WaitAny(t *testing.T) {
+	var (
+		ctx      = context.Background()
+		cl       = NewTAsyncClient(delayedClient{})
+		slow     tpayload
+		fast     tpayload
+		cctx, cf = context.WithCancel(ctx)
+	)
+
+	defer cf()
+
+	i, err := WaitAny(
+		ctx,
+		cl.CallBinary(cctx, "50ms", newTPayload("slow"), &slow),
+		cl.CallBinary(ctx, "1ms", newTPayload("fast"), &fast),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, i)
+	assert.Equal(t, "fast", fast.v)
+}
+
+// TestTAsyncClient_Cancellation verifies that a cancelled call resolves
+// right away and that its late reply is dropped.
+func TestTAsyncClient_Cancellation(t *testing.T) {
+	var (
+		cl          = NewTAsyncClient(delayedClient{})
+		ctx, cancel = context.WithCancel(context.Background())
+		res         tpayload
+	)
+
+	f := cl.CallBinary(ctx, "20ms", newTPayload("late"), &res)
+	cancel()
+
+	_, err := f.Result()
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, "", res.v)
+}
+
+func TestTAsyncClient_UnaryCancellation(t *testing.T) {
+	var (
+		cl          = NewTAsyncClient(delayedClient{})
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+
+	f := cl.CallUnary(ctx, "1s", newTPayload("late"))
+	cancel()
+
+	select {
+	case <-f.Done():
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("future not resolved")
+	}
+
+	_, err := f.Result()
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestWaitAny_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	i, err := WaitAny(ctx, newTFuture())
+
+	assert.Equal(t, -1, i)
+	assert.Equal(t, context.Canceled, err)
+}
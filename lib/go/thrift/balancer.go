@@ -0,0 +1,410 @@
+package thrift
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/upfluence/errors"
+)
+
+const (
+	DefaultBalancerResolveInterval   = 30 * time.Second
+	DefaultBalancerEjectionThreshold = 5
+	DefaultBalancerEjectionDuration  = 30 * time.Second
+)
+
+var (
+	ErrNoEndpoint = errors.New("thrift: no endpoint available")
+
+	errEndpointClosed = NewTTransportException(NOT_OPEN, "endpoint closed")
+)
+
+// TEndpoint is an address known by a TBalancedClient along with the client
+// used to reach it, dialed on first use.
+type TEndpoint struct {
+	addr    string
+	pending int64
+
+	mu           sync.Mutex
+	client       TClient
+	dialing      *endpointDial
+	failures     int
+	ejectedUntil time.Time
+	removed      bool
+}
+
+func (e *TEndpoint) Addr() string { return e.addr }
+
+// Pending returns the number of calls currently in flight to the endpoint.
+func (e *TEndpoint) Pending() int { return int(atomic.LoadInt64(&e.pending)) }
+
+// endpointDial is a dial in progress, the calls needing the client of the
+// endpoint meanwhile wait for its outcome.
+type endpointDial struct {
+	done   chan struct{}
+	client TClient
+	err    error
+}
+
+// getClient returns the client of the endpoint, dialing it if needed. The
+// dial happens outside of the lock of the endpoint for a slow dial not to
+// hold up the calls to the other endpoints.
+func (e *TEndpoint) getClient(ctx Context, dial func(string) (TClient, error)) (TClient, error) {
+	e.mu.Lock()
+
+	if cl := e.client; cl != nil {
+		e.mu.Unlock()
+		return cl, nil
+	}
+
+	if d := e.dialing; d != nil {
+		e.mu.Unlock()
+
+		select {
+		case <-d.done:
+			return d.client, d.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	d := &endpointDial{done: make(chan struct{})}
+	e.dialing = d
+	e.mu.Unlock()
+
+	cl, err := dial(e.addr)
+
+	e.mu.Lock()
+
+	switch {
+	case err != nil:
+		d.err = err
+	case e.dialing != d:
+		// The endpoint was closed while dialing.
+		closeValue(cl)
+		d.err = errEndpointClosed
+	default:
+		d.client = cl
+		e.client = cl
+	}
+
+	e.dialing = nil
+	e.mu.Unlock()
+
+	close(d.done)
+
+	return d.client, d.err
+}
+
+// report updates the consecutive failures counter of the endpoint, the
+// endpoint gets ejected once it reaches threshold. The client cl the call
+// was made with is closed on a broken connection, for the next call to dial
+// a new one: a client may not tell it is broken, like a TPipelinedClient,
+// or still look open, like a TSyncClient out of sync.
+func (e *TEndpoint) report(cl TClient, err error, threshold int, d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err == nil || !isBrokenConnection(err) {
+		e.failures = 0
+		return
+	}
+
+	if cl != nil && e.client == cl {
+		closeValue(e.client)
+		e.client = nil
+	}
+
+	e.failures++
+
+	if e.failures >= threshold {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(d)
+	}
+}
+
+func (e *TEndpoint) ejected(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return now.Before(e.ejectedUntil)
+}
+
+func (e *TEndpoint) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client != nil {
+		closeValue(e.client)
+		e.client = nil
+	}
+
+	e.dialing = nil
+}
+
+// TPicker selects the endpoint a call is sent to among the ones that are not
+// ejected. It is never called with an empty list.
+type TPicker interface {
+	Pick([]*TEndpoint) *TEndpoint
+}
+
+type roundRobinPicker struct {
+	n uint64
+}
+
+func NewTRoundRobinPicker() TPicker {
+	return &roundRobinPicker{}
+}
+
+func (p *roundRobinPicker) Pick(es []*TEndpoint) *TEndpoint {
+	return es[(atomic.AddUint64(&p.n, 1)-1)%uint64(len(es))]
+}
+
+type leastPendingPicker struct{}
+
+func NewTLeastPendingPicker() TPicker {
+	return leastPendingPicker{}
+}
+
+func (leastPendingPicker) Pick(es []*TEndpoint) *TEndpoint {
+	var (
+		offset = rand.Intn(len(es))
+		res    = es[offset]
+	)
+
+	for i := 1; i < len(es); i++ {
+		if e := es[(offset+i)%len(es)]; e.Pending() < res.Pending() {
+			res = e
+		}
+	}
+
+	return res
+}
+
+type p2cPicker struct{}
+
+// NewTP2CPicker returns a picker drawing two endpoints at random and keeping
+// the one with the fewest pending calls.
+func NewTP2CPicker() TPicker {
+	return p2cPicker{}
+}
+
+func (p2cPicker) Pick(es []*TEndpoint) *TEndpoint {
+	if len(es) == 1 {
+		return es[0]
+	}
+
+	i := rand.Intn(len(es))
+	j := rand.Intn(len(es) - 1)
+
+	if j >= i {
+		j++
+	}
+
+	if es[j].Pending() < es[i].Pending() {
+		return es[j]
+	}
+
+	return es[i]
+}
+
+type TBalancedClientOptions struct {
+	Resolver TResolver
+	// Dialer builds the client used to reach an endpoint, for instance a
+	// TPoolClient or a TPipelinedClient.
+	Dialer func(addr string) (TClient, error)
+	// Defaults to a round-robin picker.
+	Picker TPicker
+
+	ResolveInterval time.Duration
+
+	// An endpoint is ejected for EjectionDuration after EjectionThreshold
+	// consecutive transport errors.
+	EjectionThreshold int
+	EjectionDuration  time.Duration
+}
+
+// TBalancedClient is a TClient spreading its calls over the endpoints
+// returned by a TResolver, which is queried again every ResolveInterval.
+// When every endpoint is ejected, calls are spread over all of them.
+type TBalancedClient struct {
+	opts TBalancedClientOptions
+
+	mu        sync.RWMutex
+	endpoints []*TEndpoint
+
+	closeOnce sync.Once
+	closec    chan struct{}
+}
+
+func NewTBalancedClient(opts TBalancedClientOptions) (*TBalancedClient, error) {
+	if opts.Picker == nil {
+		opts.Picker = NewTRoundRobinPicker()
+	}
+
+	if opts.ResolveInterval == 0 {
+		opts.ResolveInterval = DefaultBalancerResolveInterval
+	}
+
+	if opts.EjectionThreshold == 0 {
+		opts.EjectionThreshold = DefaultBalancerEjectionThreshold
+	}
+
+	if opts.EjectionDuration == 0 {
+		opts.EjectionDuration = DefaultBalancerEjectionDuration
+	}
+
+	c := &TBalancedClient{opts: opts, closec: make(chan struct{})}
+
+	if err := c.resolve(defaultCtx); err != nil {
+		return nil, err
+	}
+
+	go c.resolveLoop()
+
+	return c, nil
+}
+
+func (c *TBalancedClient) resolveLoop() {
+	t := time.NewTicker(c.opts.ResolveInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.closec:
+			return
+		case <-t.C:
+			// On failure, keep on using the last known endpoints.
+			c.resolve(defaultCtx)
+		}
+	}
+}
+
+func (c *TBalancedClient) resolve(ctx Context) error {
+	addrs, err := c.opts.Resolver.Resolve(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+
+	var (
+		current   = make(map[string]*TEndpoint, len(c.endpoints))
+		endpoints = make([]*TEndpoint, 0, len(addrs))
+	)
+
+	for _, e := range c.endpoints {
+		current[e.addr] = e
+	}
+
+	for _, addr := range addrs {
+		if e, ok := current[addr]; ok {
+			endpoints = append(endpoints, e)
+			delete(current, addr)
+		} else {
+			endpoints = append(endpoints, &TEndpoint{addr: addr})
+		}
+	}
+
+	c.endpoints = endpoints
+	c.mu.Unlock()
+
+	for _, e := range current {
+		e.mu.Lock()
+		e.removed = true
+		e.mu.Unlock()
+
+		if e.Pending() == 0 {
+			e.close()
+		}
+	}
+
+	return nil
+}
+
+// Endpoints returns the endpoints currently known by the client.
+func (c *TBalancedClient) Endpoints() []*TEndpoint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]*TEndpoint(nil), c.endpoints...)
+}
+
+func (c *TBalancedClient) pick() (*TEndpoint, error) {
+	var (
+		all       = c.Endpoints()
+		available = make([]*TEndpoint, 0, len(all))
+		now       = time.Now()
+	)
+
+	if len(all) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	for _, e := range all {
+		if !e.ejected(now) {
+			available = append(available, e)
+		}
+	}
+
+	if len(available) == 0 {
+		available = all
+	}
+
+	return c.opts.Picker.Pick(available), nil
+}
+
+func (c *TBalancedClient) do(ctx Context, fn func(TClient) error) error {
+	e, err := c.pick()
+
+	if err != nil {
+		return err
+	}
+
+	atomic.AddInt64(&e.pending, 1)
+
+	cl, err := e.getClient(ctx, c.opts.Dialer)
+
+	switch {
+	case err == nil:
+		err = fn(cl)
+	case err != ctx.Err():
+		err = NewTTransportExceptionFromError(err)
+	}
+
+	e.report(cl, err, c.opts.EjectionThreshold, c.opts.EjectionDuration)
+
+	if atomic.AddInt64(&e.pending, -1) == 0 {
+		e.mu.Lock()
+		removed := e.removed
+		e.mu.Unlock()
+
+		if removed {
+			e.close()
+		}
+	}
+
+	return err
+}
+
+func (c *TBalancedClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	return c.do(ctx, func(cl TClient) error { return cl.CallBinary(ctx, method, req, res) })
+}
+
+func (c *TBalancedClient) CallUnary(ctx Context, method string, req TRequest) error {
+	return c.do(ctx, func(cl TClient) error { return cl.CallUnary(ctx, method, req) })
+}
+
+// Close stops the resolution and closes the clients of every endpoint.
+func (c *TBalancedClient) Close() error {
+	c.closeOnce.Do(func() { close(c.closec) })
+
+	for _, e := range c.Endpoints() {
+		e.close()
+	}
+
+	return nil
+}
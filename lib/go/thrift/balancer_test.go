@@ -0,0 +1,315 @@
+package thrift
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type balancerTestClient struct {
+	addr string
+
+	mu     sync.Mutex
+	calls  int
+	err    error
+	closed bool
+}
+
+func (c *balancerTestClient) CallBinary(Context, string, TRequest, TResponse) error {
+	return c.CallUnary(nil, "", nil)
+}
+
+func (c *balancerTestClient) CallUnary(Context, string, TRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls++
+
+	return c.err
+}
+
+func (c *balancerTestClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	return nil
+}
+
+func (c *balancerTestClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+type balancerTestDialer struct {
+	mu      sync.Mutex
+	clients map[string]*balancerTestClient
+}
+
+func (d *balancerTestDialer) dial(addr string) (TClient, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.clients == nil {
+		d.clients = make(map[string]*balancerTestClient)
+	}
+
+	c := &balancerTestClient{addr: addr}
+	d.clients[addr] = c
+
+	return c, nil
+}
+
+func (d *balancerTestDialer) client(addr string) *balancerTestClient {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.clients[addr]
+}
+
+type mutableResolver struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (r *mutableResolver) set(addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addrs = addrs
+}
+
+func (r *mutableResolver) Resolve(Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.addrs, nil
+}
+
+// TestTBalancedClient_RoundRobin verifies that calls are spread evenly over
+// the resolved endpoints.
+func TestTBalancedClient_RoundRobin(t *testing.T) {
+	var d balancerTestDialer
+
+	cl, err := NewTBalancedClient(
+		TBalancedClientOptions{
+			Resolver: NewTStaticResolver("a:1", "b:1", "c:1"),
+			Dialer:   d.dial,
+		},
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	for i := 0; i < 9; i++ {
+		require.NoError(t, cl.CallUnary(context.Background(), "foo", nil))
+	}
+
+	for _, addr := range []string{"a:1", "b:1", "c:1"} {
+		assert.Equal(t, 3, d.client(addr).calls)
+	}
+}
+
+// TestTBalancedClient_Ejection verifies that an endpoint returning transport
+// errors is ejected for the configured duration.
+func TestTBalancedClient_Ejection(t *testing.T) {
+	var d balancerTestDialer
+
+	cl, err := NewTBalancedClient(
+		TBalancedClientOptions{
+			Resolver:          NewTStaticResolver("a:1", "b:1"),
+			Dialer:            d.dial,
+			EjectionThreshold: 1,
+			EjectionDuration:  time.Hour,
+		},
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	ctx := context.Background()
+
+	require.NoError(t, cl.CallUnary(ctx, "foo", nil))
+	require.NoError(t, cl.CallUnary(ctx, "foo", nil))
+
+	d.client("a:1").err = NewTTransportException(END_OF_FILE, "EOF")
+
+	for i := 0; i < 3; i++ {
+		cl.CallUnary(ctx, "foo", nil)
+	}
+
+	assert.Equal(t, 2, d.client("a:1").calls)
+	assert.Equal(t, 3, d.client("b:1").calls)
+}
+
+// TestTBalancedClient_Resolution verifies that endpoints are resolved again
+// over time and that removed endpoints get closed.
+func TestTBalancedClient_Resolution(t *testing.T) {
+	var (
+		d balancerTestDialer
+		r mutableResolver
+	)
+
+	r.set("a:1")
+
+	cl, err := NewTBalancedClient(
+		TBalancedClientOptions{
+			Resolver:        &r,
+			Dialer:          d.dial,
+			ResolveInterval: 5 * time.Millisecond,
+		},
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	require.NoError(t, cl.CallUnary(context.Background(), "foo", nil))
+
+	r.set("b:1")
+
+	assert.Eventually(
+		t,
+		func() bool {
+			es := cl.Endpoints()
+			return len(es) == 1 && es[0].Addr() == "b:1"
+		},
+		time.Second,
+		5*time.Millisecond,
+	)
+
+	assert.Eventually(t, d.client("a:1").isClosed, time.Second, 5*time.Millisecond)
+}
+
+// TestTBalancedClient_Redial verifies that a client whose connection broke is
+// dialed again, even though it cannot tell it is broken.
+func TestTBalancedClient_Redial(t *testing.T) {
+	var (
+		s = newTestServer(t, peerHandler{}, &tickerHandler{})
+
+		mu    sync.Mutex
+		socks []*TSocket
+	)
+
+	defer s.Stop()
+
+	cl, err := NewTBalancedClient(
+		TBalancedClientOptions{
+			Resolver: NewTStaticResolver("test:1"),
+			Dialer: func(string) (TClient, error) {
+				sock := openTestSocket(t, s)
+
+				mu.Lock()
+				socks = append(socks, sock)
+				mu.Unlock()
+
+				return NewTPipelinedClient(sock, NewTBinaryProtocolFactoryDefault()), nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	var (
+		ctx = context.Background()
+		res tstring
+	)
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString(""), &res))
+	first := res
+
+	socks[0].Close()
+
+	assert.Error(t, cl.CallBinary(ctx, "echo", newTString(""), &res))
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString(""), &res))
+	assert.NotEqual(t, first, res)
+	assert.Len(t, socks, 2)
+}
+
+// TestTBalancedClient_SlowDial verifies that a dial in progress does not hold
+// up the calls to the other endpoints.
+func TestTBalancedClient_SlowDial(t *testing.T) {
+	var (
+		d       balancerTestDialer
+		release = make(chan struct{})
+	)
+
+	cl, err := NewTBalancedClient(
+		TBalancedClientOptions{
+			Resolver: NewTStaticResolver("a:1", "b:1"),
+			Dialer: func(addr string) (TClient, error) {
+				if addr == "a:1" {
+					<-release
+				}
+
+				return d.dial(addr)
+			},
+		},
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	errc := make(chan error, 2)
+
+	for i := 0; i < 2; i++ {
+		go func() { errc <- cl.CallUnary(context.Background(), "foo", nil) }()
+	}
+
+	require.Eventually(
+		t,
+		func() bool { return d.client("b:1") != nil },
+		time.Second,
+		5*time.Millisecond,
+	)
+
+	// The second call to a:1 waits for the dial of the first one.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, cl.CallUnary(ctx, "foo", nil))
+
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-errc)
+	}
+
+	assert.Equal(t, 1, d.client("a:1").calls)
+}
+
+// TestTP2CPicker verifies that the picker keeps the least loaded of the two
+// endpoints it draws.
+func TestTP2CPicker(t *testing.T) {
+	es := []*TEndpoint{{addr: "a:1", pending: 10}, {addr: "b:1"}}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b:1", NewTP2CPicker().Pick(es).Addr())
+		assert.Equal(t, "b:1", NewTLeastPendingPicker().Pick(es).Addr())
+	}
+}
+
+// TestTFileResolver verifies that the file is parsed again once modified.
+func TestTFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints")
+
+	require.NoError(t, os.WriteFile(path, []byte("# comment\na:1\n\nb:1\n"), 0644))
+
+	r := NewTFileResolver(path)
+
+	addrs, err := r.Resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a:1", "b:1"}, addrs)
+
+	require.NoError(t, os.WriteFile(path, []byte("c:1\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+
+	addrs, err = r.Resolve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c:1"}, addrs)
+}
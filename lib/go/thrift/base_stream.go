@@ -17,6 +17,10 @@ type tBaseStream struct {
 	goAwayOnce sync.Once
 	closerFunc func()
 
+	// sendMu serializes the writes, goneAway is set once the go away sent.
+	sendMu   sync.Mutex
+	goneAway bool
+
 	name  string
 	seqID int32
 
@@ -40,8 +44,8 @@ func newTServerBaseStream(name string, seqID int32, in, out TProtocol, goAwayTyp
 	}
 }
 
-func newTClientBaseStream(name string, seqID int32, in, out TProtocol, goAwayType TMessageType, cl *TSyncClient) tBaseStream {
-	var unlockOnce sync.Once
+func newTClientBaseStream(name string, seqID int32, in, out TProtocol, goAwayType TMessageType, release func()) tBaseStream {
+	var releaseOnce sync.Once
 
 	return tBaseStream{
 		name:          name,
@@ -51,7 +55,7 @@ func newTClientBaseStream(name string, seqID int32, in, out TProtocol, goAwayTyp
 		out:           out,
 		seqID:         seqID,
 		closec:        make(chan struct{}),
-		closerFunc:    func() { unlockOnce.Do(func() { cl.mu.Unlock() }) },
+		closerFunc:    func() { releaseOnce.Do(release) },
 		readyc:        make(chan struct{}),
 	}
 }
@@ -77,6 +81,19 @@ func (bs *tBaseStream) write(ctx Context, typeID TMessageType, req TStruct) erro
 	default:
 	}
 
+	bs.sendMu.Lock()
+	defer bs.sendMu.Unlock()
+
+	if bs.goneAway {
+		switch {
+		case typeID == bs.goAwayType:
+			return nil
+		case req != nil:
+			// The peer stops reading the messages once told to go away.
+			return io.EOF
+		}
+	}
+
 	if err := bs.out.Transport().WriteContext(ctx); err != nil {
 		return err
 	}
@@ -86,6 +103,10 @@ func (bs *tBaseStream) write(ctx Context, typeID TMessageType, req TStruct) erro
 		return parseStreamingError(err)
 	}
 
+	if bs.goAwayType != INVALID_TMESSAGE_TYPE && typeID == bs.goAwayType {
+		bs.goneAway = true
+	}
+
 	return nil
 }
 
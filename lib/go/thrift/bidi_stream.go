@@ -31,9 +31,9 @@ type tBidiStream struct {
 	writeMu sync.Mutex
 }
 
-func newTClientBidiStream(name string, seqID int32, in, out TProtocol, cl *TSyncClient) *tBidiStream {
+func newTClientBidiStream(name string, seqID int32, in, out TProtocol, release func()) *tBidiStream {
 	return &tBidiStream{
-		tBaseStream:           newTClientBaseStream(name, seqID, in, out, 0, cl),
+		tBaseStream:           newTClientBaseStream(name, seqID, in, out, 0, release),
 		outboundClosec:        make(chan struct{}),
 		inboundClosec:         make(chan struct{}),
 		inboundMessageType:    SERVER_STREAM_MESSAGE,
@@ -66,6 +66,11 @@ func (bs *tBidiStream) Close() error {
 	return nil
 }
 
+func (bs *tBidiStream) goAway() {
+	go (&tOutboundBidiStream{tBidiStream: bs}).Close()
+	(&tInboundBidiStream{tBidiStream: bs}).Close()
+}
+
 func (bs *tBidiStream) writeShell(mt TMessageType) error {
 	bs.writeMu.Lock()
 	defer bs.writeMu.Unlock()
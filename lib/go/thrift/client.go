@@ -1,7 +1,9 @@
 package thrift
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"sync"
 )
 
@@ -53,6 +55,8 @@ func NewTSyncClient(t TTransport, f TProtocolFactory, ms ...TMiddleware) *TSyncC
 }
 
 func send(ctx Context, oprot TProtocol, seqID int32, method string, args TRequest, mType TMessageType) error {
+	writeTHeaders(ctx, oprot)
+
 	if err := oprot.WriteMessageBegin(method, mType, seqID); err != nil {
 		return err
 	}
@@ -74,23 +78,33 @@ func send(ctx Context, oprot TProtocol, seqID int32, method string, args TReques
 	return oprot.Flush()
 }
 
-func recv(iprot TProtocol, seqID int32, method string, result TResponse) error {
+func recv(ctx Context, iprot TProtocol, seqID int32, method string, result TResponse) error {
 	var rMethod, rTypeID, rSeqID, err = iprot.ReadMessageBegin()
 
 	if err != nil {
 		return err
 	}
 
+	if h, ok := GetResponseHeaders(ctx); ok {
+		readResponseTHeaders(h, iprot)
+	}
+
+	if method == rMethod && seqID != rSeqID {
+		return NewTApplicationException(
+			BAD_SEQUENCE_ID,
+			fmt.Sprintf("%s: out of order sequence response", method),
+		)
+	}
+
+	return readReply(iprot, method, rMethod, rTypeID, result)
+}
+
+func readReply(iprot TProtocol, method, rMethod string, rTypeID TMessageType, result TResponse) error {
 	if method != rMethod {
 		return NewTApplicationException(
 			WRONG_METHOD_NAME,
 			fmt.Sprintf("%s: wrong method name", method),
 		)
-	} else if seqID != rSeqID {
-		return NewTApplicationException(
-			BAD_SEQUENCE_ID,
-			fmt.Sprintf("%s: out of order sequence response", method),
-		)
 	} else if rTypeID == EXCEPTION {
 		exception := &tApplicationException{}
 
@@ -117,16 +131,49 @@ func recv(iprot TProtocol, seqID int32, method string, result TResponse) error {
 	return iprot.ReadMessageEnd()
 }
 
+// copyResponse copies into res the response r a middleware answered a call
+// with, without calling the next handler of the chain, like a cached one.
+func copyResponse(res, r TResponse) error {
+	if r == nil || res == nil {
+		return nil
+	}
+
+	dv, sv := reflect.ValueOf(res), reflect.ValueOf(r)
+
+	if dv.Kind() == reflect.Ptr && dv.Type() == sv.Type() {
+		if dv.IsNil() || sv.IsNil() || dv.Pointer() == sv.Pointer() {
+			return nil
+		}
+
+		dv.Elem().Set(sv.Elem())
+
+		return nil
+	}
+
+	buf, err := NewTSerializer().Write(context.Background(), r)
+
+	if err != nil {
+		return err
+	}
+
+	return NewTDeserializer().Read(res, buf)
+}
+
+func (c *TSyncClient) IsOpen() bool { return c.trans.IsOpen() }
+func (c *TSyncClient) Close() error { return c.trans.Close() }
+
 func (c *TSyncClient) InProtocol() TProtocol  { return c.in }
 func (c *TSyncClient) OutProtocol() TProtocol { return c.out }
 
 func (c *TSyncClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.seqID++
 
-	_, err := c.middleware.HandleBinaryRequest(
+	r, err := c.middleware.HandleBinaryRequest(
 		ctx,
 		method,
 		c.seqID,
@@ -136,14 +183,20 @@ func (c *TSyncClient) CallBinary(ctx Context, method string, req TRequest, res T
 				return nil, err
 			}
 
-			return res, recv(c.out, c.seqID, method, res)
+			return res, recv(ctx, c.out, c.seqID, method, res)
 		},
 	)
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	return copyResponse(res, r)
 }
 
 func (c *TSyncClient) CallUnary(ctx Context, method string, req TRequest) error {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -162,26 +215,20 @@ func (c *TSyncClient) CallUnary(ctx Context, method string, req TRequest) error
 
 func (c *TSyncClient) rpcStream(ctx Context, method string, req TRequest, res TResponse) error {
 	if err := send(ctx, c.in, c.seqID, method, req, CALL); err != nil {
-		c.mu.Unlock()
-
 		return err
 	}
 
-	if err := recv(c.out, c.seqID, method, res); err != nil {
-		c.mu.Unlock()
-
-		return err
-	}
-
-	return nil
+	return recv(ctx, c.out, c.seqID, method, res)
 }
 
 func (c *TSyncClient) StreamClient(ctx Context, method string, req TRequest, res TResponse) (TOutboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
 	c.mu.Lock()
 	c.seqID++
 
 	var (
-		originalStream = newTClientOutboundStream(method, c.seqID, c.in, c.out, c)
+		originalStream = newTClientOutboundStream(method, c.seqID, c.in, c.out, c.mu.Unlock)
 
 		wrappedStream TOutboundStream = originalStream
 	)
@@ -199,17 +246,25 @@ func (c *TSyncClient) StreamClient(ctx Context, method string, req TRequest, res
 		},
 	)
 
-	originalStream.ready()
+	if err != nil {
+		// The call failed, the stream never started: it is closed without
+		// the GOAWAY exchange, which releases the client.
+		originalStream.close()
+	} else {
+		originalStream.ready()
+	}
 
 	return wrappedStream, err
 }
 
 func (c *TSyncClient) StreamServer(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
 	c.mu.Lock()
 	c.seqID++
 
 	var (
-		originalStream = newTClientInboundStream(method, c.seqID, c.in, c.out, c)
+		originalStream = newTClientInboundStream(method, c.seqID, c.in, c.out, c.mu.Unlock)
 
 		wrappedStream TInboundStream = originalStream
 	)
@@ -227,17 +282,25 @@ func (c *TSyncClient) StreamServer(ctx Context, method string, req TRequest, res
 		},
 	)
 
-	originalStream.ready()
+	if err != nil {
+		// The call failed, the stream never started: it is closed without
+		// the GOAWAY exchange, which releases the client.
+		originalStream.close()
+	} else {
+		originalStream.ready()
+	}
 
 	return wrappedStream, err
 }
 
 func (c *TSyncClient) StreamBidi(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, TOutboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
 	c.mu.Lock()
 	c.seqID++
 
 	var (
-		bidiStream = newTClientBidiStream(method, c.seqID, c.in, c.out, c)
+		bidiStream = newTClientBidiStream(method, c.seqID, c.in, c.out, c.mu.Unlock)
 
 		inboundStream  TInboundStream  = &tInboundBidiStream{tBidiStream: bidiStream}
 		outboundStream TOutboundStream = &tOutboundBidiStream{tBidiStream: bidiStream}
@@ -258,7 +321,13 @@ func (c *TSyncClient) StreamBidi(ctx Context, method string, req TRequest, res T
 		},
 	)
 
-	bidiStream.ready()
+	if err != nil {
+		// The call failed, the stream never started: it is closed without
+		// the GOAWAY exchange, which releases the client.
+		bidiStream.close()
+	} else {
+		bidiStream.ready()
+	}
 
 	return inboundStream, outboundStream, err
 }
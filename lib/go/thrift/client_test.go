@@ -340,3 +340,16 @@ func TestTSyncClient_ConcurrentCallsAreSerialised(t *testing.T) {
 	// must be exactly two distinct messages (no interleaving / duplication).
 	assert.ElementsMatch(t, []string{"first", "second"}, got)
 }
+
+// TestCopyResponse verifies that the response a middleware answers a call
+// with is copied into the result of the caller.
+func TestCopyResponse(t *testing.T) {
+	res := newTString("")
+
+	assert.NoError(t, copyResponse(res, newTString("foo")))
+	assert.Equal(t, "foo", res.String())
+
+	assert.NoError(t, copyResponse(res, res))
+	assert.NoError(t, copyResponse(res, nil))
+	assert.Equal(t, "foo", res.String())
+}
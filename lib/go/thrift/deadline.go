@@ -0,0 +1,72 @@
+package thrift
+
+import (
+	"context"
+	"time"
+)
+
+// THeaderDeadlineKey is the reserved THeader carrying the time left before
+// the deadline of a call, formatted by time.Duration.String. A relative value
+// keeps the propagation safe from clock skew between the peers.
+const THeaderDeadlineKey = "thrift-deadline"
+
+// writeTHeaders sets the write headers of an outgoing call when oprot speaks
+// THeader: the ones listed by GetWriteHeaderList along with the time left
+// before the deadline of ctx, if any. The headers set beforehand through
+// SetWriteHeader are kept.
+func writeTHeaders(ctx Context, oprot TProtocol) {
+	hp, ok := oprot.(*THeaderProtocol)
+
+	if !ok || ctx == nil {
+		return
+	}
+
+	for _, k := range GetWriteHeaderList(ctx) {
+		if v, ok := GetHeader(ctx, k); ok {
+			hp.SetWriteHeader(k, v)
+		}
+	}
+
+	if d, ok := ctx.Deadline(); ok {
+		left := time.Until(d)
+
+		if left < 0 {
+			left = 0
+		}
+
+		// Overrides a forwarded value, the budget left is always the one of
+		// the current context.
+		hp.SetWriteHeader(THeaderDeadlineKey, left.String())
+	} else {
+		// Drops the value left by a previous call on the same protocol.
+		hp.RemoveWriteHeader(THeaderDeadlineKey)
+	}
+}
+
+// withTHeaderDeadline applies the deadline propagated by the client, if any,
+// to the context of a request built by AddReadTHeaderToContext.
+func withTHeaderDeadline(ctx Context) (Context, context.CancelFunc) {
+	v, ok := GetHeader(ctx, THeaderDeadlineKey)
+
+	if !ok {
+		return ctx, func() {}
+	}
+
+	d, err := time.ParseDuration(v)
+
+	if err != nil {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, d)
+}
+
+// deadlineExceeded returns the error of ctx when its deadline passed, the
+// handler is then not worth calling.
+func deadlineExceeded(ctx Context) error {
+	if ctx != nil && ctx.Err() == context.DeadlineExceeded {
+		return ctx.Err()
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package thrift
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeadline_Propagation verifies that the deadline of the client context
+// travels in a THeader, along with the forwarded headers, and gets applied
+// back to the server context.
+func TestDeadline_Propagation(t *testing.T) {
+	var (
+		trans  = NewTMemoryBuffer()
+		client = NewTHeaderProtocol(trans)
+		server = NewTHeaderProtocol(trans)
+	)
+
+	ctx := SetHeader(context.Background(), "foo", "bar")
+	ctx = SetHeader(ctx, THeaderDeadlineKey, "1h")
+	ctx = SetWriteHeaderList(ctx, []string{"foo", THeaderDeadlineKey})
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	require.NoError(t, send(ctx, client, 1, "echo", newTString("x"), CALL))
+	require.NoError(t, server.ReadFrame())
+
+	headers := server.GetReadHeaders()
+	assert.Equal(t, "bar", headers["foo"])
+
+	sctx, scancel := withTHeaderDeadline(AddReadTHeaderToContext(context.Background(), headers))
+	defer scancel()
+
+	d, ok := sctx.Deadline()
+	require.True(t, ok)
+
+	left := time.Until(d)
+	assert.True(t, left > 0 && left <= time.Minute, "%v", left)
+}
+
+// TestDeadline_KeepsWriteHeaders verifies that the headers set on the
+// protocol are kept while the deadline of a previous call is dropped.
+func TestDeadline_KeepsWriteHeaders(t *testing.T) {
+	var (
+		trans  = NewTMemoryBuffer()
+		client = NewTHeaderProtocol(trans)
+		server = NewTHeaderProtocol(trans)
+	)
+
+	roundTrip := func(ctx Context) THeaderMap {
+		require.NoError(t, send(ctx, client, 1, "echo", newTString("x"), CALL))
+
+		_, _, _, err := server.ReadMessageBegin()
+		require.NoError(t, err)
+		var s tstring
+		require.NoError(t, s.Read(server))
+		require.NoError(t, server.ReadMessageEnd())
+
+		return server.GetReadHeaders()
+	}
+
+	client.SetWriteHeader("foo", "bar")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	headers := roundTrip(ctx)
+	assert.Equal(t, "bar", headers["foo"])
+	assert.Contains(t, headers, THeaderDeadlineKey)
+
+	headers = roundTrip(context.Background())
+	assert.Equal(t, "bar", headers["foo"])
+	assert.NotContains(t, headers, THeaderDeadlineKey)
+}
+
+func TestDeadline_NoDeadline(t *testing.T) {
+	ctx, cancel := withTHeaderDeadline(context.Background())
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+// TestTBinaryProcessorFunction_DeadlineExceeded verifies that the handler is
+// not called when the deadline expired before it could start.
+func TestTBinaryProcessorFunction_DeadlineExceeded(t *testing.T) {
+	clientProt, serverProt := processorPipe()
+
+	h := &binaryHandler{}
+	p := NewTStandardProcessor(nil)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(p, "echo", func() TRequest { return newTString("") }, h),
+	)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	done := make(chan struct{})
+
+	go func() {
+		p.Process(ctx, serverProt, serverProt) //nolint:errcheck
+		close(done)
+	}()
+
+	rawCall(t, clientProt, "echo", 1, "req")
+
+	_, typeID, _, err := clientProt.ReadMessageBegin()
+	require.NoError(t, err)
+	assert.Equal(t, EXCEPTION, typeID)
+
+	var ex tApplicationException
+
+	require.NoError(t, ex.Read(clientProt))
+	require.NoError(t, clientProt.ReadMessageEnd())
+	assert.Equal(t, int32(INTERNAL_TIME_OUT_ERROR), ex.TypeId())
+
+	<-done
+	assert.False(t, h.called)
+}
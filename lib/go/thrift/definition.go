@@ -21,6 +21,10 @@ func GetServiceDefinition(n string) (ServiceDefinition, bool) {
 	return reflection.GetServiceDefinition(n)
 }
 
+func HasStructuredAnnotation(def AnnotatedDefinition, a RegistrableStruct) bool {
+	return reflection.HasStructuredAnnotation(def, a)
+}
+
 func RegisterStruct(rs RegistrableStruct) {
 	reflection.RegisterStruct(rs)
 }
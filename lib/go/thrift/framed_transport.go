@@ -35,6 +35,7 @@ type TFramedTransport struct {
 	reader    *bufio.Reader
 	frameSize uint32 //Current remaining size of the frame. if ==0 read next frame header
 	buffer    [4]byte
+	wbuffer   [4]byte
 	maxLength uint32
 }
 
@@ -150,7 +151,7 @@ func (p *TFramedTransport) WriteString(s string) (n int, err error) {
 
 func (p *TFramedTransport) Flush() error {
 	size := p.buf.Len()
-	buf := p.buffer[:4]
+	buf := p.wbuffer[:4]
 	binary.BigEndian.PutUint32(buf, uint32(size))
 	_, err := p.transport.Write(buf)
 	if err != nil {
@@ -23,6 +23,10 @@ import (
 	"context"
 )
 
+// THeaderCallerKey is the reserved THeader identifying the caller of a call,
+// the middlewares keeping a state per caller read it by default.
+const THeaderCallerKey = "thrift-caller"
+
 // See https://godoc.org/context#WithValue on why do we need the unexported typedefs.
 type (
 	headerKey     string
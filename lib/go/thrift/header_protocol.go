@@ -77,6 +77,11 @@ func (p *THeaderProtocol) SetWriteHeader(key, value string) {
 	p.transport.SetWriteHeader(key, value)
 }
 
+// RemoveWriteHeader removes a header previously set for write.
+func (p *THeaderProtocol) RemoveWriteHeader(key string) {
+	p.transport.RemoveWriteHeader(key)
+}
+
 // ClearWriteHeaders clears all write headers previously set.
 func (p *THeaderProtocol) ClearWriteHeaders() {
 	p.transport.ClearWriteHeaders()
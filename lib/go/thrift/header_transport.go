@@ -41,15 +41,45 @@ type headerMeta struct {
 
 const headerMetaSize = 10
 
-type clientType int
+// THeaderClientType is the dialect THeaderTransport detected its peer
+// speaks.
+type THeaderClientType int
 
 const (
-	clientUnknown clientType = iota
-	clientHeaders
-	clientFramedBinary
-	clientUnframedBinary
-	clientFramedCompact
-	clientUnframedCompact
+	THeaderClientUnknown THeaderClientType = iota
+	THeaderClientHeaders
+	THeaderClientFramedBinary
+	THeaderClientUnframedBinary
+	THeaderClientFramedCompact
+	THeaderClientUnframedCompact
+)
+
+func (t THeaderClientType) String() string {
+	switch t {
+	case THeaderClientHeaders:
+		return "headers"
+	case THeaderClientFramedBinary:
+		return "framed_binary"
+	case THeaderClientUnframedBinary:
+		return "unframed_binary"
+	case THeaderClientFramedCompact:
+		return "framed_compact"
+	case THeaderClientUnframedCompact:
+		return "unframed_compact"
+	}
+
+	return "unknown"
+}
+
+type clientType = THeaderClientType
+
+const (
+	clientUnknown         = THeaderClientUnknown
+	clientHeaders         = THeaderClientHeaders
+	clientFramedBinary    = THeaderClientFramedBinary
+	clientUnframedBinary  = THeaderClientUnframedBinary
+	clientFramedCompact   = THeaderClientFramedCompact
+	clientUnframedCompact = THeaderClientUnframedCompact
 )
 
 // Constants defined in THeader format:
@@ -366,6 +396,18 @@ func (t *THeaderTransport) ReadFrame() error {
 	)
 }
 
+// ClientType returns the dialect detected from the first frame read,
+// THeaderClientUnknown before it.
+func (t *THeaderTransport) ClientType() THeaderClientType {
+	return t.clientType
+}
+
+// hasPendingRead tells whether data read from the underlying transport is
+// not consumed yet.
+func (t *THeaderTransport) hasPendingRead() bool {
+	return t.frameBuffer.Len() > 0 || t.reader.Buffered() > 0
+}
+
 // endOfFrame does end of frame handling.
 //
 // It closes frameReader, and also resets frame related states.
@@ -445,7 +487,9 @@ func (t *THeaderTransport) parseHeaders(frameSize uint32) error {
 	headers := make(THeaderMap)
 	for {
 		infoType, err := hp.readVarint32()
-		if err == io.EOF {
+		// Without padding the info section ends with the header, the
+		// compact protocol wraps the EOF in a TProtocolException.
+		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
@@ -661,6 +705,11 @@ func (t *THeaderTransport) SetWriteHeader(key, value string) {
 	t.writeHeaders[key] = value
 }
 
+// RemoveWriteHeader removes a header previously set for write.
+func (t *THeaderTransport) RemoveWriteHeader(key string) {
+	delete(t.writeHeaders, key)
+}
+
 // ClearWriteHeaders clears all write headers previously set.
 func (t *THeaderTransport) ClearWriteHeaders() {
 	t.writeHeaders = make(THeaderMap)
@@ -127,3 +127,28 @@ func TestTHeaderTransportNoDoubleWrapping(t *testing.T) {
 		t.Errorf("NewTHeaderTransport double wrapped THeaderTransport")
 	}
 }
+
+// TestTHeaderHeadersUnpadded verifies that an info section filling the header
+// up to a 4 bytes boundary, hence written without padding, is read back.
+func TestTHeaderHeadersUnpadded(t *testing.T) {
+	trans := NewTMemoryBuffer()
+	reader := NewTHeaderTransport(trans)
+	writer := NewTHeaderTransport(trans)
+
+	// protocol ID, transform count, info type, header count, then the key
+	// and the value prefixed by their length: 8 bytes.
+	writer.SetWriteHeader("k", "v")
+	if _, err := writer.Write([]byte("payload")); err != nil {
+		t.Fatalf("writer.Write returned error: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("writer.Flush returned error: %v", err)
+	}
+
+	if err := reader.ReadFrame(); err != nil {
+		t.Fatalf("reader.ReadFrame returned error: %v", err)
+	}
+	if headers := reader.GetReadHeaders(); headers["k"] != "v" {
+		t.Errorf("reader.GetReadHeaders() expected k=v, actual content: %+v", headers)
+	}
+}
@@ -22,6 +22,7 @@ package thrift
 import (
 	"compress/gzip"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -34,10 +35,27 @@ func NewThriftHandlerFunc(processor TProcessor,
 		w.Header().Add("Content-Type", "application/x-thrift")
 
 		transport := NewStreamTransport(r.Body, w)
-		processor.Process(r.Context(), inPfactory.GetProtocol(transport), outPfactory.GetProtocol(transport))
+		processor.Process(withHTTPPeer(r), inPfactory.GetProtocol(transport), outPfactory.GetProtocol(transport))
 	})
 }
 
+// withHTTPPeer returns the context of r carrying the peer r was received
+// from, the HTTP connections are not identified.
+func withHTTPPeer(r *http.Request) Context {
+	ctx := r.Context()
+	p := TPeer{TLS: r.TLS, RemoteAddr: tAddr{network: "tcp", addr: r.RemoteAddr}}
+
+	if addr, ok := ctx.Value(http.LocalAddrContextKey).(net.Addr); ok {
+		p.LocalAddr = addr
+	}
+
+	if r.TLS != nil {
+		ctx = SetTLSConnectionState(ctx, r.TLS)
+	}
+
+	return WithPeer(ctx, p)
+}
+
 // gz transparently compresses the HTTP response if the client supports it.
 func gz(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
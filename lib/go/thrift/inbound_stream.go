@@ -17,9 +17,9 @@ type tInboundStream struct {
 	messageType TMessageType
 }
 
-func newTClientInboundStream(name string, seqID int32, in, out TProtocol, cl *TSyncClient) *tInboundStream {
+func newTClientInboundStream(name string, seqID int32, in, out TProtocol, release func()) *tInboundStream {
 	return &tInboundStream{
-		tBaseStream: newTClientBaseStream(name, seqID, in, out, SERVER_STREAM_GOAWAY, cl),
+		tBaseStream: newTClientBaseStream(name, seqID, in, out, SERVER_STREAM_GOAWAY, release),
 		messageType: SERVER_STREAM_MESSAGE,
 	}
 }
@@ -49,6 +49,12 @@ func (s *tInboundStream) Close() error {
 	return nil
 }
 
+// goAway tells the peer to stop sending, the messages already sent are
+// received before the acknowledgement ending the stream.
+func (s *tInboundStream) goAway() {
+	s.goAwayOnce.Do(func() { s.writeGoAway() })
+}
+
 func (s *tInboundStream) readGoAwayACK() error {
 	mt, err := s.readShell()
 
@@ -80,6 +86,10 @@ func (s *tInboundStream) Receive(ctx Context, req TRequest) error {
 		s.goAwayOnce.Do(func() {})
 		s.close()
 		return io.EOF
+	case s.goAwayACKType:
+		s.in.ReadMessageEnd()
+		s.close()
+		return io.EOF
 	default:
 		s.in.ReadMessageEnd()
 		return fmt.Errorf("unexpected messaege type: %v", typeID)
@@ -21,8 +21,9 @@ var (
 	}
 
 	defaultServiceRegistry = &serviceRegistry{
-		defs: make(map[string]ServiceDefinition),
-		ext:  defaultCanonicalNameExtractor,
+		defs:      make(map[string]ServiceDefinition),
+		functions: make(map[string]map[string]ServiceDefinition),
+		ext:       defaultCanonicalNameExtractor,
 	}
 )
 
@@ -74,6 +75,10 @@ type serviceRegistry struct {
 
 	ext  CanonicalNameExtractor
 	defs map[string]ServiceDefinition
+
+	// functions indexes the services by the canonical name of the arguments
+	// struct of their functions, then by their own canonical name.
+	functions map[string]map[string]ServiceDefinition
 }
 
 func (str *serviceRegistry) registerService(sd ServiceDefinition) {
@@ -85,6 +90,36 @@ func (str *serviceRegistry) registerService(sd ServiceDefinition) {
 	for _, n := range ns {
 		str.defs[n] = sd
 	}
+
+	for _, fd := range sd.Functions {
+		k := fd.Args.CanonicalName()
+
+		if str.functions[k] == nil {
+			str.functions[k] = make(map[string]ServiceDefinition)
+		}
+
+		str.functions[k][sd.CanonicalName()] = sd
+	}
+}
+
+func (str *serviceRegistry) lookupFunction(name string, args StructDefinition) (ServiceDefinition, FunctionDefinition, bool) {
+	str.mu.RLock()
+	defer str.mu.RUnlock()
+
+	var (
+		rsd ServiceDefinition
+		rfd FunctionDefinition
+		n   int
+	)
+
+	for _, sd := range str.functions[args.CanonicalName()] {
+		if fd, ok := sd.Function(name); ok && fd.Args.CanonicalName() == args.CanonicalName() {
+			rsd, rfd = sd, fd
+			n++
+		}
+	}
+
+	return rsd, rfd, n == 1
 }
 
 type structTypeRegistry struct {
@@ -186,6 +221,45 @@ func (sd ServiceDefinition) CanonicalName() string {
 	return fmt.Sprintf("%s.%s", sd.Namespace, sd.Name)
 }
 
+func (sd ServiceDefinition) Function(name string) (FunctionDefinition, bool) {
+	for _, fd := range sd.Functions {
+		if fd.Name == name {
+			return fd, true
+		}
+	}
+
+	return FunctionDefinition{}, false
+}
+
+// maxAnnotationDepth bounds the walk through annotations of annotations, it
+// protects against annotations referencing each other.
+const maxAnnotationDepth = 8
+
+// HasStructuredAnnotation reports whether def carries an annotation of the
+// same type as a, either directly or through the annotations of one of its
+// annotations (e.g. ReadOnly being itself annotated with Idempotent).
+func HasStructuredAnnotation(def AnnotatedDefinition, a RegistrableStruct) bool {
+	return hasStructuredAnnotation(def.StructuredAnnotations, reflect.TypeOf(a), 0)
+}
+
+func hasStructuredAnnotation(as []RegistrableStruct, t reflect.Type, depth int) bool {
+	if depth > maxAnnotationDepth {
+		return false
+	}
+
+	for _, a := range as {
+		if reflect.TypeOf(a) == t {
+			return true
+		}
+
+		if hasStructuredAnnotation(a.StructDefinition().StructuredAnnotations, t, depth+1) {
+			return true
+		}
+	}
+
+	return false
+}
+
 type RegistrableStruct interface {
 	StructDefinition() StructDefinition
 }
@@ -200,6 +274,13 @@ func GetServiceDefinition(n string) (ServiceDefinition, bool) {
 	return def, ok
 }
 
+// LookupFunction returns the registered function named name taking args as
+// arguments struct, along with its service. It reports false when no service,
+// or more than one, defines such a function.
+func LookupFunction(name string, args StructDefinition) (ServiceDefinition, FunctionDefinition, bool) {
+	return defaultServiceRegistry.lookupFunction(name, args)
+}
+
 func RegisterStruct(rs RegistrableStruct) {
 	defaultStructTypeRegistry.registerStructType(rs)
 }
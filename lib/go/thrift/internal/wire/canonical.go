@@ -0,0 +1,230 @@
+package wire
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+type frame struct {
+	buf  *thrift.TMemoryBuffer
+	prot *thrift.TBinaryProtocol
+
+	// arity is the number of values of an entry: 2 for a map, 1 for a set
+	// and 0 for the root frame whose content is kept in order.
+	arity   int
+	values  int
+	nesting int
+	entries [][]byte
+}
+
+func newFrame(arity int) *frame {
+	buf := thrift.NewTMemoryBuffer()
+
+	return &frame{buf: buf, prot: thrift.NewTBinaryProtocol(buf, false, true), arity: arity}
+}
+
+// valueDone accounts for a value fully written in f, once all the values of
+// an entry are written its bytes are set aside to be sorted.
+func (f *frame) valueDone() {
+	if f.arity == 0 {
+		return
+	}
+
+	if f.values++; f.values < f.arity {
+		return
+	}
+
+	f.entries = append(f.entries, append([]byte(nil), f.buf.Bytes()...))
+	f.buf.Reset()
+	f.values = 0
+}
+
+// canonicalProtocol is a write only binary TProtocol sorting the entries of
+// the maps and the elements of the sets, which are written in the random
+// iteration order of Go maps, so that equal requests serialize the same way.
+type canonicalProtocol struct {
+	thrift.TProtocol
+
+	frames []*frame
+}
+
+func newCanonicalProtocol() *canonicalProtocol {
+	root := newFrame(0)
+
+	return &canonicalProtocol{TProtocol: root.prot, frames: []*frame{root}}
+}
+
+func (p *canonicalProtocol) Bytes() []byte {
+	return p.frames[0].buf.Bytes()
+}
+
+func (p *canonicalProtocol) current() *frame {
+	return p.frames[len(p.frames)-1]
+}
+
+func (p *canonicalProtocol) value(err error) error {
+	if f := p.current(); err == nil && f.nesting == 0 {
+		f.valueDone()
+	}
+
+	return err
+}
+
+func (p *canonicalProtocol) open(err error) error {
+	if err == nil {
+		p.current().nesting++
+	}
+
+	return err
+}
+
+func (p *canonicalProtocol) close(err error) error {
+	if err != nil {
+		return err
+	}
+
+	f := p.current()
+
+	if f.nesting--; f.nesting == 0 {
+		f.valueDone()
+	}
+
+	return nil
+}
+
+func (p *canonicalProtocol) push(arity int, err error) error {
+	if err == nil {
+		p.frames = append(p.frames, newFrame(arity))
+	}
+
+	return err
+}
+
+// pop writes the entries of the map or set ending, sorted, to the frame
+// enclosing it.
+func (p *canonicalProtocol) pop() error {
+	f := p.current()
+	p.frames = p.frames[:len(p.frames)-1]
+
+	sort.Slice(f.entries, func(i, j int) bool {
+		return bytes.Compare(f.entries[i], f.entries[j]) < 0
+	})
+
+	parent := p.current()
+
+	for _, e := range f.entries {
+		if _, err := parent.buf.Write(e); err != nil {
+			return err
+		}
+	}
+
+	return p.value(nil)
+}
+
+func (p *canonicalProtocol) WriteStructBegin(name string) error {
+	return p.open(p.current().prot.WriteStructBegin(name))
+}
+
+func (p *canonicalProtocol) WriteStructEnd() error {
+	return p.close(p.current().prot.WriteStructEnd())
+}
+
+func (p *canonicalProtocol) WriteFieldBegin(name string, typeID thrift.TType, id int16) error {
+	return p.current().prot.WriteFieldBegin(name, typeID, id)
+}
+
+func (p *canonicalProtocol) WriteFieldEnd() error {
+	return p.current().prot.WriteFieldEnd()
+}
+
+func (p *canonicalProtocol) WriteFieldStop() error {
+	return p.current().prot.WriteFieldStop()
+}
+
+func (p *canonicalProtocol) WriteMapBegin(keyType, valueType thrift.TType, size int) error {
+	return p.push(2, p.current().prot.WriteMapBegin(keyType, valueType, size))
+}
+
+func (p *canonicalProtocol) WriteMapEnd() error {
+	return p.pop()
+}
+
+func (p *canonicalProtocol) WriteListBegin(elemType thrift.TType, size int) error {
+	return p.open(p.current().prot.WriteListBegin(elemType, size))
+}
+
+func (p *canonicalProtocol) WriteListEnd() error {
+	return p.close(p.current().prot.WriteListEnd())
+}
+
+func (p *canonicalProtocol) WriteSetBegin(elemType thrift.TType, size int) error {
+	return p.push(1, p.current().prot.WriteSetBegin(elemType, size))
+}
+
+func (p *canonicalProtocol) WriteSetEnd() error {
+	return p.pop()
+}
+
+func (p *canonicalProtocol) WriteBool(v bool) error {
+	return p.value(p.current().prot.WriteBool(v))
+}
+
+func (p *canonicalProtocol) WriteByte(v byte) error {
+	return p.value(p.current().prot.WriteByte(v))
+}
+
+func (p *canonicalProtocol) WriteI16(v int16) error {
+	return p.value(p.current().prot.WriteI16(v))
+}
+
+func (p *canonicalProtocol) WriteI32(v int32) error {
+	return p.value(p.current().prot.WriteI32(v))
+}
+
+func (p *canonicalProtocol) WriteI64(v int64) error {
+	return p.value(p.current().prot.WriteI64(v))
+}
+
+func (p *canonicalProtocol) WriteDouble(v float64) error {
+	return p.value(p.current().prot.WriteDouble(v))
+}
+
+func (p *canonicalProtocol) WriteString(v string) error {
+	return p.value(p.current().prot.WriteString(v))
+}
+
+func (p *canonicalProtocol) WriteBinary(v []byte) error {
+	return p.value(p.current().prot.WriteBinary(v))
+}
+
+// CanonicalBytes serializes s with the binary protocol, the entries of its
+// maps and sets sorted.
+func CanonicalBytes(s thrift.TStruct) ([]byte, error) {
+	p := newCanonicalProtocol()
+
+	if err := s.Write(p); err != nil {
+		return nil, err
+	}
+
+	return p.Bytes(), nil
+}
+
+// Canonicalize sorts the entries of the maps and sets of the struct
+// serialized with the binary protocol in data.
+func Canonicalize(data []byte) ([]byte, error) {
+	buf := thrift.NewTMemoryBuffer()
+
+	if _, err := buf.Write(data); err != nil {
+		return nil, err
+	}
+
+	p := newCanonicalProtocol()
+
+	if err := Transcode(thrift.NewTBinaryProtocolTransport(buf), p, thrift.STRUCT); err != nil {
+		return nil, err
+	}
+
+	return p.Bytes(), nil
+}
@@ -0,0 +1,135 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+type getArgs struct {
+	Tags   map[string]map[string]bool
+	IDs    []int64
+	Unique []string
+}
+
+func (a *getArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("get_args")
+
+	p.WriteFieldBegin("tags", thrift.MAP, 1)
+	p.WriteMapBegin(thrift.STRING, thrift.MAP, len(a.Tags))
+
+	for k, vs := range a.Tags {
+		p.WriteString(k)
+		p.WriteMapBegin(thrift.STRING, thrift.BOOL, len(vs))
+
+		for k, v := range vs {
+			p.WriteString(k)
+			p.WriteBool(v)
+		}
+
+		p.WriteMapEnd()
+	}
+
+	p.WriteMapEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldBegin("ids", thrift.LIST, 2)
+	p.WriteListBegin(thrift.I64, len(a.IDs))
+
+	for _, id := range a.IDs {
+		p.WriteI64(id)
+	}
+
+	p.WriteListEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldBegin("unique", thrift.SET, 3)
+	p.WriteSetBegin(thrift.STRING, len(a.Unique))
+
+	for _, v := range a.Unique {
+		p.WriteString(v)
+	}
+
+	p.WriteSetEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (a *getArgs) Read(p thrift.TProtocol) error { return p.Skip(thrift.STRUCT) }
+func (a *getArgs) String() string                { return fmt.Sprintf("%+v", *a) }
+
+func TestCanonicalBytes(t *testing.T) {
+	var (
+		tags = map[string]map[string]bool{}
+		want []byte
+	)
+
+	for i := 0; i < 16; i++ {
+		tags[fmt.Sprintf("k%d", i)] = map[string]bool{"a": true, "b": false, "c": true}
+	}
+
+	for i := 0; i < 32; i++ {
+		b, err := CanonicalBytes(
+			&getArgs{Tags: tags, IDs: []int64{3, 1, 2}, Unique: []string{"y", "x"}},
+		)
+		require.NoError(t, err)
+
+		if want == nil {
+			want = b
+		}
+
+		assert.Equal(t, want, b)
+	}
+
+	b, err := CanonicalBytes(
+		&getArgs{Tags: tags, IDs: []int64{1, 2, 3}, Unique: []string{"x", "y"}},
+	)
+	require.NoError(t, err)
+
+	// Lists keep their order, sets do not.
+	assert.NotEqual(t, want, b)
+
+	b, err = CanonicalBytes(
+		&getArgs{Tags: tags, IDs: []int64{3, 1, 2}, Unique: []string{"x", "y"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, want, b)
+
+	// The canonical bytes are still valid binary protocol.
+	buf := thrift.NewTMemoryBuffer()
+	buf.Write(want)
+
+	require.NoError(t, thrift.NewTBinaryProtocolTransport(buf).Skip(thrift.STRUCT))
+	assert.Zero(t, buf.Len())
+}
+
+func TestCanonicalize(t *testing.T) {
+	tags := map[string]map[string]bool{}
+
+	for i := 0; i < 16; i++ {
+		tags[fmt.Sprintf("k%d", i)] = map[string]bool{"a": true, "b": false, "c": true}
+	}
+
+	args := &getArgs{Tags: tags, IDs: []int64{3, 1, 2}, Unique: []string{"y", "x"}}
+
+	want, err := CanonicalBytes(args)
+	require.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		b, err := thrift.NewTSerializer().Write(context.Background(), args)
+		require.NoError(t, err)
+
+		b, err = Canonicalize(b)
+		require.NoError(t, err)
+
+		assert.Equal(t, want, b)
+	}
+}
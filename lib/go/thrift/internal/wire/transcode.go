@@ -0,0 +1,212 @@
+package wire
+
+import (
+	"fmt"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// Transcode reads a value of type typ from in and writes it to out, the
+// value does not have to be known beforehand.
+func Transcode(in, out thrift.TProtocol, typ thrift.TType) error {
+	switch typ {
+	case thrift.BOOL:
+		v, err := in.ReadBool()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteBool(v)
+	case thrift.BYTE:
+		v, err := in.ReadByte()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteByte(v)
+	case thrift.I16:
+		v, err := in.ReadI16()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteI16(v)
+	case thrift.I32:
+		v, err := in.ReadI32()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteI32(v)
+	case thrift.I64:
+		v, err := in.ReadI64()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteI64(v)
+	case thrift.DOUBLE:
+		v, err := in.ReadDouble()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteDouble(v)
+	case thrift.STRING:
+		// The binary protocol encodes strings and binaries alike.
+		v, err := in.ReadBinary()
+
+		if err != nil {
+			return err
+		}
+
+		return out.WriteBinary(v)
+	case thrift.STRUCT:
+		return transcodeStruct(in, out)
+	case thrift.MAP:
+		kt, vt, n, err := in.ReadMapBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if err := out.WriteMapBegin(kt, vt, n); err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			if err := Transcode(in, out, kt); err != nil {
+				return err
+			}
+
+			if err := Transcode(in, out, vt); err != nil {
+				return err
+			}
+		}
+
+		if err := in.ReadMapEnd(); err != nil {
+			return err
+		}
+
+		return out.WriteMapEnd()
+	case thrift.LIST:
+		et, n, err := in.ReadListBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if err := out.WriteListBegin(et, n); err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			if err := Transcode(in, out, et); err != nil {
+				return err
+			}
+		}
+
+		if err := in.ReadListEnd(); err != nil {
+			return err
+		}
+
+		return out.WriteListEnd()
+	case thrift.SET:
+		et, n, err := in.ReadSetBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if err := out.WriteSetBegin(et, n); err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			if err := Transcode(in, out, et); err != nil {
+				return err
+			}
+		}
+
+		if err := in.ReadSetEnd(); err != nil {
+			return err
+		}
+
+		return out.WriteSetEnd()
+	}
+
+	return thrift.NewTProtocolExceptionWithType(
+		thrift.INVALID_DATA,
+		fmt.Errorf("unknown type %d", typ),
+	)
+}
+
+func transcodeStruct(in, out thrift.TProtocol) error {
+	name, err := in.ReadStructBegin()
+
+	if err != nil {
+		return err
+	}
+
+	if err := out.WriteStructBegin(name); err != nil {
+		return err
+	}
+
+	for {
+		name, typ, id, err := in.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typ == thrift.STOP {
+			break
+		}
+
+		if err := out.WriteFieldBegin(name, typ, id); err != nil {
+			return err
+		}
+
+		if err := Transcode(in, out, typ); err != nil {
+			return err
+		}
+
+		if err := in.ReadFieldEnd(); err != nil {
+			return err
+		}
+
+		if err := out.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	if err := out.WriteFieldStop(); err != nil {
+		return err
+	}
+
+	if err := in.ReadStructEnd(); err != nil {
+		return err
+	}
+
+	return out.WriteStructEnd()
+}
+
+// Lossless reports whether Transcode preserves the values written to p. The
+// binary and compact protocols encode the strings and the binaries alike,
+// the other ones tell them apart and would get the strings as binaries.
+func Lossless(p thrift.TProtocol) bool {
+	switch p := p.(type) {
+	case *thrift.TBinaryProtocol, *thrift.TCompactProtocol, *thrift.THeaderProtocol:
+		return true
+	case *thrift.TMultiplexedProtocol:
+		return Lossless(p.TProtocol)
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package thrift
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/upfluence/thrift/lib/go/thrift/internal/reflection"
+)
+
+// TMethodDefinition describes the function a call is made to.
+type TMethodDefinition struct {
+	Service  ServiceDefinition
+	Function FunctionDefinition
+}
+
+type methodDefinitionKey struct{}
+
+// WithMethodDefinition returns a copy of ctx carrying md.
+func WithMethodDefinition(ctx Context, md TMethodDefinition) Context {
+	return context.WithValue(ctx, methodDefinitionKey{}, &md)
+}
+
+// WithMethod returns a copy of ctx carrying the definition of the function
+// method of sd, as its processor would set it. ctx is returned as is when sd
+// has no such function.
+func WithMethod(ctx Context, sd ServiceDefinition, method string) Context {
+	fd, ok := sd.Function(method)
+
+	if !ok {
+		return ctx
+	}
+
+	return WithMethodDefinition(ctx, TMethodDefinition{Service: sd, Function: fd})
+}
+
+// GetMethodDefinition returns the definition of the function the call of ctx
+// is made to. The processors and the clients set it before running their
+// middlewares, when the function and its service were registered.
+func GetMethodDefinition(ctx Context) (TMethodDefinition, bool) {
+	if ctx == nil {
+		return TMethodDefinition{}, false
+	}
+
+	md, ok := ctx.Value(methodDefinitionKey{}).(*TMethodDefinition)
+
+	if !ok || md == nil {
+		return TMethodDefinition{}, false
+	}
+
+	return *md, true
+}
+
+type methodDefinitionCacheKey struct {
+	t      reflect.Type
+	method string
+}
+
+var methodDefinitions sync.Map
+
+// LookupMethodDefinition returns the definition of the function method whose
+// arguments struct is req. The function is found among the registered
+// services from the definition of req, it must then be a generated arguments
+// struct. It reports false when no service, or more than one, matches.
+func LookupMethodDefinition(method string, req TRequest) (TMethodDefinition, bool) {
+	rs, ok := req.(RegistrableStruct)
+
+	if !ok {
+		return TMethodDefinition{}, false
+	}
+
+	k := methodDefinitionCacheKey{t: reflect.TypeOf(req), method: method}
+
+	if v, ok := methodDefinitions.Load(k); ok {
+		return v.(TMethodDefinition), true
+	}
+
+	sd, fd, ok := reflection.LookupFunction(method, rs.StructDefinition())
+
+	if !ok {
+		// The misses are not cached: the service may be registered later on.
+		return TMethodDefinition{}, false
+	}
+
+	md := TMethodDefinition{Service: sd, Function: fd}
+	methodDefinitions.Store(k, md)
+
+	return md, true
+}
+
+// withCallMethodDefinition sets the definition of the function a client call
+// is made to on ctx. A definition ctx already carries, like the one of the
+// call a handler serves, is hidden when none is found.
+func withCallMethodDefinition(ctx Context, method string, req TRequest) Context {
+	if ctx == nil {
+		return ctx
+	}
+
+	if md, ok := LookupMethodDefinition(method, req); ok {
+		return WithMethodDefinition(ctx, md)
+	}
+
+	if _, ok := GetMethodDefinition(ctx); ok {
+		return context.WithValue(ctx, methodDefinitionKey{}, (*TMethodDefinition)(nil))
+	}
+
+	return ctx
+}
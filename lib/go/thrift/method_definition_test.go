@@ -0,0 +1,157 @@
+package thrift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// defArgs is a tstring standing for the generated arguments struct of the
+// echo function of the definition.test Service.
+type defArgs struct {
+	*tstring
+}
+
+var defArgsDefinition = StructDefinition{
+	Namespace:           "definition.test",
+	AnnotatedDefinition: AnnotatedDefinition{Name: "echo_args"},
+}
+
+func (defArgs) StructDefinition() StructDefinition { return defArgsDefinition }
+
+var defService = ServiceDefinition{
+	Namespace:           "definition.test",
+	AnnotatedDefinition: AnnotatedDefinition{Name: "Service"},
+	Functions: []FunctionDefinition{
+		{AnnotatedDefinition: AnnotatedDefinition{Name: "echo"}, Args: defArgsDefinition},
+	},
+}
+
+func init() {
+	RegisterService(defService)
+}
+
+// definitionRecorder is a middleware recording the method definition of the
+// calls it sees.
+type definitionRecorder struct {
+	TNopMiddleware
+
+	definition TMethodDefinition
+	found      bool
+}
+
+func (m *definitionRecorder) HandleBinaryRequest(ctx Context, mth string, seqID int32, req TRequest, next func(Context, TRequest) (TResponse, error)) (TResponse, error) {
+	m.definition, m.found = GetMethodDefinition(ctx)
+	return next(ctx, req)
+}
+
+func TestLookupMethodDefinition(t *testing.T) {
+	md, ok := LookupMethodDefinition("echo", defArgs{newTString("")})
+	require.True(t, ok)
+	assert.Equal(t, "definition.test.Service", md.Service.CanonicalName())
+	assert.Equal(t, "echo", md.Function.Name)
+
+	_, ok = LookupMethodDefinition("other", defArgs{newTString("")})
+	assert.False(t, ok)
+
+	_, ok = LookupMethodDefinition("echo", newTString(""))
+	assert.False(t, ok)
+}
+
+// TestLookupMethodDefinition_LateRegistration verifies that a miss does not
+// hide the service registered afterwards.
+func TestLookupMethodDefinition_LateRegistration(t *testing.T) {
+	args := StructDefinition{
+		Namespace:           "definition.test",
+		AnnotatedDefinition: AnnotatedDefinition{Name: "late_args"},
+	}
+
+	req := lateArgs{defArgs{newTString("")}, args}
+
+	_, ok := LookupMethodDefinition("late", req)
+	require.False(t, ok)
+
+	RegisterService(
+		ServiceDefinition{
+			Namespace:           "definition.test",
+			AnnotatedDefinition: AnnotatedDefinition{Name: "Late"},
+			Functions: []FunctionDefinition{
+				{AnnotatedDefinition: AnnotatedDefinition{Name: "late"}, Args: args},
+			},
+		},
+	)
+
+	md, ok := LookupMethodDefinition("late", req)
+	require.True(t, ok)
+	assert.Equal(t, "definition.test.Late", md.Service.CanonicalName())
+}
+
+type lateArgs struct {
+	defArgs
+
+	definition StructDefinition
+}
+
+func (a lateArgs) StructDefinition() StructDefinition { return a.definition }
+
+// TestMethodDefinition_Call verifies that the definition is available to the
+// middlewares of both the processor and the client.
+func TestMethodDefinition_Call(t *testing.T) {
+	clientProt, serverProt := processorPipe()
+
+	var (
+		sm definitionRecorder
+		cm definitionRecorder
+
+		p      = NewTStandardProcessor([]TMiddleware{&sm})
+		client = &TSyncClient{in: clientProt, out: clientProt, middleware: WrapMiddlewares([]TMiddleware{&cm})}
+	)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(
+			p,
+			"echo",
+			func() TRequest { return defArgs{newTString("")} },
+			&binaryHandler{},
+		),
+	)
+
+	go p.Process(context.Background(), serverProt, serverProt) //nolint:errcheck
+
+	require.NoError(
+		t,
+		client.CallBinary(context.Background(), "echo", defArgs{newTString("foo")}, defArgs{newTString("")}),
+	)
+
+	for _, m := range []*definitionRecorder{&sm, &cm} {
+		assert.True(t, m.found)
+		assert.Equal(t, "Service", m.definition.Service.Name)
+		assert.Equal(t, "echo", m.definition.Function.Name)
+	}
+}
+
+// TestMethodDefinition_Hidden verifies that the definition of the call a
+// handler serves does not leak to the unknown calls it makes.
+func TestMethodDefinition_Hidden(t *testing.T) {
+	ctx := WithMethodDefinition(context.Background(), TMethodDefinition{Service: defService})
+
+	_, ok := GetMethodDefinition(withCallMethodDefinition(ctx, "echo", newTString("")))
+	assert.False(t, ok)
+
+	md, ok := GetMethodDefinition(withCallMethodDefinition(ctx, "echo", defArgs{newTString("")}))
+	assert.True(t, ok)
+	assert.Equal(t, "echo", md.Function.Name)
+}
+
+func TestWithMethod(t *testing.T) {
+	md, ok := GetMethodDefinition(WithMethod(context.Background(), defService, "echo"))
+	assert.True(t, ok)
+	assert.Equal(t, "Service", md.Service.Name)
+	assert.Equal(t, "echo", md.Function.Name)
+
+	_, ok = GetMethodDefinition(WithMethod(context.Background(), defService, "unknown"))
+	assert.False(t, ok)
+}
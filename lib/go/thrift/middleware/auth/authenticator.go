@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const DefaultHeader = "authorization"
+
+// ErrNoCredentials is returned by an Authenticator when the call carries none
+// of the credentials it reads.
+var ErrNoCredentials = errors.New("thrift: no credentials")
+
+// Authenticator returns the principal a call is made on behalf of, from the
+// credentials it carries.
+type Authenticator interface {
+	Authenticate(thrift.Context) (*Principal, error)
+}
+
+type AuthenticatorFunc func(thrift.Context) (*Principal, error)
+
+func (fn AuthenticatorFunc) Authenticate(ctx thrift.Context) (*Principal, error) {
+	return fn(ctx)
+}
+
+// MultiAuthenticator tries its authenticators in order, the first one finding
+// credentials decides.
+type MultiAuthenticator []Authenticator
+
+func (as MultiAuthenticator) Authenticate(ctx thrift.Context) (*Principal, error) {
+	for _, a := range as {
+		p, err := a.Authenticate(ctx)
+
+		if !errors.Is(err, ErrNoCredentials) {
+			return p, err
+		}
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// NewHeaderAuthenticator returns an Authenticator verifying the value of the
+// header THeader. A "Bearer " prefix is trimmed from it.
+func NewHeaderAuthenticator(header string, verify func(ctx thrift.Context, credentials string) (*Principal, error)) Authenticator {
+	if header == "" {
+		header = DefaultHeader
+	}
+
+	return AuthenticatorFunc(func(ctx thrift.Context) (*Principal, error) {
+		v, ok := thrift.GetHeader(ctx, header)
+
+		if !ok || v == "" {
+			return nil, ErrNoCredentials
+		}
+
+		if len(v) > 7 && strings.EqualFold(v[:7], "bearer ") {
+			v = v[7:]
+		}
+
+		return verify(ctx, v)
+	})
+}
+
+// NewTLSAuthenticator returns an Authenticator verifying the certificate of
+// the peer. Only the certificates verified during the handshake are used, the
+// tls.Config of the server must then verify the client certificates.
+func NewTLSAuthenticator(verify func(ctx thrift.Context, cert *x509.Certificate) (*Principal, error)) Authenticator {
+	return AuthenticatorFunc(func(ctx thrift.Context) (*Principal, error) {
+		cs, ok := thrift.GetTLSConnectionState(ctx)
+
+		if !ok || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+			return nil, ErrNoCredentials
+		}
+
+		return verify(ctx, cs.VerifiedChains[0][0])
+	})
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// NewUnauthenticated returns the UNAUTHENTICATED TApplicationException
+// failing the calls without valid credentials.
+func NewUnauthenticated(msg string) thrift.TApplicationException {
+	return thrift.NewTApplicationException(thrift.UNAUTHENTICATED, msg)
+}
+
+// NewPermissionDenied returns the PERMISSION_DENIED TApplicationException
+// failing the calls whose principal lacks a required scope.
+func NewPermissionDenied(msg string) thrift.TApplicationException {
+	return thrift.NewTApplicationException(thrift.PERMISSION_DENIED, msg)
+}
+
+func isApplicationException(err error, typeID int32) bool {
+	var aerr thrift.TApplicationException
+
+	return errors.As(err, &aerr) && aerr.TypeId() == typeID
+}
+
+// IsUnauthenticated reports whether err is, or wraps, an UNAUTHENTICATED
+// TApplicationException. It holds on both sides of the connection.
+func IsUnauthenticated(err error) bool {
+	return isApplicationException(err, thrift.UNAUTHENTICATED)
+}
+
+// IsPermissionDenied reports whether err is, or wraps, a PERMISSION_DENIED
+// TApplicationException. It holds on both sides of the connection.
+func IsPermissionDenied(err error) bool {
+	return isApplicationException(err, thrift.PERMISSION_DENIED)
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/authorization"
+)
+
+type builder struct {
+	authenticator Authenticator
+}
+
+// NewMiddlewareBuilder returns a server TMiddlewareBuilder authenticating the
+// calls with a and enforcing the authorization annotations of the functions
+// called. The calls of the functions not annotated with Public must be
+// authenticated, and their principal granted the scopes required by
+// RequireScopes. The calls whose function definition cannot be found are
+// denied. The principal is available to the handler through
+// PrincipalFromContext.
+func NewMiddlewareBuilder(a Authenticator) thrift.TMiddlewareBuilder {
+	return &builder{authenticator: a}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{authenticator: b.authenticator, service: namespace + "." + service}
+}
+
+type middleware struct {
+	authenticator Authenticator
+	service       string
+}
+
+type rule struct {
+	public bool
+	scopes []string
+}
+
+// lookupRule returns the rule of the function called, from the definition the
+// processor set on ctx or else from the definition of the service the
+// middleware was built for. It reports false when neither is known.
+func (m *middleware) lookupRule(ctx thrift.Context, mth string) (rule, bool) {
+	var (
+		sd thrift.ServiceDefinition
+		fd thrift.FunctionDefinition
+	)
+
+	if md, ok := thrift.GetMethodDefinition(ctx); ok {
+		sd, fd = md.Service, md.Function
+	} else if sd, ok = thrift.GetServiceDefinition(m.service); ok {
+		// An unknown function is still subject to the rule of its service.
+		fd, _ = sd.Function(mth)
+	} else {
+		return rule{}, false
+	}
+
+	return rule{
+		public: authorization.IsPublic(sd, fd),
+		scopes: authorization.RequiredScopes(sd, fd),
+	}, true
+}
+
+func (m *middleware) authorize(ctx thrift.Context, mth string) (thrift.Context, error) {
+	r, ok := m.lookupRule(ctx, mth)
+
+	if !ok {
+		return nil, NewPermissionDenied(mth + ": unknown function definition")
+	}
+
+	p, err := m.authenticator.Authenticate(ctx)
+
+	if r.public {
+		if err == nil && p != nil {
+			ctx = ContextWithPrincipal(ctx, p)
+		}
+
+		return ctx, nil
+	}
+
+	var aerr thrift.TApplicationException
+
+	switch {
+	case errors.As(err, &aerr):
+		return nil, err
+	case errors.Is(err, ErrNoCredentials):
+		return nil, NewUnauthenticated(mth + ": missing credentials")
+	case err != nil, p == nil:
+		return nil, NewUnauthenticated(mth + ": invalid credentials")
+	}
+
+	for _, s := range r.scopes {
+		if !p.HasScope(s) {
+			return nil, NewPermissionDenied(fmt.Sprintf("%s: missing scope %q", mth, s))
+		}
+	}
+
+	return ContextWithPrincipal(ctx, p), nil
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, err := m.authorize(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return next(ctx, req)
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	ctx, err := m.authorize(ctx, mth)
+
+	if err != nil {
+		return err
+	}
+
+	return next(ctx, req)
+}
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, err := m.authorize(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return next(ctx, req, s)
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, err := m.authorize(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return next(ctx, req, s)
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, err := m.authorize(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return next(ctx, req, is, os)
+}
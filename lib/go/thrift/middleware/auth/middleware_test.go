@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/authorization"
+)
+
+var testService = thrift.ServiceDefinition{
+	Namespace: "auth.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{
+		Name: "Service",
+		StructuredAnnotations: []thrift.RegistrableStruct{
+			&authorization.RequireScopes{Scopes: []string{"read"}},
+		},
+	},
+	Functions: []thrift.FunctionDefinition{
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "get"}},
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name: "update",
+				StructuredAnnotations: []thrift.RegistrableStruct{
+					&authorization.RequireScopes{Scopes: []string{"read", "write"}},
+				},
+			},
+		},
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "health",
+				StructuredAnnotations: []thrift.RegistrableStruct{&authorization.Public{}},
+			},
+		},
+	},
+}
+
+func init() {
+	thrift.RegisterService(testService)
+}
+
+var tokens = map[string]*Principal{
+	"reader": {Name: "reader", Scopes: []string{"read"}},
+	"writer": {Name: "writer", Scopes: []string{"read", "write"}},
+}
+
+func verifyToken(_ thrift.Context, token string) (*Principal, error) {
+	if p, ok := tokens[token]; ok {
+		return p, nil
+	}
+
+	return nil, errors.New("unknown token")
+}
+
+func tokenContext(token string) thrift.Context {
+	if token == "" {
+		return context.Background()
+	}
+
+	return thrift.AddReadTHeaderToContext(
+		context.Background(),
+		thrift.THeaderMap{DefaultHeader: "Bearer " + token},
+	)
+}
+
+func TestMiddleware(t *testing.T) {
+	m := NewMiddlewareBuilder(NewHeaderAuthenticator("", verifyToken)).Build("auth.test", "Service")
+
+	for _, tt := range []struct {
+		mth   string
+		token string
+
+		wantErr       func(error) bool
+		wantPrincipal string
+	}{
+		{mth: "get", wantErr: IsUnauthenticated},
+		{mth: "get", token: "forged", wantErr: IsUnauthenticated},
+		{mth: "get", token: "reader", wantPrincipal: "reader"},
+		{mth: "update", token: "reader", wantErr: IsPermissionDenied},
+		{mth: "update", token: "writer", wantPrincipal: "writer"},
+		{mth: "health"},
+		{mth: "health", token: "forged"},
+		{mth: "health", token: "reader", wantPrincipal: "reader"},
+		{mth: "unknown", token: "forged", wantErr: IsUnauthenticated},
+		{mth: "unknown", token: "reader", wantPrincipal: "reader"},
+	} {
+		var principal *Principal
+
+		_, err := m.HandleBinaryRequest(
+			tokenContext(tt.token),
+			tt.mth,
+			1,
+			nil,
+			func(ctx thrift.Context, _ thrift.TRequest) (thrift.TResponse, error) {
+				principal = PrincipalFromContext(ctx)
+				return nil, nil
+			},
+		)
+
+		if tt.wantErr != nil {
+			assert.True(t, tt.wantErr(err), "%s %s: %v", tt.mth, tt.token, err)
+			continue
+		}
+
+		require.NoError(t, err)
+
+		if tt.wantPrincipal == "" {
+			assert.Nil(t, principal)
+		} else {
+			require.NotNil(t, principal)
+			assert.Equal(t, tt.wantPrincipal, principal.Name)
+		}
+	}
+}
+
+func TestMultiAuthenticator(t *testing.T) {
+	var (
+		errFailed = errors.New("failed")
+
+		a = MultiAuthenticator{
+			NewTLSAuthenticator(nil),
+			AuthenticatorFunc(func(thrift.Context) (*Principal, error) { return nil, ErrNoCredentials }),
+			AuthenticatorFunc(func(thrift.Context) (*Principal, error) { return nil, errFailed }),
+		}
+	)
+
+	_, err := a.Authenticate(context.Background())
+	assert.Equal(t, errFailed, err)
+
+	_, err = a[:2].Authenticate(context.Background())
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestMiddleware_UnknownService(t *testing.T) {
+	m := NewMiddlewareBuilder(NewHeaderAuthenticator("", verifyToken)).Build("auth.test", "Unknown")
+
+	call := func(ctx thrift.Context, mth string) error {
+		_, err := m.HandleBinaryRequest(
+			ctx,
+			mth,
+			1,
+			nil,
+			func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) { return nil, nil },
+		)
+
+		return err
+	}
+
+	// Without a definition for the function, the calls are denied.
+	for _, mth := range []string{"get", "health"} {
+		err := call(tokenContext("writer"), mth)
+		assert.True(t, IsPermissionDenied(err), "%s: %v", mth, err)
+	}
+
+	// The definition set by the processor prevails.
+	withDefinition := func(ctx thrift.Context, mth string) thrift.Context {
+		return thrift.WithMethod(ctx, testService, mth)
+	}
+
+	assert.NoError(t, call(withDefinition(context.Background(), "health"), "health"))
+	assert.NoError(t, call(withDefinition(tokenContext("writer"), "update"), "update"))
+	assert.True(t, IsPermissionDenied(call(withDefinition(tokenContext("reader"), "update"), "update")))
+}
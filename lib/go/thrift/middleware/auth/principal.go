@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// Principal is the identity a call was authenticated as.
+type Principal struct {
+	Name   string
+	Scopes []string
+
+	// Free form attributes set by the Authenticator.
+	Attributes map[string]string
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying p.
+func ContextWithPrincipal(ctx thrift.Context, p *Principal) thrift.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal the call was authenticated as,
+// nil for an anonymous call.
+func PrincipalFromContext(ctx thrift.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
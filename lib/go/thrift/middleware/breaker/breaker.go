@@ -0,0 +1,351 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultCoolDown         = 10 * time.Second
+	DefaultHalfOpenCalls    = 1
+)
+
+// ErrOpen is returned without calling the server while the breaker of the
+// method is open.
+var ErrOpen = errors.New("thrift: circuit breaker open")
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+type Options struct {
+	// Number of consecutive failures opening the breaker.
+	FailureThreshold int
+	// Time spent open before letting trial calls through.
+	CoolDown time.Duration
+	// Number of concurrent trial calls let through while half-open.
+	HalfOpenCalls int
+
+	// Defaults to IsFailure.
+	IsFailure func(error) bool
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.FailureThreshold == 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+
+	if opts.CoolDown == 0 {
+		opts.CoolDown = DefaultCoolDown
+	}
+
+	if opts.HalfOpenCalls == 0 {
+		opts.HalfOpenCalls = DefaultHalfOpenCalls
+	}
+
+	if opts.IsFailure == nil {
+		opts.IsFailure = IsFailure
+	}
+
+	return opts
+}
+
+// IsFailure reports whether err is a sign of an unhealthy dependency: a
+// transport error, a timeout or an internal error of the server. Declared
+// exceptions are never failures.
+func IsFailure(err error) bool {
+	var (
+		terr thrift.TTransportException
+		aerr thrift.TApplicationException
+	)
+
+	switch {
+	case err == nil:
+		return false
+	case errors.IsTimeout(err), errors.As(err, &terr):
+		return true
+	case errors.As(err, &aerr):
+		switch aerr.TypeId() {
+		case thrift.INTERNAL_ERROR, thrift.INTERNAL_TIME_OUT_ERROR:
+			return true
+		}
+	}
+
+	return false
+}
+
+// Breaker is the circuit breaker of a single method.
+type Breaker struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trials   int
+
+	// generation changes along with the state, the outcome of a call let
+	// through under a former state is ignored.
+	generation uint64
+}
+
+func newBreaker(opts Options) *Breaker {
+	return &Breaker{opts: opts, now: time.Now}
+}
+
+// State returns the current state of the breaker, an open breaker whose
+// cool-down is over is reported half-open.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refreshLocked()
+
+	return b.state
+}
+
+func (b *Breaker) refreshLocked() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.opts.CoolDown {
+		b.setStateLocked(HalfOpen)
+		b.trials = 0
+	}
+}
+
+func (b *Breaker) setStateLocked(s State) {
+	b.state = s
+	b.failures = 0
+	b.generation++
+}
+
+// allow returns the generation the call is let through under, to be passed
+// to report along with its outcome.
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refreshLocked()
+
+	switch b.state {
+	case Open:
+		return 0, ErrOpen
+	case HalfOpen:
+		if b.trials >= b.opts.HalfOpenCalls {
+			return 0, ErrOpen
+		}
+
+		b.trials++
+	}
+
+	return b.generation, nil
+}
+
+func (b *Breaker) report(generation uint64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	if !b.opts.IsFailure(err) {
+		if b.state == Closed {
+			b.failures = 0
+		} else {
+			b.setStateLocked(Closed)
+		}
+
+		return
+	}
+
+	b.failures++
+
+	if b.state == HalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.setStateLocked(Open)
+		b.openedAt = b.now()
+	}
+}
+
+// Middleware is a client side TStreamingMiddleware keeping a breaker per
+// method.
+type Middleware struct {
+	opts Options
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewMiddleware(opts Options) *Middleware {
+	return &Middleware{opts: opts.withDefaults(), breakers: make(map[string]*Breaker)}
+}
+
+// Breaker returns the breaker of the given method.
+func (m *Middleware) Breaker(mth string) *Breaker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.breakers[mth]
+
+	if !ok {
+		b = newBreaker(m.opts)
+		m.breakers[mth] = b
+	}
+
+	return b
+}
+
+// States returns the state of the breaker of every method called so far.
+func (m *Middleware) States() map[string]State {
+	m.mu.Lock()
+
+	bs := make(map[string]*Breaker, len(m.breakers))
+
+	for mth, b := range m.breakers {
+		bs[mth] = b
+	}
+
+	m.mu.Unlock()
+
+	res := make(map[string]State, len(bs))
+
+	for mth, b := range bs {
+		res[mth] = b.State()
+	}
+
+	return res
+}
+
+func (m *Middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	b := m.Breaker(mth)
+
+	generation, err := b.allow()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req)
+	b.report(generation, err)
+
+	return res, err
+}
+
+func (m *Middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	b := m.Breaker(mth)
+
+	generation, err := b.allow()
+
+	if err != nil {
+		return err
+	}
+
+	err = next(ctx, req)
+	b.report(generation, err)
+
+	return err
+}
+
+func (m *Middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	b := m.Breaker(mth)
+
+	generation, err := b.allow()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, s)
+	b.report(generation, err)
+
+	return res, err
+}
+
+func (m *Middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	b := m.Breaker(mth)
+
+	generation, err := b.allow()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, s)
+	b.report(generation, err)
+
+	return res, err
+}
+
+func (m *Middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	b := m.Breaker(mth)
+
+	generation, err := b.allow()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, is, os)
+	b.report(generation, err)
+
+	return res, err
+}
+
+// Builder is a TMiddlewareBuilder building one Middleware, hence one set of
+// breakers, per service.
+type Builder struct {
+	opts Options
+
+	mu          sync.Mutex
+	middlewares map[string]*Middleware
+}
+
+func NewMiddlewareBuilder(opts Options) *Builder {
+	return &Builder{opts: opts, middlewares: make(map[string]*Middleware)}
+}
+
+func (b *Builder) Build(namespace, service string) thrift.TMiddleware {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	k := namespace + "." + service
+	m, ok := b.middlewares[k]
+
+	if !ok {
+		m = NewMiddleware(b.opts)
+		b.middlewares[k] = m
+	}
+
+	return m
+}
+
+// Middleware returns the middleware built for the given service, if any.
+func (b *Builder) Middleware(namespace, service string) (*Middleware, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, ok := b.middlewares[namespace+"."+service]
+
+	return m, ok
+}
@@ -0,0 +1,148 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+func call(m *Middleware, mth string, err error) error {
+	_, rerr := m.HandleBinaryRequest(
+		context.Background(),
+		mth,
+		0,
+		nil,
+		func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+			return nil, err
+		},
+	)
+
+	return rerr
+}
+
+func TestMiddleware_Lifecycle(t *testing.T) {
+	var (
+		now  = time.Now()
+		m    = NewMiddleware(Options{FailureThreshold: 2, CoolDown: time.Minute})
+		b    = m.Breaker("foo")
+		terr = thrift.NewTTransportException(thrift.NOT_OPEN, "broken")
+	)
+
+	b.now = func() time.Time { return now }
+
+	assert.Equal(t, terr, call(m, "foo", terr))
+	assert.Equal(t, Closed, b.State())
+
+	assert.Equal(t, terr, call(m, "foo", terr))
+	assert.Equal(t, Open, b.State())
+
+	assert.Equal(t, ErrOpen, call(m, "foo", nil))
+	assert.NoError(t, call(m, "bar", nil))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, HalfOpen, b.State())
+
+	assert.Equal(t, terr, call(m, "foo", terr))
+	assert.Equal(t, Open, b.State())
+
+	now = now.Add(time.Minute)
+
+	assert.NoError(t, call(m, "foo", nil))
+	assert.Equal(t, Closed, b.State())
+
+	assert.Equal(
+		t,
+		map[string]State{"foo": Closed, "bar": Closed},
+		m.States(),
+	)
+}
+
+func TestMiddleware_HalfOpenTrials(t *testing.T) {
+	var (
+		now = time.Now()
+		m   = NewMiddleware(Options{FailureThreshold: 1, CoolDown: time.Minute})
+		b   = m.Breaker("foo")
+	)
+
+	b.now = func() time.Time { return now }
+
+	call(m, "foo", thrift.NewTTransportException(thrift.NOT_OPEN, "broken"))
+	now = now.Add(time.Minute)
+
+	_, err := b.allow()
+	assert.NoError(t, err)
+
+	_, err = b.allow()
+	assert.Equal(t, ErrOpen, err)
+}
+
+func TestMiddleware_LateOutcomes(t *testing.T) {
+	var (
+		now  = time.Now()
+		m    = NewMiddleware(Options{FailureThreshold: 1, CoolDown: time.Minute})
+		b    = m.Breaker("foo")
+		terr = thrift.NewTTransportException(thrift.NOT_OPEN, "broken")
+	)
+
+	b.now = func() time.Time { return now }
+
+	late, err := b.allow()
+	assert.NoError(t, err)
+
+	assert.Equal(t, terr, call(m, "foo", terr))
+	assert.Equal(t, Open, b.State())
+
+	// A success of a call let through before the breaker opened does not
+	// skip the cool-down.
+	b.report(late, nil)
+	assert.Equal(t, Open, b.State())
+
+	now = now.Add(time.Minute)
+
+	trial, err := b.allow()
+	assert.NoError(t, err)
+
+	b.report(late, terr)
+	assert.Equal(t, HalfOpen, b.State())
+
+	b.report(trial, nil)
+	assert.Equal(t, Closed, b.State())
+}
+
+func TestIsFailure(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil},
+		{err: errors.New("declared")},
+		{err: context.Canceled},
+		{err: context.DeadlineExceeded, want: true},
+		{err: thrift.NewTTransportException(thrift.TIMED_OUT, ""), want: true},
+		{err: thrift.NewTApplicationException(thrift.INTERNAL_ERROR, ""), want: true},
+		{err: thrift.NewTApplicationException(thrift.INTERNAL_TIME_OUT_ERROR, ""), want: true},
+		{err: thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, "")},
+	} {
+		assert.Equal(t, tt.want, IsFailure(tt.err), "%v", tt.err)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewMiddlewareBuilder(Options{})
+
+	m1 := b.Build("foo", "Foo")
+	m2 := b.Build("foo", "Foo")
+	m3 := b.Build("foo", "Bar")
+
+	assert.Same(t, m1, m2)
+	assert.NotSame(t, m1, m3)
+
+	m, ok := b.Middleware("foo", "Foo")
+	assert.True(t, ok)
+	assert.Same(t, m1, m)
+}
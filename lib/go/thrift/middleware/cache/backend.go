@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const DefaultSize = 1024
+
+// Backend stores the serialized responses. The errors it returns are treated
+// as misses by the middleware.
+type Backend interface {
+	Get(ctx thrift.Context, key string) ([]byte, bool, error)
+	Set(ctx thrift.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU is an in memory Backend bounded in number of entries, the least
+// recently used one is evicted to make room for a new one.
+type LRU struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// NewLRU returns a LRU holding up to size entries, DefaultSize when size is
+// not positive.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	return &LRU{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (l *LRU) Get(_ thrift.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	le := e.Value.(*lruEntry)
+
+	if !l.now().Before(le.expires) {
+		l.remove(e)
+		return nil, false, nil
+	}
+
+	l.order.MoveToFront(e)
+
+	return le.value, true, nil
+}
+
+func (l *LRU) Set(_ thrift.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	le := &lruEntry{key: key, value: value, expires: l.now().Add(ttl)}
+
+	if e, ok := l.entries[key]; ok {
+		e.Value = le
+		l.order.MoveToFront(e)
+
+		return nil
+	}
+
+	l.entries[key] = l.order.PushFront(le)
+
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+
+	return nil
+}
+
+// Len returns the number of entries held, expired ones included.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.order.Len()
+}
+
+func (l *LRU) remove(e *list.Element) {
+	l.order.Remove(e)
+	delete(l.entries, e.Value.(*lruEntry).key)
+}
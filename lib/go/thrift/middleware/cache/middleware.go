@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/internal/wire"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+const (
+	DefaultTTL = time.Minute
+
+	// DefaultCacheControlHeader is the THeader overriding the caching of a
+	// call. The caller can set it to "no-cache", to skip the cached response,
+	// or "no-store", to not cache the fresh one. The server can send it back
+	// with "no-store" or "max-age=<seconds>" to override the TTL.
+	DefaultCacheControlHeader = "thrift-cache-control"
+)
+
+type Options struct {
+	// Defaults to a LRU of DefaultSize entries.
+	Backend Backend
+	// TTL of the cached responses, unless the server overrides it.
+	TTL time.Duration
+
+	CacheControlHeader string
+
+	// THeaders the responses depend on, their values are part of the cache
+	// key. A response depending on the credentials of the caller must list
+	// the header carrying them, it would be served to other callers
+	// otherwise.
+	Vary []string
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Backend == nil {
+		opts.Backend = NewLRU(DefaultSize)
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	if opts.CacheControlHeader == "" {
+		opts.CacheControlHeader = DefaultCacheControlHeader
+	}
+
+	return opts
+}
+
+type cacheControl struct {
+	noCache bool
+	noStore bool
+
+	maxAge    time.Duration
+	hasMaxAge bool
+}
+
+func parseCacheControl(v string) cacheControl {
+	var cc cacheControl
+
+	for _, d := range strings.Split(v, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+
+		switch {
+		case d == "no-cache":
+			cc.noCache = true
+		case d == "no-store":
+			cc.noStore = true
+		case strings.HasPrefix(d, "max-age="):
+			if s, err := strconv.Atoi(d[8:]); err == nil && s >= 0 {
+				cc.maxAge, cc.hasMaxAge = time.Duration(s)*time.Second, true
+			}
+		}
+	}
+
+	return cc
+}
+
+// SetCacheControl sets, from a handler, the cache control directives sent
+// back to the caching clients along with the response of the call.
+func SetCacheControl(ctx thrift.Context, directives string) bool {
+	return thrift.SetResponseHeader(ctx, DefaultCacheControlHeader, directives)
+}
+
+type builder struct {
+	opts Options
+}
+
+// NewClientMiddlewareBuilder returns a client TMiddlewareBuilder caching the
+// responses of the ReadOnly functions, keyed by method and by the canonical
+// serialization of the request, along with the values of the Vary headers.
+// Identical calls running concurrently are coalesced into a single one. The
+// responses carrying an exception are not cached.
+func NewClientMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	return &builder{opts: opts.withDefaults()}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{
+		opts:    b.opts,
+		prefix:  namespace + "." + service + ".",
+		flights: make(map[string]*flight),
+	}
+}
+
+type flight struct {
+	done chan struct{}
+
+	data []byte
+	err  error
+}
+
+type middleware struct {
+	thrift.TNopMiddleware
+
+	opts   Options
+	prefix string
+
+	// types holds the type of the responses of every method, learned from
+	// the first call made, to decode the cached ones.
+	types sync.Map
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func (m *middleware) key(ctx thrift.Context, mth string, req thrift.TRequest) (string, error) {
+	b, err := wire.CanonicalBytes(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(b)
+
+	for _, k := range m.opts.Vary {
+		// Length prefixed so that an absent header differs from an empty
+		// one and the values can not run into each other.
+		if v, ok := thrift.GetHeader(ctx, k); ok {
+			h.Write([]byte(strconv.Itoa(len(v)) + ":" + v))
+		} else {
+			h.Write([]byte("-"))
+		}
+	}
+
+	return m.prefix + mth + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *middleware) decode(mth string, data []byte) (thrift.TResponse, bool) {
+	t, ok := m.types.Load(mth)
+
+	if !ok {
+		return nil, false
+	}
+
+	res := reflect.New(t.(reflect.Type).Elem()).Interface().(thrift.TResponse)
+
+	if err := thrift.NewTDeserializer().Read(res, data); err != nil {
+		return nil, false
+	}
+
+	return res, true
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	md, ok := thrift.GetMethodDefinition(ctx)
+
+	if !ok || !rpc.IsReadOnly(md.Service, md.Function) {
+		return next(ctx, req)
+	}
+
+	k, err := m.key(ctx, mth, req)
+
+	if err != nil {
+		return next(ctx, req)
+	}
+
+	v, _ := thrift.GetHeader(ctx, m.opts.CacheControlHeader)
+	cc := parseCacheControl(v)
+
+	if !cc.noCache {
+		if data, ok, err := m.opts.Backend.Get(ctx, k); err == nil && ok {
+			if res, ok := m.decode(mth, data); ok {
+				return res, nil
+			}
+		}
+	}
+
+	for {
+		m.mu.Lock()
+		f, ok := m.flights[k]
+
+		if !ok {
+			f = &flight{done: make(chan struct{})}
+			m.flights[k] = f
+			m.mu.Unlock()
+
+			return m.lead(ctx, f, k, mth, req, cc, next)
+		}
+
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.done:
+		}
+
+		// The call of the leader was canceled along with its own context,
+		// this one is still worth running.
+		if errors.Is(f.err, context.Canceled) || errors.Is(f.err, context.DeadlineExceeded) {
+			continue
+		}
+
+		if f.err != nil {
+			return nil, f.err
+		}
+
+		if res, ok := m.decode(mth, f.data); ok {
+			return res, nil
+		}
+
+		return next(ctx, req)
+	}
+}
+
+// lead runs the call of a flight and caches its response.
+func (m *middleware) lead(ctx thrift.Context, f *flight, k, mth string, req thrift.TRequest, cc cacheControl, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.flights, k)
+		m.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	cctx, hs := thrift.WithResponseHeaders(ctx)
+
+	res, err := next(cctx, req)
+
+	if f.err = err; err != nil || res == nil {
+		return res, err
+	}
+
+	if t := reflect.TypeOf(res); t.Kind() == reflect.Ptr {
+		m.types.LoadOrStore(mth, t)
+	}
+
+	data, err := thrift.NewTSerializer().Write(ctx, res)
+
+	if err != nil {
+		return res, nil
+	}
+
+	// The followers get the exception as well, it is just not cached.
+	f.data = data
+
+	if res.GetError() != nil {
+		return res, nil
+	}
+
+	v, _ := hs.Get(m.opts.CacheControlHeader)
+	scc := parseCacheControl(v)
+
+	ttl := m.opts.TTL
+
+	if scc.hasMaxAge {
+		ttl = scc.maxAge
+	}
+
+	if !cc.noStore && !scc.noStore && ttl > 0 {
+		m.opts.Backend.Set(ctx, k, data, ttl) //nolint:errcheck
+	}
+
+	return res, nil
+}
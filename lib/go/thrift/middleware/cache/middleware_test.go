@@ -0,0 +1,321 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+type getArgs struct {
+	Tags   map[string]map[string]bool
+	IDs    []int64
+	Unique []string
+}
+
+func (a *getArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("get_args")
+
+	p.WriteFieldBegin("tags", thrift.MAP, 1)
+	p.WriteMapBegin(thrift.STRING, thrift.MAP, len(a.Tags))
+
+	for k, vs := range a.Tags {
+		p.WriteString(k)
+		p.WriteMapBegin(thrift.STRING, thrift.BOOL, len(vs))
+
+		for k, v := range vs {
+			p.WriteString(k)
+			p.WriteBool(v)
+		}
+
+		p.WriteMapEnd()
+	}
+
+	p.WriteMapEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldBegin("ids", thrift.LIST, 2)
+	p.WriteListBegin(thrift.I64, len(a.IDs))
+
+	for _, id := range a.IDs {
+		p.WriteI64(id)
+	}
+
+	p.WriteListEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldBegin("unique", thrift.SET, 3)
+	p.WriteSetBegin(thrift.STRING, len(a.Unique))
+
+	for _, v := range a.Unique {
+		p.WriteString(v)
+	}
+
+	p.WriteSetEnd()
+	p.WriteFieldEnd()
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (a *getArgs) Read(p thrift.TProtocol) error { return p.Skip(thrift.STRUCT) }
+func (a *getArgs) String() string                { return fmt.Sprintf("%+v", *a) }
+
+type getResult struct {
+	Success *string
+}
+
+func (r *getResult) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("get_result")
+
+	if r.Success != nil {
+		p.WriteFieldBegin("success", thrift.STRING, 0)
+		p.WriteString(*r.Success)
+		p.WriteFieldEnd()
+	}
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (r *getResult) Read(p thrift.TProtocol) error {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typeID == thrift.STOP {
+			break
+		}
+
+		if id == 0 && typeID == thrift.STRING {
+			v, err := p.ReadString()
+
+			if err != nil {
+				return err
+			}
+
+			r.Success = &v
+		} else if err := p.Skip(typeID); err != nil {
+			return err
+		}
+
+		if err := p.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return p.ReadStructEnd()
+}
+
+func (r *getResult) String() string         { return fmt.Sprintf("%+v", *r) }
+func (r *getResult) GetResult() interface{} { return r.Success }
+func (r *getResult) GetError() error        { return nil }
+
+var testService = thrift.ServiceDefinition{
+	Functions: []thrift.FunctionDefinition{
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "get",
+				StructuredAnnotations: []thrift.RegistrableStruct{&rpc.ReadOnly{}},
+			},
+		},
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "update"}},
+	},
+}
+
+type backend struct {
+	calls int32
+	value string
+
+	// headers are sent back by the backend along with its responses.
+	headers thrift.THeaderMap
+}
+
+func (b *backend) next(ctx thrift.Context, _ thrift.TRequest) (thrift.TResponse, error) {
+	atomic.AddInt32(&b.calls, 1)
+
+	if hs, ok := thrift.GetResponseHeaders(ctx); ok {
+		for k, v := range b.headers {
+			hs.Set(k, v)
+		}
+	}
+
+	v := b.value
+
+	return &getResult{Success: &v}, nil
+}
+
+func call(t *testing.T, m thrift.TMiddleware, ctx thrift.Context, mth string, b *backend) string {
+	t.Helper()
+
+	res, err := m.HandleBinaryRequest(ctx, mth, 1, &getArgs{IDs: []int64{1}}, b.next)
+	require.NoError(t, err)
+
+	return *res.(*getResult).Success
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		mth      string
+		ctx      func(thrift.Context) thrift.Context
+		headers  thrift.THeaderMap
+		wantHits bool
+	}{
+		{name: "read only", mth: "get", wantHits: true},
+		{name: "not read only", mth: "update"},
+		{
+			name: "no-cache",
+			mth:  "get",
+			ctx: func(ctx thrift.Context) thrift.Context {
+				return thrift.SetHeader(ctx, DefaultCacheControlHeader, "no-cache")
+			},
+		},
+		{
+			name:    "server no-store",
+			mth:     "get",
+			headers: thrift.THeaderMap{DefaultCacheControlHeader: "no-store"},
+		},
+		{
+			name:    "server max-age=0",
+			mth:     "get",
+			headers: thrift.THeaderMap{DefaultCacheControlHeader: "max-age=0"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				m = NewClientMiddlewareBuilder(Options{}).Build("cache.test", "Service")
+				b = backend{value: "foo", headers: tt.headers}
+
+				ctx = thrift.WithMethod(context.Background(), testService, tt.mth)
+			)
+
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+
+			assert.Equal(t, "foo", call(t, m, ctx, tt.mth, &b))
+
+			b.value = "bar"
+
+			if tt.wantHits {
+				assert.Equal(t, "foo", call(t, m, ctx, tt.mth, &b))
+				assert.Equal(t, int32(1), b.calls)
+			} else {
+				assert.Equal(t, "bar", call(t, m, ctx, tt.mth, &b))
+				assert.Equal(t, int32(2), b.calls)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Vary(t *testing.T) {
+	var (
+		m = NewClientMiddlewareBuilder(
+			Options{Vary: []string{"authorization"}},
+		).Build("cache.test", "Service")
+		b = backend{value: "foo"}
+
+		ctx = thrift.WithMethod(context.Background(), testService, "get")
+		foo = thrift.SetHeader(ctx, "authorization", "foo")
+	)
+
+	assert.Equal(t, "foo", call(t, m, foo, "get", &b))
+
+	b.value = "bar"
+
+	assert.Equal(t, "foo", call(t, m, foo, "get", &b))
+	assert.Equal(t, "bar", call(t, m, thrift.SetHeader(ctx, "authorization", "bar"), "get", &b))
+	assert.Equal(t, "bar", call(t, m, ctx, "get", &b))
+	assert.Equal(t, int32(3), b.calls)
+}
+
+func TestMiddleware_SingleFlight(t *testing.T) {
+	var (
+		m = NewClientMiddlewareBuilder(Options{}).Build("cache.test", "Service")
+
+		release = make(chan struct{})
+		calls   int32
+		wg      sync.WaitGroup
+	)
+
+	next := func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		v := "foo"
+
+		return &getResult{Success: &v}, nil
+	}
+
+	results := make([]thrift.TResponse, 8)
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			res, err := m.HandleBinaryRequest(thrift.WithMethod(context.Background(), testService, "get"), "get", 1, &getArgs{}, next)
+			assert.NoError(t, err)
+
+			results[i] = res
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls)
+
+	for _, res := range results {
+		assert.Equal(t, "foo", *res.(*getResult).Success)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		l   = NewLRU(2)
+	)
+
+	l.now = func() time.Time { return now }
+
+	l.Set(ctx, "a", []byte("a"), time.Minute)
+	l.Set(ctx, "b", []byte("b"), time.Second)
+
+	_, ok, _ := l.Get(ctx, "a")
+	assert.True(t, ok)
+
+	// b is the least recently used entry.
+	l.Set(ctx, "c", []byte("c"), time.Minute)
+
+	_, ok, _ = l.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, l.Len())
+
+	now = now.Add(2 * time.Minute)
+
+	_, ok, _ = l.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, l.Len())
+}
@@ -0,0 +1,240 @@
+package deprecation
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/deprecation"
+)
+
+const maxDepth = 32
+
+var registrableStructType = reflect.TypeOf((*thrift.RegistrableStruct)(nil)).Elem()
+
+// IsDeprecated reports whether def is annotated with deprecation.Deprecated.
+func IsDeprecated(def thrift.AnnotatedDefinition) bool {
+	return thrift.HasStructuredAnnotation(def, &deprecation.Deprecated{})
+}
+
+type fieldPlan struct {
+	index      int
+	name       string
+	deprecated bool
+	descend    bool
+}
+
+type structPlan struct {
+	canonicalName string
+	deprecated    bool
+	fields        []fieldPlan
+}
+
+// inspector finds the deprecated structs and fields set in a request. The
+// layout of every struct type met is computed once from its definition.
+type inspector struct {
+	plans    sync.Map
+	carriers sync.Map
+}
+
+func (i *inspector) plan(t reflect.Type) *structPlan {
+	if p, ok := i.plans.Load(t); ok {
+		return p.(*structPlan)
+	}
+
+	var (
+		p   structPlan
+		def thrift.StructDefinition
+	)
+
+	if reflect.PtrTo(t).Implements(registrableStructType) {
+		def = reflect.New(t).Interface().(thrift.RegistrableStruct).StructDefinition()
+
+		p.canonicalName = def.CanonicalName()
+		p.deprecated = IsDeprecated(def.AnnotatedDefinition)
+	}
+
+	for j := 0; j < t.NumField(); j++ {
+		sf := t.Field(j)
+		tag := sf.Tag.Get("thrift")
+
+		if tag == "" {
+			continue
+		}
+
+		fp := fieldPlan{
+			index:   j,
+			name:    strings.SplitN(tag, ",", 2)[0],
+			descend: len(structTypes(sf.Type)) > 0,
+		}
+
+		for _, fd := range def.Fields {
+			if fd.Name == fp.name {
+				fp.deprecated = IsDeprecated(fd.AnnotatedDefinition)
+				break
+			}
+		}
+
+		if fp.deprecated || fp.descend {
+			p.fields = append(p.fields, fp)
+		}
+	}
+
+	v, _ := i.plans.LoadOrStore(t, &p)
+	return v.(*structPlan)
+}
+
+// structTypes returns the struct types a value of type t can hold, through
+// pointers, lists, sets and maps.
+func structTypes(t reflect.Type) []reflect.Type {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return structTypes(t.Elem())
+	case reflect.Map:
+		return append(structTypes(t.Key()), structTypes(t.Elem())...)
+	case reflect.Struct:
+		return []reflect.Type{t}
+	}
+
+	return nil
+}
+
+// mayCarry reports whether a request of type t can hold any deprecated struct
+// or field, the requests which cannot are not walked.
+func (i *inspector) mayCarry(t reflect.Type) bool {
+	if v, ok := i.carriers.Load(t); ok {
+		return v.(bool)
+	}
+
+	var ok bool
+
+	for _, st := range structTypes(t) {
+		if i.reaches(st, make(map[reflect.Type]bool)) {
+			ok = true
+			break
+		}
+	}
+
+	i.carriers.Store(t, ok)
+
+	return ok
+}
+
+func (i *inspector) reaches(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+
+	seen[t] = true
+
+	p := i.plan(t)
+
+	if p.deprecated {
+		return true
+	}
+
+	for _, f := range p.fields {
+		if f.deprecated {
+			return true
+		}
+
+		for _, st := range structTypes(t.Field(f.index).Type) {
+			if i.reaches(st, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// inspect returns the deprecated elements set in the request of the method
+// mth, sorted.
+func (i *inspector) inspect(mth string, req thrift.TRequest) []string {
+	if req == nil || !i.mayCarry(reflect.TypeOf(req)) {
+		return nil
+	}
+
+	w := walker{inspector: i, method: mth, seen: make(map[string]bool)}
+	w.value(reflect.ValueOf(req), 0, true)
+
+	sort.Strings(w.elements)
+
+	return w.elements
+}
+
+type walker struct {
+	*inspector
+
+	method   string
+	seen     map[string]bool
+	elements []string
+}
+
+func (w *walker) add(e string) {
+	if !w.seen[e] {
+		w.seen[e] = true
+		w.elements = append(w.elements, e)
+	}
+}
+
+func (w *walker) value(v reflect.Value, depth int, args bool) {
+	if depth > maxDepth {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			w.value(v.Elem(), depth, args)
+		}
+	case reflect.Slice, reflect.Array:
+		for j := 0; j < v.Len(); j++ {
+			w.value(v.Index(j), depth+1, false)
+		}
+	case reflect.Map:
+		keys := len(structTypes(v.Type().Key())) > 0
+
+		for it := v.MapRange(); it.Next(); {
+			if keys {
+				w.value(it.Key(), depth+1, false)
+			}
+
+			w.value(it.Value(), depth+1, false)
+		}
+	case reflect.Struct:
+		w.structValue(v, depth, args)
+	}
+}
+
+// structValue walks the fields set of v, args tells v is the arguments
+// struct of the method whose fields are reported as arguments.
+func (w *walker) structValue(v reflect.Value, depth int, args bool) {
+	p := w.plan(v.Type())
+
+	if p.deprecated && !args {
+		w.add("struct " + p.canonicalName)
+	}
+
+	for _, f := range p.fields {
+		fv := v.Field(f.index)
+
+		if fv.IsZero() {
+			continue
+		}
+
+		if f.deprecated {
+			if args {
+				w.add("argument " + w.method + "." + f.name)
+			} else {
+				w.add("field " + p.canonicalName + "." + f.name)
+			}
+		}
+
+		if f.descend {
+			w.value(fv, depth+1, false)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package deprecation
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const (
+	DefaultCallerHeader = thrift.THeaderCallerKey
+
+	// WarningHeader is the THeader listing the deprecated elements used by a
+	// call, sent back along with its response.
+	WarningHeader = "thrift-deprecation"
+)
+
+type Options struct {
+	// Records the usages detected, nil to only warn the callers.
+	Recorder Recorder
+
+	// THeader identifying the caller.
+	CallerHeader string
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.CallerHeader == "" {
+		opts.CallerHeader = DefaultCallerHeader
+	}
+
+	return opts
+}
+
+type builder struct {
+	opts      Options
+	inspector *inspector
+}
+
+// NewServerMiddlewareBuilder returns a server TMiddlewareBuilder detecting the
+// calls using deprecated elements of the services it is built for: a
+// deprecated service or function, or a deprecated struct or field set in the
+// request. Every usage is recorded per caller and the deprecated elements are
+// listed to the client in the WarningHeader of the response.
+func NewServerMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	return &builder{opts: opts.withDefaults(), inspector: &inspector{}}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	m := &middleware{
+		builder:   b,
+		service:   namespace + "." + service,
+		functions: make(map[string][]string),
+	}
+
+	sd, ok := thrift.GetServiceDefinition(m.service)
+
+	if !ok {
+		return m
+	}
+
+	var deprecated []string
+
+	if IsDeprecated(sd.AnnotatedDefinition) {
+		deprecated = []string{"service " + sd.CanonicalName()}
+	}
+
+	for _, fd := range sd.Functions {
+		es := deprecated
+
+		if IsDeprecated(fd.AnnotatedDefinition) {
+			es = append(es[:len(es):len(es)], "function "+service+"."+fd.Name)
+		}
+
+		m.functions[fd.Name] = es
+	}
+
+	return m
+}
+
+type middleware struct {
+	*builder
+
+	service   string
+	functions map[string][]string
+}
+
+func (m *middleware) inspect(ctx thrift.Context, mth string, req thrift.TRequest) {
+	es := m.functions[mth]
+
+	if res := m.inspector.inspect(mth, req); len(res) > 0 {
+		es = append(es[:len(es):len(es)], res...)
+	}
+
+	if len(es) == 0 {
+		return
+	}
+
+	if m.opts.Recorder != nil {
+		caller, _ := thrift.GetHeader(ctx, m.opts.CallerHeader)
+
+		for _, e := range es {
+			m.opts.Recorder.Record(
+				Usage{Caller: caller, Service: m.service, Method: mth, Element: e},
+			)
+		}
+	}
+
+	thrift.SetResponseHeader(ctx, WarningHeader, strings.Join(es, ", "))
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	m.inspect(ctx, mth, req)
+	return next(ctx, req)
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	m.inspect(ctx, mth, req)
+	return next(ctx, req)
+}
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	m.inspect(ctx, mth, req)
+	return next(ctx, req, s)
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	m.inspect(ctx, mth, req)
+	return next(ctx, req, s)
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	m.inspect(ctx, mth, req)
+	return next(ctx, req, is, os)
+}
+
+// Logger reports the deprecation warning received for a call.
+type Logger func(service, method, warning string)
+
+// DefaultLogger logs the warnings with the standard logger.
+func DefaultLogger(service, method, warning string) {
+	log.Printf("thrift: %s/%s: deprecated usage: %s", service, method, warning)
+}
+
+type clientBuilder struct {
+	logger Logger
+}
+
+// NewClientMiddlewareBuilder returns a client TMiddlewareBuilder reporting to
+// l, DefaultLogger when nil, the deprecation warnings sent back by the
+// servers. A given warning of a method is only reported once per client.
+func NewClientMiddlewareBuilder(l Logger) thrift.TMiddlewareBuilder {
+	if l == nil {
+		l = DefaultLogger
+	}
+
+	return &clientBuilder{logger: l}
+}
+
+func (b *clientBuilder) Build(namespace, service string) thrift.TMiddleware {
+	return &clientMiddleware{
+		logger:  b.logger,
+		service: namespace + "." + service,
+		seen:    make(map[string]bool),
+	}
+}
+
+type clientMiddleware struct {
+	logger  Logger
+	service string
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+// call runs next with a context collecting the response headers.
+func (m *clientMiddleware) call(ctx thrift.Context, mth string, next func(thrift.Context) error) error {
+	cctx, hs := thrift.WithResponseHeaders(ctx)
+
+	err := next(cctx)
+
+	if w, ok := hs.Get(WarningHeader); ok && w != "" {
+		m.report(mth, w)
+	}
+
+	return err
+}
+
+func (m *clientMiddleware) report(mth, w string) {
+	k := mth + "\x00" + w
+
+	m.mu.Lock()
+	seen := m.seen[k]
+	m.seen[k] = true
+	m.mu.Unlock()
+
+	if !seen {
+		m.logger(m.service, mth, w)
+	}
+}
+
+func (m *clientMiddleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	var res thrift.TResponse
+
+	err := m.call(ctx, mth, func(ctx thrift.Context) error {
+		var err error
+
+		res, err = next(ctx, req)
+		return err
+	})
+
+	return res, err
+}
+
+func (m *clientMiddleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	// Oneway calls get no response to carry a warning back.
+	return next(ctx, req)
+}
+
+func (m *clientMiddleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	var res thrift.TResponse
+
+	err := m.call(ctx, mth, func(ctx thrift.Context) error {
+		var err error
+
+		res, err = next(ctx, req, s)
+		return err
+	})
+
+	return res, err
+}
+
+func (m *clientMiddleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	var res thrift.TResponse
+
+	err := m.call(ctx, mth, func(ctx thrift.Context) error {
+		var err error
+
+		res, err = next(ctx, req, s)
+		return err
+	})
+
+	return res, err
+}
+
+func (m *clientMiddleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	var res thrift.TResponse
+
+	err := m.call(ctx, mth, func(ctx thrift.Context) error {
+		var err error
+
+		res, err = next(ctx, req, is, os)
+		return err
+	})
+
+	return res, err
+}
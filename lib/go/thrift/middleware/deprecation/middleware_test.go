@@ -0,0 +1,246 @@
+package deprecation
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/deprecation"
+)
+
+type testStruct struct{}
+
+func (testStruct) Write(p thrift.TProtocol) error {
+	if err := p.WriteStructBegin("test"); err != nil {
+		return err
+	}
+
+	if err := p.WriteFieldStop(); err != nil {
+		return err
+	}
+
+	return p.WriteStructEnd()
+}
+
+func (testStruct) Read(p thrift.TProtocol) error { return p.Skip(thrift.STRUCT) }
+func (testStruct) String() string                { return "test" }
+func (testStruct) GetResult() interface{}        { return nil }
+func (testStruct) GetError() error               { return nil }
+
+func annotated(name string, deprecated bool) thrift.AnnotatedDefinition {
+	def := thrift.AnnotatedDefinition{Name: name}
+
+	if deprecated {
+		def.StructuredAnnotations = []thrift.RegistrableStruct{&deprecation.Deprecated{}}
+	}
+
+	return def
+}
+
+type Item struct {
+	testStruct
+
+	Name   string  `thrift:"name,1"`
+	Legacy *string `thrift:"legacy,2"`
+}
+
+func (*Item) StructDefinition() thrift.StructDefinition {
+	return thrift.StructDefinition{
+		Namespace:           "deprecation.test",
+		AnnotatedDefinition: annotated("Item", false),
+		Fields: []thrift.FieldDefinition{
+			{AnnotatedDefinition: annotated("name", false)},
+			{AnnotatedDefinition: annotated("legacy", true)},
+		},
+	}
+}
+
+type OldItem struct {
+	testStruct
+
+	ID int64 `thrift:"id,1"`
+}
+
+func (*OldItem) StructDefinition() thrift.StructDefinition {
+	return thrift.StructDefinition{
+		Namespace:           "deprecation.test",
+		AnnotatedDefinition: annotated("OldItem", true),
+		Fields:              []thrift.FieldDefinition{{AnnotatedDefinition: annotated("id", false)}},
+	}
+}
+
+type putArgs struct {
+	testStruct
+
+	Items []*Item               `thrift:"items,1"`
+	Old   map[string][]*OldItem `thrift:"old,2"`
+	Force *bool                 `thrift:"force,3"`
+}
+
+func (*putArgs) StructDefinition() thrift.StructDefinition {
+	return thrift.StructDefinition{
+		Namespace:           "deprecation.test",
+		AnnotatedDefinition: annotated("put_args", false),
+		Fields: []thrift.FieldDefinition{
+			{AnnotatedDefinition: annotated("items", false)},
+			{AnnotatedDefinition: annotated("old", false)},
+			{AnnotatedDefinition: annotated("force", true)},
+		},
+	}
+}
+
+type plainArgs struct {
+	testStruct
+
+	Name string `thrift:"name,1"`
+}
+
+func init() {
+	thrift.RegisterService(
+		thrift.ServiceDefinition{
+			Namespace:           "deprecation.test",
+			AnnotatedDefinition: annotated("Service", false),
+			Functions: []thrift.FunctionDefinition{
+				{AnnotatedDefinition: annotated("get", true)},
+				{AnnotatedDefinition: annotated("put", false)},
+			},
+		},
+	)
+}
+
+func TestInspector(t *testing.T) {
+	var (
+		i      inspector
+		force  = true
+		legacy = "x"
+	)
+
+	for _, tt := range []struct {
+		req  thrift.TRequest
+		want []string
+	}{
+		{req: &plainArgs{Name: "foo"}},
+		{req: &putArgs{Items: []*Item{{Name: "foo"}}}},
+		{
+			req: &putArgs{
+				Items: []*Item{{Name: "foo"}, {Legacy: &legacy}},
+				Old:   map[string][]*OldItem{"bar": {{ID: 1}}},
+				Force: &force,
+			},
+			want: []string{
+				"argument put.force",
+				"field deprecation.test.Item.legacy",
+				"struct deprecation.test.OldItem",
+			},
+		},
+	} {
+		assert.Equal(t, tt.want, i.inspect("put", tt.req))
+	}
+
+	assert.False(t, i.mayCarry(reflect.TypeOf(&plainArgs{})))
+	assert.True(t, i.mayCarry(reflect.TypeOf(&putArgs{})))
+}
+
+func TestMiddleware_Record(t *testing.T) {
+	var (
+		c = NewCounter()
+		m = NewServerMiddlewareBuilder(Options{Recorder: c}).Build("deprecation.test", "Service")
+
+		force = true
+		ctx   = thrift.AddReadTHeaderToContext(
+			context.Background(),
+			thrift.THeaderMap{DefaultCallerHeader: "billing"},
+		)
+	)
+
+	for _, mth := range []string{"get", "get", "put"} {
+		_, err := m.HandleBinaryRequest(
+			ctx,
+			mth,
+			1,
+			&putArgs{Force: &force},
+			func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) { return nil, nil },
+		)
+
+		require.NoError(t, err)
+	}
+
+	assert.Equal(
+		t,
+		map[Usage]int64{
+			{Caller: "billing", Service: "deprecation.test.Service", Method: "get", Element: "function Service.get"}: 2,
+			{Caller: "billing", Service: "deprecation.test.Service", Method: "get", Element: "argument get.force"}:   2,
+			{Caller: "billing", Service: "deprecation.test.Service", Method: "put", Element: "argument put.force"}:   1,
+		},
+		c.Usages(),
+	)
+}
+
+type handler struct{}
+
+func (handler) Handle(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+	return testStruct{}, nil
+}
+
+// TestMiddleware_Warning verifies that the warning of the server middleware
+// reaches the client middleware, which reports it once.
+func TestMiddleware_Warning(t *testing.T) {
+	pr1, pw1 := io.Pipe()
+	pr2, pw2 := io.Pipe()
+
+	defer pw2.Close()
+
+	var (
+		p = thrift.NewTStandardProcessor(
+			[]thrift.TMiddleware{
+				NewServerMiddlewareBuilder(Options{}).Build("deprecation.test", "Service"),
+			},
+		)
+		serverProt = thrift.NewTHeaderProtocol(thrift.NewStreamTransport(pr2, pw1))
+
+		warnings []string
+		client   = thrift.NewTSyncClient(
+			thrift.NewTHeaderTransport(thrift.NewStreamTransport(pr1, pw2)),
+			thrift.NewTHeaderProtocolFactory(),
+			NewClientMiddlewareBuilder(
+				func(_, mth, w string) { warnings = append(warnings, mth+": "+w) },
+			).Build("deprecation.test", "Service"),
+		)
+	)
+
+	for _, mth := range []string{"get", "put"} {
+		p.AddProcessor(
+			mth,
+			thrift.NewTBinaryProcessorFunction(
+				p,
+				mth,
+				func() thrift.TRequest { return testStruct{} },
+				handler{},
+			),
+		)
+	}
+
+	go func() {
+		for {
+			if ok, _ := p.Process(context.Background(), serverProt, serverProt); !ok {
+				return
+			}
+		}
+	}()
+
+	for _, mth := range []string{"get", "put", "get"} {
+		ctx, hs := thrift.WithResponseHeaders(context.Background())
+
+		require.NoError(t, client.CallBinary(ctx, mth, testStruct{}, testStruct{}))
+
+		_, ok := hs.Get(WarningHeader)
+		assert.Equal(t, mth == "get", ok)
+	}
+
+	assert.Equal(t, []string{"get: function Service.get"}, warnings)
+}
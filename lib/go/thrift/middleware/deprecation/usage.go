@@ -0,0 +1,61 @@
+package deprecation
+
+import "sync"
+
+// Usage is the use of a deprecated element by a caller.
+type Usage struct {
+	Caller  string
+	Service string
+	Method  string
+
+	// Element is the deprecated element used, like "function Service.method",
+	// "struct ns.Struct", "field ns.Struct.field" or "argument method.arg".
+	Element string
+}
+
+// Recorder records the usages detected by the server middleware.
+type Recorder interface {
+	Record(Usage)
+}
+
+type RecorderFunc func(Usage)
+
+func (fn RecorderFunc) Record(u Usage) { fn(u) }
+
+// Counter is a Recorder counting the usages.
+type Counter struct {
+	mu     sync.Mutex
+	counts map[Usage]int64
+}
+
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[Usage]int64)}
+}
+
+func (c *Counter) Record(u Usage) {
+	c.mu.Lock()
+	c.counts[u]++
+	c.mu.Unlock()
+}
+
+// Count returns the number of times u was recorded.
+func (c *Counter) Count(u Usage) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts[u]
+}
+
+// Usages returns a snapshot of the counts of every usage recorded.
+func (c *Counter) Usages() map[Usage]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	us := make(map[Usage]int64, len(c.counts))
+
+	for u, n := range c.counts {
+		us[u] = n
+	}
+
+	return us
+}
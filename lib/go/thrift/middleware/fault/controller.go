@@ -0,0 +1,162 @@
+package fault
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxConfigSize bounds the size of the configurations read from the admin
+// API.
+const maxConfigSize = 1 << 20
+
+// Controller holds the rules enabled at runtime, the middlewares built from
+// it pick their changes up on the next call. It is an http.Handler serving
+// the configuration on GET, replacing it on PUT or POST and disabling every
+// rule on DELETE.
+type Controller struct {
+	rand func() float64
+
+	mu     sync.RWMutex
+	config Config
+}
+
+// NewController returns a Controller with no rule enabled.
+func NewController() *Controller {
+	return &Controller{rand: rand.Float64}
+}
+
+// Config returns the configuration enabled.
+func (c *Controller) Config() Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Config{Rules: append([]Rule(nil), c.config.Rules...)}
+}
+
+// SetConfig enables the rules of cfg in place of the previous ones.
+func (c *Controller) SetConfig(cfg Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	cfg.Rules = append([]Rule(nil), cfg.Rules...)
+
+	c.mu.Lock()
+	c.config = cfg
+	c.mu.Unlock()
+
+	return nil
+}
+
+// LoadFile enables the rules of the JSON configuration file at path.
+func (c *Controller) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	var cfg Config
+
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	return c.SetConfig(cfg)
+}
+
+// WatchFile loads the configuration file at path and reloads it every time
+// its modification time changes, checking it every interval, until ctx is
+// done. A file failing to load keeps the previous rules enabled and is
+// reported to onError, if not nil.
+func (c *Controller) WatchFile(ctx context.Context, path string, interval time.Duration, onError func(error)) error {
+	var mtime time.Time
+
+	load := func() error {
+		fi, err := os.Stat(path)
+
+		if err != nil {
+			return err
+		}
+
+		if fi.ModTime().Equal(mtime) {
+			return nil
+		}
+
+		mtime = fi.ModTime()
+
+		return c.LoadFile(path)
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		if err := load(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var cfg Config
+
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxConfigSize)).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := c.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		c.SetConfig(Config{}) //nolint:errcheck
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Config()) //nolint:errcheck
+}
+
+// match returns the rule applying to a call, if any.
+func (c *Controller) match(service, mth, trigger string) (Rule, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, r := range c.config.Rules {
+		if !r.matches(service, mth, trigger) {
+			continue
+		}
+
+		if r.Rate > 0 && r.Rate < 1 && c.rand() >= r.Rate {
+			return Rule{}, false
+		}
+
+		return r, true
+	}
+
+	return Rule{}, false
+}
@@ -0,0 +1,295 @@
+package fault
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// DefaultTriggerHeader is the THeader matched against the Trigger of the
+// rules.
+const DefaultTriggerHeader = "thrift-fault-trigger"
+
+// ErrStreamAborted is the error of the streams aborted by a rule.
+var ErrStreamAborted = errors.New("fault: stream aborted")
+
+// WithTrigger returns a client context sending trigger along with the calls
+// made with it, for the rules restricted to it to apply.
+func WithTrigger(ctx thrift.Context, trigger string) thrift.Context {
+	keys := thrift.GetWriteHeaderList(ctx)
+
+	for _, k := range keys {
+		if k == DefaultTriggerHeader {
+			return thrift.SetHeader(ctx, DefaultTriggerHeader, trigger)
+		}
+	}
+
+	return thrift.SetWriteHeaderList(
+		thrift.SetHeader(ctx, DefaultTriggerHeader, trigger),
+		append(append([]string(nil), keys...), DefaultTriggerHeader),
+	)
+}
+
+type builder struct {
+	c *Controller
+}
+
+// NewMiddlewareBuilder returns a TMiddlewareBuilder injecting the faults
+// described by the rules enabled on c. It works on the client side, where
+// the faults never reach the server, as well as on the server side.
+func NewMiddlewareBuilder(c *Controller) thrift.TMiddlewareBuilder {
+	return &builder{c: c}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{c: b.c, service: service}
+}
+
+type middleware struct {
+	c       *Controller
+	service string
+
+	// types holds the type of the responses of every method, learned from
+	// the calls made, to build the ones carrying a declared exception when
+	// the result struct is not registered.
+	types sync.Map
+}
+
+func (m *middleware) rule(ctx thrift.Context, mth string) (Rule, bool) {
+	trigger, _ := thrift.GetHeader(ctx, DefaultTriggerHeader)
+
+	return m.c.match(m.service, mth, trigger)
+}
+
+func (m *middleware) learn(mth string, res thrift.TResponse) {
+	if t := reflect.TypeOf(res); t != nil && t.Kind() == reflect.Ptr {
+		m.types.LoadOrStore(mth, t.Elem())
+	}
+}
+
+func (m *middleware) resultType(ctx thrift.Context, mth string) (reflect.Type, bool) {
+	if md, ok := thrift.GetMethodDefinition(ctx); ok && md.Function.Result != nil {
+		if t, ok := thrift.StructType(md.Function.Result.CanonicalName()); ok {
+			return t, true
+		}
+	}
+
+	t, ok := m.types.Load(mth)
+
+	if !ok {
+		return nil, false
+	}
+
+	return t.(reflect.Type), true
+}
+
+// exception builds the response of mth carrying a zero value of its
+// declared exception named name.
+func (m *middleware) exception(ctx thrift.Context, mth, name string) (thrift.TResponse, error) {
+	t, ok := m.resultType(ctx, mth)
+
+	if !ok || t.Kind() != reflect.Struct {
+		return nil, thrift.NewTApplicationException(
+			thrift.INTERNAL_ERROR,
+			fmt.Sprintf("fault: unknown result type of %s", mth),
+		)
+	}
+
+	v := reflect.New(t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if tn, _, _ := strings.Cut(f.Tag.Get("thrift"), ","); tn != name || f.Type.Kind() != reflect.Ptr {
+			continue
+		}
+
+		v.Elem().Field(i).Set(reflect.New(f.Type.Elem()))
+
+		if res, ok := v.Interface().(thrift.TResponse); ok {
+			return res, nil
+		}
+	}
+
+	return nil, thrift.NewTApplicationException(
+		thrift.INTERNAL_ERROR,
+		fmt.Sprintf("fault: %s does not declare the exception %s", mth, name),
+	)
+}
+
+// inject applies the latency and the failure of r, it returns a nil
+// response and error when the call should proceed.
+func (m *middleware) inject(ctx thrift.Context, mth string, r Rule) (thrift.TResponse, error) {
+	if r.Latency > 0 {
+		t := time.NewTimer(time.Duration(r.Latency))
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	switch {
+	case r.ApplicationException != nil:
+		return nil, thrift.NewTApplicationException(
+			*r.ApplicationException,
+			"fault: injected application exception",
+		)
+	case r.Exception != "":
+		return m.exception(ctx, mth, r.Exception)
+	}
+
+	return nil, nil
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	if r, ok := m.rule(ctx, mth); ok {
+		if res, err := m.inject(ctx, mth, r); res != nil || err != nil {
+			return res, err
+		}
+	}
+
+	res, err := next(ctx, req)
+
+	if err == nil {
+		m.learn(mth, res)
+	}
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	r, ok := m.rule(ctx, mth)
+
+	if !ok {
+		return next(ctx, req)
+	}
+
+	if r.DropOneway {
+		return nil
+	}
+
+	// A oneway function declares no exception.
+	r.Exception = ""
+
+	if _, err := m.inject(ctx, mth, r); err != nil {
+		return err
+	}
+
+	return next(ctx, req)
+}
+
+func (m *middleware) stream(ctx thrift.Context, mth string, next func(*aborter) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	r, ok := m.rule(ctx, mth)
+
+	if !ok {
+		return next(nil)
+	}
+
+	if res, err := m.inject(ctx, mth, r); res != nil || err != nil {
+		return res, err
+	}
+
+	var a *aborter
+
+	if r.AbortStreamAfter > 0 {
+		a = &aborter{after: r.AbortStreamAfter}
+	}
+
+	return next(a)
+}
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	return m.stream(ctx, mth, func(a *aborter) (thrift.TResponse, error) {
+		if a != nil {
+			a.closers = []func() error{s.Close}
+			s = &abortingInboundStream{TInboundStream: s, a: a}
+		}
+
+		return next(ctx, req, s)
+	})
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	return m.stream(ctx, mth, func(a *aborter) (thrift.TResponse, error) {
+		if a != nil {
+			a.closers = []func() error{s.Close}
+			s = &abortingOutboundStream{TOutboundStream: s, a: a}
+		}
+
+		return next(ctx, req, s)
+	})
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	return m.stream(ctx, mth, func(a *aborter) (thrift.TResponse, error) {
+		if a != nil {
+			a.closers = []func() error{is.Close, os.Close}
+			is = &abortingInboundStream{TInboundStream: is, a: a}
+			os = &abortingOutboundStream{TOutboundStream: os, a: a}
+		}
+
+		return next(ctx, req, is, os)
+	})
+}
+
+// aborter counts the messages going through the streams of a call, in both
+// directions, and closes them once the limit is reached.
+type aborter struct {
+	after   int64
+	n       int64
+	closers []func() error
+
+	once sync.Once
+}
+
+func (a *aborter) check() error {
+	if atomic.AddInt64(&a.n, 1) <= a.after {
+		return nil
+	}
+
+	a.once.Do(func() {
+		for _, fn := range a.closers {
+			fn() //nolint:errcheck
+		}
+	})
+
+	return ErrStreamAborted
+}
+
+type abortingInboundStream struct {
+	thrift.TInboundStream
+
+	a *aborter
+}
+
+func (s *abortingInboundStream) Receive(ctx thrift.Context, msg thrift.TRequest) error {
+	if err := s.a.check(); err != nil {
+		return err
+	}
+
+	return s.TInboundStream.Receive(ctx, msg)
+}
+
+type abortingOutboundStream struct {
+	thrift.TOutboundStream
+
+	a *aborter
+}
+
+func (s *abortingOutboundStream) Send(ctx thrift.Context, msg thrift.TRequest) error {
+	if err := s.a.check(); err != nil {
+		return err
+	}
+
+	return s.TOutboundStream.Send(ctx, msg)
+}
@@ -0,0 +1,305 @@
+package fault
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+type notFound struct {
+	Key *string `thrift:"key,1" json:"key"`
+}
+
+func (e *notFound) Error() string { return "not found" }
+
+type getResult struct {
+	Success  *string   `thrift:"success,0" json:"success,omitempty"`
+	NotFound *notFound `thrift:"not_found,1" json:"not_found,omitempty"`
+}
+
+func (r *getResult) Write(p thrift.TProtocol) error { return nil }
+func (r *getResult) Read(p thrift.TProtocol) error  { return nil }
+func (r *getResult) String() string                 { return fmt.Sprintf("%+v", *r) }
+func (r *getResult) GetResult() interface{}         { return r.Success }
+
+func (r *getResult) GetError() error {
+	if r.NotFound != nil {
+		return r.NotFound
+	}
+
+	return nil
+}
+
+func next(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+	v := "foo"
+
+	return &getResult{Success: &v}, nil
+}
+
+func int32Ptr(v int32) *int32 { return &v }
+
+func build(t *testing.T, rules ...Rule) thrift.TStreamingMiddleware {
+	t.Helper()
+
+	c := NewController()
+	require.NoError(t, c.SetConfig(Config{Rules: rules}))
+
+	return NewMiddlewareBuilder(c).Build("fault.test", "Service").(thrift.TStreamingMiddleware)
+}
+
+func TestRule_Matches(t *testing.T) {
+	for _, tt := range []struct {
+		rule    Rule
+		mth     string
+		trigger string
+		want    bool
+	}{
+		{rule: Rule{}, mth: "get", want: true},
+		{rule: Rule{Method: "*"}, mth: "get", want: true},
+		{rule: Rule{Method: "get"}, mth: "get", want: true},
+		{rule: Rule{Method: "get"}, mth: "set"},
+		{rule: Rule{Method: "Service.get"}, mth: "get", want: true},
+		{rule: Rule{Method: "Service.*"}, mth: "get", want: true},
+		{rule: Rule{Method: "Other.get"}, mth: "get"},
+		{rule: Rule{Trigger: "ci"}, mth: "get"},
+		{rule: Rule{Trigger: "ci"}, mth: "get", trigger: "ci", want: true},
+	} {
+		assert.Equal(
+			t,
+			tt.want,
+			tt.rule.matches("Service", tt.mth, tt.trigger),
+			"%+v %s %s", tt.rule, tt.mth, tt.trigger,
+		)
+	}
+}
+
+func TestMiddleware_Binary(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		rule    Rule
+		timeout time.Duration
+		wantErr func(*testing.T, error)
+		wantRes func(*testing.T, thrift.TResponse)
+	}{
+		{
+			name: "no fault",
+			rule: Rule{Method: "set", Trigger: "ci", ApplicationException: int32Ptr(thrift.INTERNAL_ERROR)},
+			wantRes: func(t *testing.T, res thrift.TResponse) {
+				assert.Equal(t, "foo", *res.(*getResult).Success)
+			},
+		},
+		{
+			name: "application exception",
+			rule: Rule{Method: "get", Trigger: "ci", ApplicationException: int32Ptr(thrift.INTERNAL_ERROR)},
+			wantErr: func(t *testing.T, err error) {
+				var aerr thrift.TApplicationException
+
+				require.True(t, errors.As(err, &aerr))
+				assert.Equal(t, int32(thrift.INTERNAL_ERROR), aerr.TypeId())
+			},
+		},
+		{
+			name: "declared exception",
+			rule: Rule{Method: "get", Trigger: "ci", Exception: "not_found"},
+			wantRes: func(t *testing.T, res thrift.TResponse) {
+				assert.Equal(t, &notFound{}, res.GetError())
+			},
+		},
+		{
+			name: "undeclared exception",
+			rule: Rule{Method: "get", Trigger: "ci", Exception: "conflict"},
+			wantErr: func(t *testing.T, err error) {
+				var aerr thrift.TApplicationException
+
+				require.True(t, errors.As(err, &aerr))
+				assert.Equal(t, int32(thrift.INTERNAL_ERROR), aerr.TypeId())
+			},
+		},
+		{
+			name:    "latency",
+			rule:    Rule{Method: "get", Trigger: "ci", Latency: Duration(time.Hour)},
+			timeout: 10 * time.Millisecond,
+			wantErr: func(t *testing.T, err error) {
+				assert.Equal(t, context.DeadlineExceeded, err)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				m   = build(t, tt.rule)
+				ctx = context.Background()
+			)
+
+			// The first call is not triggered, it lets the middleware learn
+			// the result type of the method.
+			_, err := m.HandleBinaryRequest(ctx, "get", 1, nil, next)
+			require.NoError(t, err)
+
+			ctx = WithTrigger(ctx, "ci")
+
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			res, err := m.HandleBinaryRequest(ctx, "get", 2, nil, next)
+
+			if tt.wantErr != nil {
+				tt.wantErr(t, err)
+			} else {
+				require.NoError(t, err)
+				tt.wantRes(t, res)
+			}
+		})
+	}
+}
+
+func TestMiddleware_DropOneway(t *testing.T) {
+	var (
+		m     = build(t, Rule{Method: "notify", DropOneway: true})
+		calls int
+	)
+
+	next := func(thrift.Context, thrift.TRequest) error {
+		calls++
+		return nil
+	}
+
+	require.NoError(t, m.HandleUnaryRequest(context.Background(), "notify", 1, nil, next))
+	require.NoError(t, m.HandleUnaryRequest(context.Background(), "other", 1, nil, next))
+
+	assert.Equal(t, 1, calls)
+}
+
+type stream struct {
+	messages int
+	closed   bool
+}
+
+func (s *stream) Send(thrift.Context, thrift.TRequest) error    { s.messages++; return nil }
+func (s *stream) Receive(thrift.Context, thrift.TRequest) error { s.messages++; return nil }
+
+func (s *stream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestMiddleware_AbortStream(t *testing.T) {
+	var (
+		m      = build(t, Rule{Method: "chat", AbortStreamAfter: 3})
+		is, os stream
+	)
+
+	_, err := m.HandleBidiStream(
+		context.Background(),
+		"chat",
+		1,
+		nil,
+		&is,
+		&os,
+		func(ctx thrift.Context, _ thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream) (thrift.TResponse, error) {
+			for {
+				if err := is.Receive(ctx, nil); err != nil {
+					return nil, err
+				}
+
+				if err := os.Send(ctx, nil); err != nil {
+					return nil, err
+				}
+			}
+		},
+	)
+
+	assert.Equal(t, ErrStreamAborted, err)
+	assert.Equal(t, 3, is.messages+os.messages)
+	assert.True(t, is.closed)
+	assert.True(t, os.closed)
+}
+
+func TestController_ServeHTTP(t *testing.T) {
+	c := NewController()
+
+	for _, tt := range []struct {
+		method   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{method: "GET", wantCode: http.StatusOK, wantBody: `{"rules":null}`},
+		{
+			method:   "PUT",
+			body:     `{"rules":[{"method":"get","latency":"150ms"}]}`,
+			wantCode: http.StatusOK,
+			wantBody: `{"rules":[{"method":"get","latency":"150ms"}]}`,
+		},
+		{method: "PUT", body: `{"rules":[{"rate":2}]}`, wantCode: http.StatusBadRequest},
+		{
+			method:   "GET",
+			wantCode: http.StatusOK,
+			wantBody: `{"rules":[{"method":"get","latency":"150ms"}]}`,
+		},
+		{method: "DELETE", wantCode: http.StatusOK, wantBody: `{"rules":null}`},
+		{method: "PATCH", wantCode: http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+
+		c.ServeHTTP(w, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+
+		assert.Equal(t, tt.wantCode, w.Code, tt.method)
+
+		if tt.wantBody != "" {
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		}
+	}
+}
+
+func TestController_WatchFile(t *testing.T) {
+	var (
+		c    = NewController()
+		path = filepath.Join(t.TempDir(), "fault.json")
+
+		ctx, cancel = context.WithCancel(context.Background())
+		done        = make(chan error)
+	)
+
+	defer cancel()
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules":[{"method":"get"}]}`), 0600))
+
+	go func() { done <- c.WatchFile(ctx, path, time.Millisecond, nil) }()
+
+	require.Eventually(
+		t,
+		func() bool { return len(c.Config().Rules) == 1 },
+		time.Second,
+		time.Millisecond,
+	)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules":[]}`), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	require.Eventually(
+		t,
+		func() bool { return len(c.Config().Rules) == 0 },
+		time.Second,
+		time.Millisecond,
+	)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
@@ -0,0 +1,113 @@
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/upfluence/errors"
+)
+
+// Duration is a time.Duration encoded in JSON as a string such as "150ms".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// Rule describes the faults injected in the calls it matches.
+type Rule struct {
+	// Method matched, either "<method>" or "<service>.<method>". Empty or
+	// "*" matches every method.
+	Method string `json:"method,omitempty"`
+	// Trigger restricts the rule to the calls carrying it as the value of
+	// the trigger THeader.
+	Trigger string `json:"trigger,omitempty"`
+	// Rate is the fraction of the matched calls faulted, 0 stands for all of
+	// them.
+	Rate float64 `json:"rate,omitempty"`
+
+	// Latency added before the call is processed.
+	Latency Duration `json:"latency,omitempty"`
+	// ApplicationException is the type of the TApplicationException the
+	// call fails with.
+	ApplicationException *int32 `json:"application_exception,omitempty"`
+	// Exception is the name of the declared exception, as named in the
+	// throws clause of the function, the call fails with.
+	Exception string `json:"exception,omitempty"`
+	// DropOneway drops the oneway calls, they are never processed.
+	DropOneway bool `json:"drop_oneway,omitempty"`
+	// AbortStreamAfter aborts the streams once this many messages went
+	// through them.
+	AbortStreamAfter int64 `json:"abort_stream_after,omitempty"`
+}
+
+func (r Rule) validate() error {
+	if r.Rate < 0 || r.Rate > 1 {
+		return fmt.Errorf("fault: rate %v is out of [0, 1]", r.Rate)
+	}
+
+	if r.Latency < 0 {
+		return errors.New("fault: latency is negative")
+	}
+
+	if r.AbortStreamAfter < 0 {
+		return errors.New("fault: abort_stream_after is negative")
+	}
+
+	if r.ApplicationException != nil && r.Exception != "" {
+		return errors.New("fault: application_exception and exception are exclusive")
+	}
+
+	return nil
+}
+
+func (r Rule) matches(service, mth, trigger string) bool {
+	if r.Trigger != "" && r.Trigger != trigger {
+		return false
+	}
+
+	switch r.Method {
+	case "", "*", mth:
+		return true
+	}
+
+	s, m, ok := strings.Cut(r.Method, ".")
+
+	return ok && s == service && (m == mth || m == "*")
+}
+
+// Config is the set of rules enabled, the first rule matching a call
+// applies.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+func (c Config) validate() error {
+	for i, r := range c.Rules {
+		if err := r.validate(); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("rule %d", i))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package hedge
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+const (
+	DefaultDelay      = 50 * time.Millisecond
+	DefaultMaxHedges  = 1
+	DefaultMinSamples = 20
+
+	latencyWindow = 256
+)
+
+type Options struct {
+	// Delay between two copies of a call.
+	Delay time.Duration
+
+	// When set, the delay is the given percentile (e.g. 0.95) of the
+	// latencies observed for the method, Delay being used until MinSamples
+	// calls succeeded.
+	Percentile float64
+	MinSamples int
+
+	// Number of copies sent on top of the original call.
+	MaxHedges int
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Delay == 0 {
+		opts.Delay = DefaultDelay
+	}
+
+	if opts.MinSamples == 0 {
+		opts.MinSamples = DefaultMinSamples
+	}
+
+	if opts.MaxHedges == 0 {
+		opts.MaxHedges = DefaultMaxHedges
+	}
+
+	return opts
+}
+
+type provider struct {
+	provider thrift.TClientProvider
+	opts     Options
+}
+
+// NewClientProvider returns a TClientProvider hedging the calls of the
+// functions annotated ReadOnly: when no response came back after the hedging
+// delay, a copy of the call is sent, up to MaxHedges times. A copy is sent
+// right away when a call fails on a transport error, a timeout or an error
+// of the server. The first successful response is used and the other calls
+// are cancelled through their context.
+//
+// Every copy goes through its own client built by p, so a provider dialing a
+// connection per client sends each copy over a separate connection.
+func NewClientProvider(p thrift.TClientProvider, opts Options) thrift.TClientProvider {
+	return &provider{provider: p, opts: opts.withDefaults()}
+}
+
+func (p *provider) Build(namespace, service string) (thrift.TClient, error) {
+	var (
+		sd, _ = thrift.GetServiceDefinition(namespace + "." + service)
+
+		c = client{
+			opts:      p.opts,
+			clients:   make([]thrift.TClient, p.opts.MaxHedges+1),
+			readOnly:  make(map[string]bool),
+			latencies: make(map[string]*latencies),
+		}
+	)
+
+	for i := range c.clients {
+		cl, err := p.provider.Build(namespace, service)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.clients[i] = cl
+	}
+
+	for _, fd := range sd.Functions {
+		c.readOnly[fd.Name] = rpc.IsReadOnly(sd, fd)
+	}
+
+	return &c, nil
+}
+
+// latencies keeps the last latencyWindow latencies of a method.
+type latencies struct {
+	mu     sync.Mutex
+	values []time.Duration
+	next   int
+}
+
+func (l *latencies) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.values) < latencyWindow {
+		l.values = append(l.values, d)
+		return
+	}
+
+	l.values[l.next] = d
+	l.next = (l.next + 1) % latencyWindow
+}
+
+func (l *latencies) percentile(p float64, minSamples int) (time.Duration, bool) {
+	l.mu.Lock()
+
+	if len(l.values) < minSamples || len(l.values) == 0 {
+		l.mu.Unlock()
+		return 0, false
+	}
+
+	vs := append([]time.Duration(nil), l.values...)
+	l.mu.Unlock()
+
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+
+	i := int(p * float64(len(vs)))
+
+	if i >= len(vs) {
+		i = len(vs) - 1
+	}
+
+	return vs[i], true
+}
+
+type client struct {
+	opts     Options
+	clients  []thrift.TClient
+	readOnly map[string]bool
+
+	mu        sync.Mutex
+	latencies map[string]*latencies
+}
+
+func (c *client) methodLatencies(mth string) *latencies {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.latencies[mth]
+
+	if !ok {
+		l = &latencies{}
+		c.latencies[mth] = l
+	}
+
+	return l
+}
+
+func (c *client) delay(l *latencies) time.Duration {
+	if c.opts.Percentile > 0 {
+		if d, ok := l.percentile(c.opts.Percentile, c.opts.MinSamples); ok {
+			return d
+		}
+	}
+
+	return c.opts.Delay
+}
+
+// isRetryable reports whether another copy of a failed call may succeed.
+func isRetryable(err error) bool {
+	var (
+		terr thrift.TTransportException
+		aerr thrift.TApplicationException
+	)
+
+	switch {
+	case errors.IsTimeout(err), errors.As(err, &terr):
+		return true
+	case errors.As(err, &aerr):
+		switch aerr.TypeId() {
+		case thrift.INTERNAL_ERROR, thrift.INTERNAL_TIME_OUT_ERROR, thrift.BAD_SEQUENCE_ID, thrift.OVERLOADED:
+			return true
+		}
+	}
+
+	return false
+}
+
+type result struct {
+	res thrift.TResponse
+	err error
+	d   time.Duration
+}
+
+func (c *client) CallBinary(ctx thrift.Context, mth string, req thrift.TRequest, res thrift.TResponse) error {
+	rv := reflect.ValueOf(res)
+
+	if !c.readOnly[mth] || rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return c.clients[0].CallBinary(ctx, mth, req, res)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		l       = c.methodLatencies(mth)
+		results = make(chan result, len(c.clients))
+
+		sent, inflight int
+		lastErr        error
+	)
+
+	send := func() {
+		var (
+			cl = c.clients[sent]
+			r  = reflect.New(rv.Elem().Type()).Interface().(thrift.TResponse)
+		)
+
+		sent++
+		inflight++
+
+		go func() {
+			t0 := time.Now()
+			err := cl.CallBinary(ctx, mth, req, r)
+
+			results <- result{res: r, err: err, d: time.Since(t0)}
+		}()
+	}
+
+	send()
+
+	t := time.NewTimer(c.delay(l))
+	defer t.Stop()
+
+	for inflight > 0 {
+		select {
+		case r := <-results:
+			inflight--
+
+			if r.err == nil {
+				l.record(r.d)
+				rv.Elem().Set(reflect.ValueOf(r.res).Elem())
+
+				return nil
+			}
+
+			lastErr = r.err
+
+			// Waiting for the delay is pointless, the copy is sent as a
+			// retry would.
+			if sent < len(c.clients) && ctx.Err() == nil && isRetryable(r.err) {
+				send()
+			}
+		case <-t.C:
+			if sent < len(c.clients) {
+				send()
+				t.Reset(c.delay(l))
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return lastErr
+}
+
+func (c *client) CallUnary(ctx thrift.Context, mth string, req thrift.TRequest) error {
+	return c.clients[0].CallUnary(ctx, mth, req)
+}
@@ -0,0 +1,209 @@
+package hedge
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+type response struct {
+	thrift.TResponse
+
+	Value string
+}
+
+type fakeClient struct {
+	thrift.TClient
+
+	delay time.Duration
+	err   error
+
+	mu        sync.Mutex
+	calls     int
+	cancelled bool
+}
+
+func (c *fakeClient) CallBinary(ctx thrift.Context, _ string, _ thrift.TRequest, res thrift.TResponse) error {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	select {
+	case <-time.After(c.delay):
+		res.(*response).Value = "ok"
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.cancelled = true
+		c.mu.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+func (c *fakeClient) state() (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls, c.cancelled
+}
+
+type fakeProvider struct {
+	delays  []time.Duration
+	clients []*fakeClient
+}
+
+func (p *fakeProvider) Build(string, string) (thrift.TClient, error) {
+	cl := &fakeClient{delay: p.delays[len(p.clients)]}
+	p.clients = append(p.clients, cl)
+
+	return cl, nil
+}
+
+func init() {
+	thrift.RegisterService(
+		thrift.ServiceDefinition{
+			Namespace:           "hedge.test",
+			AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "Service"},
+			Functions: []thrift.FunctionDefinition{
+				{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "write"}},
+				{
+					AnnotatedDefinition: thrift.AnnotatedDefinition{
+						Name:                  "read",
+						StructuredAnnotations: []thrift.RegistrableStruct{&rpc.ReadOnly{}},
+					},
+				},
+			},
+		},
+	)
+}
+
+func buildClient(t *testing.T, delays ...time.Duration) (thrift.TClient, *fakeProvider) {
+	p := &fakeProvider{delays: delays}
+
+	cl, err := NewClientProvider(
+		p,
+		Options{Delay: 10 * time.Millisecond, MaxHedges: len(delays) - 1},
+	).Build("hedge.test", "Service")
+
+	require.NoError(t, err)
+
+	return cl, p
+}
+
+func TestClient_Hedged(t *testing.T) {
+	cl, p := buildClient(t, time.Hour, time.Millisecond)
+
+	var res response
+
+	require.NoError(t, cl.CallBinary(context.Background(), "read", nil, &res))
+	assert.Equal(t, "ok", res.Value)
+
+	assert.Eventually(
+		t,
+		func() bool {
+			_, cancelled := p.clients[0].state()
+			return cancelled
+		},
+		time.Second,
+		time.Millisecond,
+	)
+
+	calls, _ := p.clients[1].state()
+	assert.Equal(t, 1, calls)
+}
+
+func TestClient_HedgedOnFailure(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{
+			name:      "retryable",
+			err:       thrift.NewTTransportException(thrift.NOT_OPEN, "broken"),
+			wantCalls: 1,
+		},
+		{
+			name: "not retryable",
+			err:  thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, "unknown"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakeProvider{delays: []time.Duration{0, time.Millisecond}}
+
+			// The delay never elapses, a hedge can only follow the failure.
+			cl, err := NewClientProvider(
+				p,
+				Options{Delay: time.Hour, MaxHedges: 1},
+			).Build("hedge.test", "Service")
+			require.NoError(t, err)
+
+			p.clients[0].err = tt.err
+
+			var res response
+
+			err = cl.CallBinary(context.Background(), "read", nil, &res)
+
+			calls, _ := p.clients[1].state()
+			assert.Equal(t, tt.wantCalls, calls)
+
+			if tt.wantCalls > 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "ok", res.Value)
+			} else {
+				assert.Equal(t, tt.err, err)
+			}
+		})
+	}
+}
+
+func TestClient_NoHedgeWhenFast(t *testing.T) {
+	cl, p := buildClient(t, 0, 0)
+
+	var res response
+
+	require.NoError(t, cl.CallBinary(context.Background(), "read", nil, &res))
+	assert.Equal(t, "ok", res.Value)
+
+	calls, _ := p.clients[1].state()
+	assert.Equal(t, 0, calls)
+}
+
+func TestClient_NotReadOnly(t *testing.T) {
+	cl, p := buildClient(t, 30*time.Millisecond, 0)
+
+	var res response
+
+	require.NoError(t, cl.CallBinary(context.Background(), "write", nil, &res))
+	assert.Equal(t, "ok", res.Value)
+
+	calls, _ := p.clients[1].state()
+	assert.Equal(t, 0, calls)
+}
+
+func TestLatencies_Percentile(t *testing.T) {
+	var l latencies
+
+	_, ok := l.percentile(0.9, 10)
+	assert.False(t, ok)
+
+	for i := 1; i <= 100; i++ {
+		l.record(time.Duration(i) * time.Millisecond)
+	}
+
+	d, ok := l.percentile(0.9, 10)
+	assert.True(t, ok)
+	assert.Equal(t, 91*time.Millisecond, d)
+}
@@ -0,0 +1,277 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/internal/wire"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+const (
+	DefaultTTL = 24 * time.Hour
+
+	// KeyHeader is the THeader carrying the idempotency key of a call.
+	KeyHeader = "thrift-idempotency-key"
+
+	DefaultCallerHeader = thrift.THeaderCallerKey
+)
+
+type Options struct {
+	// Defaults to a MemoryStore.
+	Store Store
+	// TTL of the records, retries coming later are processed again.
+	TTL time.Duration
+
+	// CallerHeader scopes the keys by caller, two callers using the same key
+	// do not share their records.
+	CallerHeader string
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	if opts.CallerHeader == "" {
+		opts.CallerHeader = DefaultCallerHeader
+	}
+
+	return opts
+}
+
+// WithKey returns a client context sending key as the idempotency key of
+// the calls made with it. The retries of a call made with this context
+// share its key.
+func WithKey(ctx thrift.Context, key string) thrift.Context {
+	keys := thrift.GetWriteHeaderList(ctx)
+
+	for _, k := range keys {
+		if k == KeyHeader {
+			return thrift.SetHeader(ctx, KeyHeader, key)
+		}
+	}
+
+	return thrift.SetWriteHeaderList(
+		thrift.SetHeader(ctx, KeyHeader, key),
+		append(append([]string(nil), keys...), KeyHeader),
+	)
+}
+
+type builder struct {
+	opts Options
+}
+
+// NewServerMiddlewareBuilder returns a server TMiddlewareBuilder
+// deduplicating the calls to the Idempotent functions carrying an
+// idempotency key. The outcome of the first call, its response or its
+// declared exception, is stored and replayed to the calls retried with the
+// same key. The duplicates received while the first call runs wait for its
+// outcome. The calls failing otherwise are not stored, their retries are
+// processed again. A key reused with different arguments fails with a
+// PROTOCOL_ERROR TApplicationException. The calls to the functions whose
+// result struct is not registered are not deduplicated, their responses
+// could not be replayed.
+func NewServerMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	return &builder{opts: opts.withDefaults()}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{
+		opts:    b.opts,
+		prefix:  namespace + "." + service + ".",
+		flights: make(map[string]*flight),
+	}
+}
+
+type flight struct {
+	done chan struct{}
+
+	record *Record
+}
+
+type middleware struct {
+	thrift.TNopMiddleware
+
+	opts   Options
+	prefix string
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// call is a call deduplicated under its idempotency key.
+type call struct {
+	key string
+	// hash is the hash of the arguments of the call, telling apart the
+	// calls reusing a key.
+	hash []byte
+	// result is the type of the result struct of the function called, the
+	// responses replayed are read back into it. It is nil for the oneway
+	// functions.
+	result reflect.Type
+}
+
+// call returns the call to deduplicate, it reports false when the call is
+// to be processed as is.
+func (m *middleware) call(ctx thrift.Context, mth string, req thrift.TRequest, oneway bool) (call, bool) {
+	md, ok := thrift.GetMethodDefinition(ctx)
+
+	if !ok || !rpc.IsIdempotent(md.Service, md.Function) {
+		return call{}, false
+	}
+
+	key, ok := thrift.GetHeader(ctx, KeyHeader)
+
+	if !ok || key == "" {
+		return call{}, false
+	}
+
+	b, err := wire.CanonicalBytes(req)
+
+	if err != nil {
+		return call{}, false
+	}
+
+	caller, _ := thrift.GetHeader(ctx, m.opts.CallerHeader)
+	sum := sha256.Sum256(b)
+
+	c := call{key: m.prefix + mth + ":" + caller + ":" + key, hash: sum[:]}
+
+	if oneway {
+		return c, true
+	}
+
+	// The response is replayed in the protocol of the retry, it has to be
+	// read back into its result struct.
+	if c.result, ok = resultType(md); !ok {
+		return call{}, false
+	}
+
+	return c, true
+}
+
+var responseType = reflect.TypeOf((*thrift.TResponse)(nil)).Elem()
+
+func resultType(md thrift.TMethodDefinition) (reflect.Type, bool) {
+	if md.Function.Result == nil {
+		return nil, false
+	}
+
+	t, ok := thrift.StructType(md.Function.Result.CanonicalName())
+
+	if !ok || !reflect.PtrTo(t).Implements(responseType) {
+		return nil, false
+	}
+
+	return t, true
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	c, ok := m.call(ctx, mth, req, false)
+
+	if !ok {
+		return next(ctx, req)
+	}
+
+	return m.do(ctx, c, func(ctx thrift.Context) (thrift.TResponse, *Record, error) {
+		res, err := next(ctx, req)
+
+		if err != nil || res == nil {
+			return res, nil, err
+		}
+
+		data, err := thrift.NewTSerializer().Write(ctx, res)
+
+		if err != nil {
+			return res, nil, nil
+		}
+
+		return res, &Record{RequestHash: c.hash, Response: data, Exception: res.GetError() != nil}, nil
+	})
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	c, ok := m.call(ctx, mth, req, true)
+
+	if !ok {
+		return next(ctx, req)
+	}
+
+	_, err := m.do(ctx, c, func(ctx thrift.Context) (thrift.TResponse, *Record, error) {
+		if err := next(ctx, req); err != nil {
+			return nil, nil, err
+		}
+
+		return nil, &Record{RequestHash: c.hash}, nil
+	})
+
+	return err
+}
+
+// do runs fn unless a record is stored for c or a flight is running for it.
+func (m *middleware) do(ctx thrift.Context, c call, fn func(thrift.Context) (thrift.TResponse, *Record, error)) (thrift.TResponse, error) {
+	for {
+		m.mu.Lock()
+		f, ok := m.flights[c.key]
+
+		if !ok {
+			f = &flight{done: make(chan struct{})}
+			m.flights[c.key] = f
+			m.mu.Unlock()
+
+			return m.lead(ctx, f, c, fn)
+		}
+
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-f.done:
+		}
+
+		if f.record != nil {
+			return replay(f.record, c)
+		}
+
+		// The call of the leader failed, this one is processed again just
+		// as a retry coming later would be.
+	}
+}
+
+// lead looks the record of c up and runs fn when there is none.
+func (m *middleware) lead(ctx thrift.Context, f *flight, c call, fn func(thrift.Context) (thrift.TResponse, *Record, error)) (thrift.TResponse, error) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.flights, c.key)
+		m.mu.Unlock()
+
+		close(f.done)
+	}()
+
+	if r, ok, err := m.opts.Store.Get(ctx, c.key); err == nil && ok {
+		f.record = r
+
+		return replay(r, c)
+	}
+
+	res, r, err := fn(ctx)
+
+	if err != nil || r == nil {
+		return res, err
+	}
+
+	f.record = r
+
+	m.opts.Store.Set(ctx, c.key, r, m.opts.TTL) //nolint:errcheck
+
+	return res, nil
+}
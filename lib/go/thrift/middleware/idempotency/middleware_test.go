@@ -0,0 +1,483 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+type upsertResult struct {
+	Success *int64
+	Failure *string
+	Tags    map[string][]string
+}
+
+func (r *upsertResult) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("upsert_result")
+
+	if r.Success != nil {
+		p.WriteFieldBegin("success", thrift.I64, 0)
+		p.WriteI64(*r.Success)
+		p.WriteFieldEnd()
+	}
+
+	if r.Failure != nil {
+		p.WriteFieldBegin("failure", thrift.STRING, 1)
+		p.WriteString(*r.Failure)
+		p.WriteFieldEnd()
+	}
+
+	if r.Tags != nil {
+		p.WriteFieldBegin("tags", thrift.MAP, 2)
+		p.WriteMapBegin(thrift.STRING, thrift.LIST, len(r.Tags))
+
+		for k, vs := range r.Tags {
+			p.WriteString(k)
+			p.WriteListBegin(thrift.STRING, len(vs))
+
+			for _, v := range vs {
+				p.WriteString(v)
+			}
+
+			p.WriteListEnd()
+		}
+
+		p.WriteMapEnd()
+		p.WriteFieldEnd()
+	}
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (r *upsertResult) Read(p thrift.TProtocol) error {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typeID == thrift.STOP {
+			break
+		}
+
+		switch {
+		case id == 0 && typeID == thrift.I64:
+			v, err := p.ReadI64()
+
+			if err != nil {
+				return err
+			}
+
+			r.Success = &v
+		case id == 1 && typeID == thrift.STRING:
+			v, err := p.ReadString()
+
+			if err != nil {
+				return err
+			}
+
+			r.Failure = &v
+		case id == 2 && typeID == thrift.MAP:
+			_, _, n, err := p.ReadMapBegin()
+
+			if err != nil {
+				return err
+			}
+
+			r.Tags = make(map[string][]string, n)
+
+			for i := 0; i < n; i++ {
+				k, _ := p.ReadString()
+				_, m, err := p.ReadListBegin()
+
+				if err != nil {
+					return err
+				}
+
+				for j := 0; j < m; j++ {
+					v, _ := p.ReadString()
+					r.Tags[k] = append(r.Tags[k], v)
+				}
+
+				p.ReadListEnd()
+			}
+
+			if err := p.ReadMapEnd(); err != nil {
+				return err
+			}
+		default:
+			if err := p.Skip(typeID); err != nil {
+				return err
+			}
+		}
+
+		if err := p.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return p.ReadStructEnd()
+}
+
+var upsertResultDefinition = thrift.StructDefinition{
+	Namespace:           "idempotency.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "upsert_result"},
+}
+
+func (*upsertResult) StructDefinition() thrift.StructDefinition { return upsertResultDefinition }
+
+func (r *upsertResult) String() string         { return fmt.Sprintf("%+v", *r) }
+func (r *upsertResult) GetResult() interface{} { return r.Success }
+
+func (r *upsertResult) GetError() error {
+	if r.Failure != nil {
+		return errors.New(*r.Failure)
+	}
+
+	return nil
+}
+
+type upsertArgs struct {
+	ID int64
+}
+
+func (a *upsertArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("upsert_args")
+	p.WriteFieldBegin("id", thrift.I64, 1)
+	p.WriteI64(a.ID)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (*upsertArgs) Read(p thrift.TProtocol) error { return nil }
+func (*upsertArgs) String() string                { return "upsert_args" }
+
+var testService = thrift.ServiceDefinition{
+	Functions: []thrift.FunctionDefinition{
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "upsert",
+				StructuredAnnotations: []thrift.RegistrableStruct{&rpc.Idempotent{}},
+			},
+			Result: &upsertResultDefinition,
+		},
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "insert"}},
+	},
+}
+
+func init() {
+	thrift.RegisterStruct((*upsertResult)(nil))
+}
+
+func callContext(mth, key string) thrift.Context {
+	ctx := thrift.WithMethod(context.Background(), testService, mth)
+
+	if key != "" {
+		ctx = thrift.SetHeader(ctx, KeyHeader, key)
+	}
+
+	return ctx
+}
+
+type handler struct {
+	calls int32
+	res   upsertResult
+	err   error
+}
+
+func (h *handler) next(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+	atomic.AddInt32(&h.calls, 1)
+
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	res := h.res
+
+	return &res, nil
+}
+
+// written reads res back the way a client would, over the compact protocol.
+func written(t *testing.T, res thrift.TResponse) *upsertResult {
+	t.Helper()
+
+	var (
+		buf = thrift.NewTMemoryBuffer()
+		p   = thrift.NewTCompactProtocol(buf)
+		r   upsertResult
+	)
+
+	require.NoError(t, res.Write(p))
+	require.NoError(t, p.Flush())
+	require.NoError(t, r.Read(p))
+
+	return &r
+}
+
+func int64Ptr(v int64) *int64    { return &v }
+func stringPtr(v string) *string { return &v }
+
+func TestMiddleware(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mth  string
+		key  string
+		res  upsertResult
+
+		wantCalls int32
+	}{
+		{
+			name:      "replayed response",
+			mth:       "upsert",
+			key:       "foo",
+			res:       upsertResult{Success: int64Ptr(42), Tags: map[string][]string{"a": {"b", "c"}}},
+			wantCalls: 1,
+		},
+		{
+			name:      "replayed exception",
+			mth:       "upsert",
+			key:       "foo",
+			res:       upsertResult{Failure: stringPtr("conflict")},
+			wantCalls: 1,
+		},
+		{
+			name:      "no key",
+			mth:       "upsert",
+			res:       upsertResult{Success: int64Ptr(42)},
+			wantCalls: 2,
+		},
+		{
+			name:      "not idempotent",
+			mth:       "insert",
+			key:       "foo",
+			res:       upsertResult{Success: int64Ptr(42)},
+			wantCalls: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+				h = handler{res: tt.res}
+			)
+
+			first, err := m.HandleBinaryRequest(callContext(tt.mth, tt.key), tt.mth, 1, &upsertArgs{}, h.next)
+			require.NoError(t, err)
+
+			h.res = upsertResult{Success: int64Ptr(0)}
+
+			res, err := m.HandleBinaryRequest(callContext(tt.mth, tt.key), tt.mth, 2, &upsertArgs{}, h.next)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantCalls, h.calls)
+
+			if tt.wantCalls == 1 {
+				assert.Equal(t, written(t, first), written(t, res))
+				assert.Equal(t, first.GetError() != nil, res.GetError() != nil)
+			} else {
+				assert.Equal(t, int64(0), *written(t, res).Success)
+			}
+		})
+	}
+}
+
+// TestMiddleware_JSON verifies that the replayed response is written as the
+// first one in a protocol telling the strings and the binaries apart.
+func TestMiddleware_JSON(t *testing.T) {
+	var (
+		m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+		h = handler{res: upsertResult{Failure: stringPtr("conflict"), Tags: map[string][]string{"a": {"b"}}}}
+	)
+
+	for i := 0; i < 2; i++ {
+		res, err := m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 1, &upsertArgs{}, h.next)
+		require.NoError(t, err)
+
+		var (
+			buf = thrift.NewTMemoryBuffer()
+			p   = thrift.NewTJSONProtocol(buf)
+			r   upsertResult
+		)
+
+		require.NoError(t, res.Write(p))
+		require.NoError(t, p.Flush())
+		require.NoError(t, r.Read(p))
+
+		assert.Equal(t, h.res, r)
+	}
+
+	assert.Equal(t, int32(1), h.calls)
+}
+
+func TestMiddleware_Failure(t *testing.T) {
+	var (
+		m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+		h = handler{err: errors.New("unavailable")}
+	)
+
+	_, err := m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 1, &upsertArgs{}, h.next)
+	assert.Error(t, err)
+
+	h.err = nil
+	h.res = upsertResult{Success: int64Ptr(42)}
+
+	res, err := m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 2, &upsertArgs{}, h.next)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), h.calls)
+	assert.Equal(t, int64(42), *written(t, res).Success)
+}
+
+func TestMiddleware_ArgumentsMismatch(t *testing.T) {
+	var (
+		m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+		h = handler{res: upsertResult{Success: int64Ptr(42)}}
+	)
+
+	_, err := m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 1, &upsertArgs{ID: 1}, h.next)
+	require.NoError(t, err)
+
+	_, err = m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 2, &upsertArgs{ID: 2}, h.next)
+
+	var aerr thrift.TApplicationException
+
+	require.True(t, errors.As(err, &aerr))
+	assert.Equal(t, int32(thrift.PROTOCOL_ERROR), aerr.TypeId())
+	assert.Equal(t, int32(1), h.calls)
+}
+
+func TestMiddleware_Caller(t *testing.T) {
+	var (
+		m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+		h = handler{res: upsertResult{Success: int64Ptr(42)}}
+	)
+
+	for _, caller := range []string{"a", "b", "a"} {
+		ctx := thrift.SetHeader(callContext("upsert", "foo"), DefaultCallerHeader, caller)
+
+		_, err := m.HandleBinaryRequest(ctx, "upsert", 1, &upsertArgs{}, h.next)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(2), h.calls)
+}
+
+func TestMiddleware_Unary(t *testing.T) {
+	var (
+		m     = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+		calls int32
+	)
+
+	next := func(thrift.Context, thrift.TRequest) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(
+			t,
+			m.HandleUnaryRequest(callContext("upsert", "foo"), "upsert", 1, &upsertArgs{}, next),
+		)
+	}
+
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestMiddleware_Concurrent(t *testing.T) {
+	var (
+		m = NewServerMiddlewareBuilder(Options{}).Build("idempotency.test", "Service")
+
+		release = make(chan struct{})
+		calls   int32
+		wg      sync.WaitGroup
+	)
+
+	next := func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+
+		return &upsertResult{Success: int64Ptr(42)}, nil
+	}
+
+	results := make([]thrift.TResponse, 8)
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			res, err := m.HandleBinaryRequest(callContext("upsert", "foo"), "upsert", 1, &upsertArgs{}, next)
+			assert.NoError(t, err)
+
+			results[i] = res
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls)
+
+	for _, res := range results {
+		assert.Equal(t, int64(42), *written(t, res).Success)
+	}
+}
+
+func TestWithKey(t *testing.T) {
+	ctx := WithKey(WithKey(context.Background(), "foo"), "bar")
+
+	v, _ := thrift.GetHeader(ctx, KeyHeader)
+
+	assert.Equal(t, "bar", v)
+	assert.Equal(t, []string{KeyHeader}, thrift.GetWriteHeaderList(ctx))
+}
+
+func TestMemoryStore(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Now()
+		s   = NewMemoryStore()
+	)
+
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < minSweepSize; i++ {
+		s.Set(ctx, fmt.Sprintf("k%d", i), &Record{}, time.Second)
+	}
+
+	_, ok, _ := s.Get(ctx, "k0")
+	assert.True(t, ok)
+
+	now = now.Add(time.Minute)
+
+	_, ok, _ = s.Get(ctx, "k0")
+	assert.False(t, ok)
+
+	// The expired records are removed once the store doubled in size.
+	for i := 0; i < minSweepSize; i++ {
+		s.Set(ctx, fmt.Sprintf("v%d", i), &Record{}, time.Minute)
+	}
+
+	assert.Equal(t, minSweepSize, s.Len())
+}
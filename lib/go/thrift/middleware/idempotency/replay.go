@@ -0,0 +1,31 @@
+package idempotency
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// replay returns the response stored in r to c, read back into its result
+// struct.
+func replay(r *Record, c call) (thrift.TResponse, error) {
+	if !bytes.Equal(r.RequestHash, c.hash) {
+		return nil, thrift.NewTApplicationException(
+			thrift.PROTOCOL_ERROR,
+			"idempotency key reused with different arguments",
+		)
+	}
+
+	if c.result == nil || len(r.Response) == 0 {
+		return nil, nil
+	}
+
+	res := reflect.New(c.result).Interface().(thrift.TResponse)
+
+	if err := thrift.NewTDeserializer().Read(res, r.Response); err != nil {
+		return nil, thrift.NewTApplicationExceptionFromError(thrift.INTERNAL_ERROR, err)
+	}
+
+	return res, nil
+}
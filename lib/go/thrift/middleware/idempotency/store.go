@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// Record is the outcome of a call stored under its idempotency key.
+type Record struct {
+	// RequestHash is the hash of the arguments of the call, the retries must
+	// carry the same ones.
+	RequestHash []byte
+	// Response is the result struct of the call serialized with the binary
+	// protocol, empty for a oneway call.
+	Response []byte
+	// Exception tells whether the result carries a declared exception.
+	Exception bool
+}
+
+// Store holds the records of the calls. The errors it returns are treated
+// as misses by the middleware, the call is then processed.
+type Store interface {
+	Get(ctx thrift.Context, key string) (*Record, bool, error)
+	Set(ctx thrift.Context, key string, r *Record, ttl time.Duration) error
+}
+
+const minSweepSize = 64
+
+type memoryEntry struct {
+	record  *Record
+	expires time.Time
+}
+
+// MemoryStore is an in memory Store, only suited to deduplicate the calls
+// reaching the same process.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+
+	// sweepAt is the number of entries over which the expired ones are
+	// removed on the next Set.
+	sweepAt int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:     time.Now,
+		entries: make(map[string]memoryEntry),
+		sweepAt: minSweepSize,
+	}
+}
+
+func (s *MemoryStore) Get(_ thrift.Context, key string) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+
+	if !ok || !s.now().Before(e.expires) {
+		return nil, false, nil
+	}
+
+	return e.record, true, nil
+}
+
+func (s *MemoryStore) Set(_ thrift.Context, key string, r *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	s.entries[key] = memoryEntry{record: r, expires: now.Add(ttl)}
+
+	if len(s.entries) < s.sweepAt {
+		return nil
+	}
+
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	if s.sweepAt = 2 * len(s.entries); s.sweepAt < minSweepSize {
+		s.sweepAt = minSweepSize
+	}
+
+	return nil
+}
+
+// Len returns the number of records held, expired ones included.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
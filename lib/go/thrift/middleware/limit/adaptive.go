@@ -0,0 +1,221 @@
+package limit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInitialLimit = 20
+	DefaultMinLimit     = 1
+	DefaultMaxLimit     = 1000
+
+	DefaultBackoffRatio = 0.9
+
+	DefaultSmoothing = 0.2
+	DefaultTolerance = 1.5
+	DefaultRTTWindow = 600
+)
+
+// algorithm computes the next limit of an adaptive limiter from the sample
+// of a released call.
+type algorithm interface {
+	update(limit float64, inFlight int, rtt time.Duration, o Outcome) float64
+}
+
+// AdaptiveLimiter is a concurrency Limiter whose limit follows the latency
+// and the drops of the calls it admits.
+type AdaptiveLimiter struct {
+	algorithm algorithm
+	min, max  float64
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+
+	now func() time.Time
+}
+
+func newAdaptiveLimiter(a algorithm, initial, min, max int) *AdaptiveLimiter {
+	if initial == 0 {
+		initial = DefaultInitialLimit
+	}
+
+	if min == 0 {
+		min = DefaultMinLimit
+	}
+
+	if max == 0 {
+		max = DefaultMaxLimit
+	}
+
+	return &AdaptiveLimiter{
+		algorithm: a,
+		min:       float64(min),
+		max:       float64(max),
+		limit:     float64(initial),
+		now:       time.Now,
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+func (l *AdaptiveLimiter) Acquire() (func(Outcome), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= int(l.limit) {
+		return nil, false
+	}
+
+	l.inFlight++
+
+	var (
+		t0   = l.now()
+		once sync.Once
+	)
+
+	return func(o Outcome) {
+		once.Do(func() { l.release(l.now().Sub(t0), o) })
+	}, true
+}
+
+func (l *AdaptiveLimiter) release(rtt time.Duration, o Outcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if o != Ignored {
+		l.limit = math.Max(
+			l.min,
+			math.Min(l.max, l.algorithm.update(l.limit, l.inFlight, rtt, o)),
+		)
+	}
+
+	l.inFlight--
+}
+
+type AIMDOptions struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+
+	// Factor applied to the limit on a dropped call.
+	BackoffRatio float64
+	// A call slower than Timeout counts as dropped, 0 disables it.
+	Timeout time.Duration
+}
+
+type aimd struct {
+	backoffRatio float64
+	timeout      time.Duration
+}
+
+// NewAIMDLimiter returns a Limiter adapting its concurrency limit with an
+// additive increase, multiplicative decrease: the limit grows by one for
+// every successful call made while at least half of it is in use, and is
+// multiplied by BackoffRatio on every dropped call.
+func NewAIMDLimiter(opts AIMDOptions) *AdaptiveLimiter {
+	a := aimd{backoffRatio: opts.BackoffRatio, timeout: opts.Timeout}
+
+	if a.backoffRatio == 0 {
+		a.backoffRatio = DefaultBackoffRatio
+	}
+
+	return newAdaptiveLimiter(a, opts.InitialLimit, opts.MinLimit, opts.MaxLimit)
+}
+
+func (a aimd) update(limit float64, inFlight int, rtt time.Duration, o Outcome) float64 {
+	if o == Dropped || (a.timeout > 0 && rtt > a.timeout) {
+		return limit * a.backoffRatio
+	}
+
+	if float64(inFlight)*2 >= limit {
+		return limit + 1
+	}
+
+	return limit
+}
+
+type GradientOptions struct {
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+
+	// Weight of a new limit in the smoothed limit, between 0 and 1.
+	Smoothing float64
+	// Ratio by which the latency may exceed its long term average before
+	// the limit is reduced.
+	Tolerance float64
+	// Number of samples the long term latency is averaged over.
+	RTTWindow int
+}
+
+type gradient struct {
+	smoothing float64
+	tolerance float64
+	window    float64
+
+	longRTT float64
+}
+
+// NewGradientLimiter returns a Limiter adapting its concurrency limit to the
+// gradient between the long term average latency and the latency of each
+// call: the limit shrinks as the latency grows past the tolerance, and grows
+// by a queue of the square root of the limit otherwise.
+func NewGradientLimiter(opts GradientOptions) *AdaptiveLimiter {
+	g := &gradient{
+		smoothing: opts.Smoothing,
+		tolerance: opts.Tolerance,
+		window:    float64(opts.RTTWindow),
+	}
+
+	if g.smoothing == 0 {
+		g.smoothing = DefaultSmoothing
+	}
+
+	if g.tolerance == 0 {
+		g.tolerance = DefaultTolerance
+	}
+
+	if g.window == 0 {
+		g.window = DefaultRTTWindow
+	}
+
+	return newAdaptiveLimiter(g, opts.InitialLimit, opts.MinLimit, opts.MaxLimit)
+}
+
+func (g *gradient) update(limit float64, inFlight int, rtt time.Duration, o Outcome) float64 {
+	sample := rtt.Seconds()
+
+	if g.longRTT == 0 {
+		g.longRTT = sample
+	} else {
+		g.longRTT += (sample - g.longRTT) / g.window
+	}
+
+	grad := 0.5
+
+	if o != Dropped {
+		// An application limited service tells nothing about its limit.
+		if float64(inFlight)*2 < limit {
+			return limit
+		}
+
+		if sample > 0 {
+			grad = math.Max(0.5, math.Min(1, g.tolerance*g.longRTT/sample))
+		} else {
+			grad = 1
+		}
+	}
+
+	next := limit*grad + math.Sqrt(limit)
+
+	return limit*(1-g.smoothing) + next*g.smoothing
+}
@@ -0,0 +1,30 @@
+package limit
+
+import (
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// overloadedError is an Overloaded as it travels: an OVERLOADED
+// TApplicationException, unwrapping to the Overloaded for exception.IsSafe
+// and errors.As to find it.
+type overloadedError struct {
+	thrift.TApplicationException
+
+	overloaded *Overloaded
+}
+
+// NewOverloadedError returns the error failing the calls a limiter rejects.
+func NewOverloadedError(msg string) thrift.TApplicationException {
+	return newOverloadedError(thrift.NewTApplicationException(thrift.OVERLOADED, msg))
+}
+
+func newOverloadedError(aerr thrift.TApplicationException) *overloadedError {
+	msg := aerr.Error()
+
+	return &overloadedError{
+		TApplicationException: aerr,
+		overloaded:            &Overloaded{Message: &msg},
+	}
+}
+
+func (e *overloadedError) Unwrap() error { return e.overloaded }
@@ -0,0 +1,170 @@
+// Autogenerated by Thrift Compiler (2.7.0-upfluence)
+// DO NOT EDIT UNLESS YOU ARE SURE THAT YOU KNOW WHAT YOU ARE DOING
+
+package limit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/exception"
+	"io"
+	"reflect"
+)
+
+// (needed to ensure safety because of naive import list construction.)
+var _ = thrift.ZERO
+var _ = fmt.Printf
+var _ = context.Background
+var _ = reflect.DeepEqual
+var _ = bytes.Equal
+var _ = io.EOF
+
+var _ = exception.GoUnusedProtection__
+
+// Attributes:
+//   - Message
+type Overloaded struct {
+	Message *string `thrift:"message,1" db:"message" json:"message,omitempty"`
+}
+
+func NewOverloaded() *Overloaded {
+	return &Overloaded{}
+}
+
+var overloadedStructDefinition = thrift.StructDefinition{
+	Namespace:   Namespace,
+	IsException: true,
+	AnnotatedDefinition: thrift.AnnotatedDefinition{
+		Name:              "Overloaded",
+		LegacyAnnotations: map[string]string{},
+		StructuredAnnotations: []thrift.RegistrableStruct{
+			&exception.Safe{},
+		},
+	},
+	Fields: []thrift.FieldDefinition{
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "message",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+	},
+}
+
+func (p *Overloaded) StructDefinition() thrift.StructDefinition {
+	return overloadedStructDefinition
+}
+
+var Overloaded_Message_DEFAULT string
+
+func (p *Overloaded) GetMessage() string {
+	if !p.IsSetMessage() {
+		return Overloaded_Message_DEFAULT
+	}
+	return *p.Message
+}
+
+func (p *Overloaded) SetMessage(v string) {
+	p.Message = &v
+}
+func (p *Overloaded) IsSetMessage() bool {
+	return p.Message != nil
+}
+
+func (p *Overloaded) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T read error: ", p), err)
+	}
+
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T field %d read error: ", p, fieldId), err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField1(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T read struct end error: ", p), err)
+	}
+	return nil
+}
+
+func (p *Overloaded) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return thrift.PrependError("error reading field 1: ", err)
+	} else {
+		p.Message = &v
+	}
+	return nil
+}
+
+func (p *Overloaded) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("Overloaded"); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write struct begin error: ", p), err)
+	}
+	if p != nil {
+		if err := p.writeField1(oprot); err != nil {
+			return err
+		}
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return thrift.PrependError("write field stop error: ", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return thrift.PrependError("write struct stop error: ", err)
+	}
+	return nil
+}
+
+func (p *Overloaded) writeField1(oprot thrift.TProtocol) (err error) {
+	if p.IsSetMessage() {
+		if err := oprot.WriteFieldBegin("message", thrift.STRING, 1); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field begin error 1:message: ", p), err)
+		}
+		if err := oprot.WriteString(string(*p.Message)); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T.message (1) field write error: ", p), err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field end error 1:message: ", p), err)
+		}
+	}
+	return err
+}
+
+func (p *Overloaded) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf(
+		"Overloaded({message: %v})",
+		p.GetMessage(),
+	)
+}
+
+func (p *Overloaded) Error() string {
+	return p.String()
+}
@@ -0,0 +1,14 @@
+namespace * middleware.limit
+
+include "types/annotation/exception.thrift"
+
+// Overloaded is raised for the calls a limiter rejects. The call was rejected
+// before reaching the handler, it can be sent again, preferably after backing
+// off.
+//
+// The functions do not declare it, it travels as an OVERLOADED
+// TApplicationException carrying the message.
+@exception.Safe{}
+exception Overloaded {
+  1: optional string message;
+}
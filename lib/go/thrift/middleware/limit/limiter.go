@@ -0,0 +1,135 @@
+package limit
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcome is the result of an admitted call, reported when releasing it.
+type Outcome int
+
+const (
+	// The call completed.
+	Success Outcome = iota
+	// The call failed because of overload: it timed out or was rejected
+	// downstream. Adaptive limiters back off from it.
+	Dropped
+	// The call did not run, the slot is released without a sample.
+	Ignored
+)
+
+// Limiter admits the calls. Acquire returns false when the call must be
+// rejected, otherwise release must be called once the call is done.
+type Limiter interface {
+	Acquire() (release func(Outcome), ok bool)
+}
+
+func nopRelease(Outcome) {}
+
+// MultiLimiter admits a call when all of its limiters do.
+type MultiLimiter []Limiter
+
+func (ls MultiLimiter) Acquire() (func(Outcome), bool) {
+	rs := make([]func(Outcome), 0, len(ls))
+
+	for _, l := range ls {
+		r, ok := l.Acquire()
+
+		if !ok {
+			for _, r := range rs {
+				r(Ignored)
+			}
+
+			return nil, false
+		}
+
+		rs = append(rs, r)
+	}
+
+	return func(o Outcome) {
+		for _, r := range rs {
+			r(o)
+		}
+	}, true
+}
+
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight int
+}
+
+// NewConcurrencyLimiter returns a Limiter admitting up to n calls in flight.
+func NewConcurrencyLimiter(n int) Limiter {
+	return &concurrencyLimiter{limit: n}
+}
+
+func (l *concurrencyLimiter) Acquire() (func(Outcome), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= l.limit {
+		return nil, false
+	}
+
+	l.inFlight++
+
+	var once sync.Once
+
+	return func(Outcome) {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.mu.Unlock()
+		})
+	}, true
+}
+
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+// NewTokenBucket returns a Limiter admitting rate calls per second on
+// average, and bursts of up to burst calls. The bucket starts full.
+func NewTokenBucket(rate float64, burst int) Limiter {
+	return newTokenBucket(rate, burst, time.Now)
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+func (b *tokenBucket) Acquire() (func(Outcome), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.now()
+
+	b.tokens += t.Sub(b.last).Seconds() * b.rate
+	b.last = t
+
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+
+	if b.tokens < 1 {
+		return nil, false
+	}
+
+	b.tokens--
+
+	return nopRelease, true
+}
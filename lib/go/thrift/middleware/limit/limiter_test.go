@@ -0,0 +1,159 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	l := NewConcurrencyLimiter(2)
+
+	r1, ok := l.Acquire()
+	require.True(t, ok)
+
+	_, ok = l.Acquire()
+	require.True(t, ok)
+
+	_, ok = l.Acquire()
+	assert.False(t, ok)
+
+	r1(Success)
+	r1(Success)
+
+	_, ok = l.Acquire()
+	assert.True(t, ok)
+
+	_, ok = l.Acquire()
+	assert.False(t, ok)
+}
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestTokenBucket(t *testing.T) {
+	var (
+		c = clock{t: time.Unix(0, 0)}
+		b = newTokenBucket(10, 2, c.now)
+	)
+
+	for i := 0; i < 2; i++ {
+		_, ok := b.Acquire()
+		require.True(t, ok)
+	}
+
+	_, ok := b.Acquire()
+	assert.False(t, ok)
+
+	c.t = c.t.Add(100 * time.Millisecond)
+
+	_, ok = b.Acquire()
+	assert.True(t, ok)
+
+	_, ok = b.Acquire()
+	assert.False(t, ok)
+
+	// The bucket never holds more than the burst.
+	c.t = c.t.Add(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, ok := b.Acquire()
+		require.True(t, ok)
+	}
+
+	_, ok = b.Acquire()
+	assert.False(t, ok)
+}
+
+func TestMultiLimiter(t *testing.T) {
+	var (
+		a = NewConcurrencyLimiter(2)
+		l = MultiLimiter{a, NewConcurrencyLimiter(1)}
+	)
+
+	r, ok := l.Acquire()
+	require.True(t, ok)
+
+	_, ok = l.Acquire()
+	assert.False(t, ok)
+
+	// The slot taken on the first limiter by the rejected call was released.
+	_, ok = a.Acquire()
+	assert.True(t, ok)
+
+	_, ok = a.Acquire()
+	assert.False(t, ok)
+
+	r(Success)
+
+	_, ok = a.Acquire()
+	assert.True(t, ok)
+}
+
+func TestAIMDLimiter(t *testing.T) {
+	l := NewAIMDLimiter(AIMDOptions{InitialLimit: 2, MaxLimit: 3, BackoffRatio: 0.5})
+
+	r1, _ := l.Acquire()
+	r2, _ := l.Acquire()
+
+	r1(Success)
+	assert.Equal(t, 3, l.Limit())
+
+	r2(Success)
+	assert.Equal(t, 3, l.Limit())
+
+	r, _ := l.Acquire()
+	r(Success)
+	assert.Equal(t, 3, l.Limit(), "the limit does not grow while mostly unused")
+
+	r, _ = l.Acquire()
+	r(Dropped)
+	assert.Equal(t, 1, l.Limit())
+
+	r, _ = l.Acquire()
+	r(Ignored)
+	assert.Equal(t, 1, l.Limit())
+}
+
+func TestGradientLimiter(t *testing.T) {
+	var (
+		c = clock{t: time.Unix(0, 0)}
+		l = NewGradientLimiter(GradientOptions{InitialLimit: 4, Smoothing: 1, RTTWindow: 1000})
+	)
+
+	l.now = c.now
+
+	call := func(rtt time.Duration, o Outcome) {
+		var rs []func(Outcome)
+
+		for i := 0; i < l.Limit(); i++ {
+			r, ok := l.Acquire()
+			require.True(t, ok)
+
+			rs = append(rs, r)
+		}
+
+		c.t = c.t.Add(rtt)
+		rs[0](o)
+
+		for _, r := range rs[1:] {
+			r(Ignored)
+		}
+	}
+
+	call(10*time.Millisecond, Success)
+	assert.Equal(t, 6, l.Limit(), "a steady latency grows the limit by its square root")
+
+	call(time.Second, Success)
+	assert.Equal(t, 5, l.Limit(), "a latency spike shrinks the limit")
+
+	limit := l.limit
+
+	call(10*time.Millisecond, Dropped)
+	assert.Less(t, l.limit, limit)
+}
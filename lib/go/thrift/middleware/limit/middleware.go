@@ -0,0 +1,328 @@
+package limit
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const (
+	DefaultCallerHeader = thrift.THeaderCallerKey
+	DefaultMaxCallers   = 1024
+)
+
+type Options struct {
+	// Limiter shared by every call, nil for no global limit.
+	Global Limiter
+	// Builds the limiter of each method, nil for no per method limit.
+	PerMethod func(method string) Limiter
+	// Builds the limiter of each caller, nil for no per caller limit. The
+	// calls without caller share the limiter of the "" caller.
+	PerCaller func(caller string) Limiter
+	// Maximum number of caller limiters kept, the least recently used one
+	// is dropped to make room for a new caller. Defaults to
+	// DefaultMaxCallers.
+	MaxCallers int
+
+	// THeader identifying the caller. Its value must be an authenticated
+	// identity, such as the principal set by the auth middleware, a client
+	// picking its own value escapes its limit otherwise.
+	CallerHeader string
+
+	// Defaults to IsDropped.
+	IsDropped func(error) bool
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.CallerHeader == "" {
+		opts.CallerHeader = DefaultCallerHeader
+	}
+
+	if opts.MaxCallers <= 0 {
+		opts.MaxCallers = DefaultMaxCallers
+	}
+
+	if opts.IsDropped == nil {
+		opts.IsDropped = IsDropped
+	}
+
+	return opts
+}
+
+// IsDropped reports whether a call failed because the service is overloaded:
+// it timed out or was rejected by a limiter downstream.
+func IsDropped(err error) bool {
+	var oerr *Overloaded
+
+	return errors.IsTimeout(err) || errors.As(err, &oerr)
+}
+
+type keyedLimiter struct {
+	key     string
+	limiter Limiter
+}
+
+// keyedLimiters builds a limiter per key, keeping at most size of them when
+// size is positive.
+type keyedLimiters struct {
+	build func(string) Limiter
+	size  int
+
+	mu       sync.Mutex
+	limiters map[string]*list.Element
+	order    *list.List
+}
+
+func newKeyedLimiters(fn func(string) Limiter, size int) *keyedLimiters {
+	if fn == nil {
+		return nil
+	}
+
+	return &keyedLimiters{
+		build:    fn,
+		size:     size,
+		limiters: make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (kl *keyedLimiters) limiter(k string) Limiter {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	if e, ok := kl.limiters[k]; ok {
+		kl.order.MoveToFront(e)
+		return e.Value.(*keyedLimiter).limiter
+	}
+
+	l := kl.build(k)
+	kl.limiters[k] = kl.order.PushFront(&keyedLimiter{key: k, limiter: l})
+
+	// The calls in flight keep releasing the limiter they acquired.
+	for kl.size > 0 && kl.order.Len() > kl.size {
+		e := kl.order.Back()
+
+		kl.order.Remove(e)
+		delete(kl.limiters, e.Value.(*keyedLimiter).key)
+	}
+
+	return l
+}
+
+type builder struct {
+	opts Options
+
+	methods *keyedLimiters
+	callers *keyedLimiters
+}
+
+// NewMiddlewareBuilder returns a server TMiddlewareBuilder rejecting the
+// calls its limiters do not admit with an Overloaded exception. The limiters
+// of the callers and the global one are shared by every service the builder
+// is built for.
+func NewMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	opts = opts.withDefaults()
+
+	return &builder{
+		opts:    opts,
+		methods: newKeyedLimiters(opts.PerMethod, 0),
+		callers: newKeyedLimiters(opts.PerCaller, opts.MaxCallers),
+	}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{builder: b, service: namespace + "." + service}
+}
+
+type middleware struct {
+	*builder
+
+	service string
+}
+
+type scopedLimiter struct {
+	scope   string
+	limiter Limiter
+}
+
+// acquire admits the call through the limiters, from the narrowest to the
+// widest so that the tokens of the global one are not spent on calls a
+// caller limit rejects.
+func (m *middleware) acquire(ctx thrift.Context, mth string) (func(error), error) {
+	var ls []scopedLimiter
+
+	if m.callers != nil {
+		caller, _ := thrift.GetHeader(ctx, m.opts.CallerHeader)
+
+		ls = append(
+			ls,
+			scopedLimiter{
+				scope:   fmt.Sprintf("caller %q", caller),
+				limiter: m.callers.limiter(caller),
+			},
+		)
+	}
+
+	if m.methods != nil {
+		ls = append(
+			ls,
+			scopedLimiter{
+				scope:   "method",
+				limiter: m.methods.limiter(m.service + "/" + mth),
+			},
+		)
+	}
+
+	if m.opts.Global != nil {
+		ls = append(ls, scopedLimiter{scope: "global", limiter: m.opts.Global})
+	}
+
+	rs := make([]func(Outcome), 0, len(ls))
+
+	for _, sl := range ls {
+		r, ok := sl.limiter.Acquire()
+
+		if !ok {
+			for _, r := range rs {
+				r(Ignored)
+			}
+
+			return nil, NewOverloadedError(fmt.Sprintf("%s: %s limit reached", mth, sl.scope))
+		}
+
+		rs = append(rs, r)
+	}
+
+	return func(err error) {
+		o := Success
+
+		if m.opts.IsDropped(err) {
+			o = Dropped
+		}
+
+		for _, r := range rs {
+			r(o)
+		}
+	}, nil
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	release, err := m.acquire(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req)
+	release(err)
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	release, err := m.acquire(ctx, mth)
+
+	if err != nil {
+		return err
+	}
+
+	err = next(ctx, req)
+	release(err)
+
+	return err
+}
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	release, err := m.acquire(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, s)
+	release(err)
+
+	return res, err
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	release, err := m.acquire(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, s)
+	release(err)
+
+	return res, err
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	release, err := m.acquire(ctx, mth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next(ctx, req, is, os)
+	release(err)
+
+	return res, err
+}
+
+type clientBuilder struct{}
+
+// NewClientMiddlewareBuilder returns a client TMiddlewareBuilder turning the
+// OVERLOADED TApplicationExceptions back into Overloaded exceptions. It must
+// be nested in the middlewares relying on exception.IsSafe, such as retry.
+func NewClientMiddlewareBuilder() thrift.TMiddlewareBuilder {
+	return clientBuilder{}
+}
+
+func (clientBuilder) Build(string, string) thrift.TMiddleware {
+	return clientMiddleware{}
+}
+
+type clientMiddleware struct{}
+
+func decodeError(err error) error {
+	var (
+		aerr thrift.TApplicationException
+		oerr *Overloaded
+	)
+
+	if errors.As(err, &aerr) && aerr.TypeId() == thrift.OVERLOADED && !errors.As(err, &oerr) {
+		return newOverloadedError(aerr)
+	}
+
+	return err
+}
+
+func (clientMiddleware) HandleBinaryRequest(ctx thrift.Context, _ string, _ int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	res, err := next(ctx, req)
+	return res, decodeError(err)
+}
+
+func (clientMiddleware) HandleUnaryRequest(ctx thrift.Context, _ string, _ int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	return decodeError(next(ctx, req))
+}
+
+func (clientMiddleware) HandleInboundStream(ctx thrift.Context, _ string, _ int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	res, err := next(ctx, req, s)
+	return res, decodeError(err)
+}
+
+func (clientMiddleware) HandleOutboundStream(ctx thrift.Context, _ string, _ int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	res, err := next(ctx, req, s)
+	return res, decodeError(err)
+}
+
+func (clientMiddleware) HandleBidiStream(ctx thrift.Context, _ string, _ int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	res, err := next(ctx, req, is, os)
+	return res, decodeError(err)
+}
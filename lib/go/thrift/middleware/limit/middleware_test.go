@@ -0,0 +1,143 @@
+package limit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/exception"
+)
+
+func callerContext(caller string) thrift.Context {
+	return thrift.AddReadTHeaderToContext(
+		context.Background(),
+		thrift.THeaderMap{DefaultCallerHeader: caller},
+	)
+}
+
+func TestMiddleware_Reject(t *testing.T) {
+	var (
+		m = NewMiddlewareBuilder(
+			Options{
+				PerCaller: func(string) Limiter { return NewConcurrencyLimiter(1) },
+				PerMethod: func(string) Limiter { return NewConcurrencyLimiter(2) },
+			},
+		).Build("limit.test", "Service")
+
+		released = make(chan struct{})
+		blocked  = make(chan struct{})
+	)
+
+	block := func(ctx thrift.Context) {
+		go m.HandleBinaryRequest(
+			ctx,
+			"get",
+			1,
+			nil,
+			func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+				blocked <- struct{}{}
+				<-released
+
+				return nil, nil
+			},
+		)
+
+		<-blocked
+	}
+
+	call := func(ctx thrift.Context, mth string) error {
+		_, err := m.HandleBinaryRequest(
+			ctx,
+			mth,
+			2,
+			nil,
+			func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) { return nil, nil },
+		)
+
+		return err
+	}
+
+	block(callerContext("foo"))
+
+	err := call(callerContext("foo"), "list")
+
+	assert.True(t, exception.IsSafe(err))
+	assert.EqualError(t, err, `list: caller "foo" limit reached`)
+
+	var (
+		aerr thrift.TApplicationException
+		oerr *Overloaded
+	)
+
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, int32(thrift.OVERLOADED), aerr.TypeId())
+
+	require.ErrorAs(t, err, &oerr)
+	assert.Equal(t, `list: caller "foo" limit reached`, oerr.GetMessage())
+
+	block(callerContext("bar"))
+
+	assert.EqualError(t, call(callerContext("buz"), "get"), "get: method limit reached")
+	assert.NoError(t, call(callerContext("buz"), "list"))
+
+	close(released)
+}
+
+func TestKeyedLimiters_MaxSize(t *testing.T) {
+	var built []string
+
+	kl := newKeyedLimiters(
+		func(k string) Limiter {
+			built = append(built, k)
+			return NewConcurrencyLimiter(1)
+		},
+		2,
+	)
+
+	foo := kl.limiter("foo")
+	kl.limiter("bar")
+
+	assert.Same(t, foo, kl.limiter("foo"))
+
+	// bar is the least recently used caller, it makes room for buz.
+	kl.limiter("buz")
+	kl.limiter("bar")
+
+	assert.Equal(t, []string{"foo", "bar", "buz", "bar"}, built)
+	assert.Len(t, kl.limiters, 2)
+	assert.Equal(t, 2, kl.order.Len())
+}
+
+func TestClientMiddleware(t *testing.T) {
+	m := NewClientMiddlewareBuilder().Build("limit.test", "Service")
+
+	err := m.HandleUnaryRequest(
+		context.Background(),
+		"notify",
+		1,
+		nil,
+		func(thrift.Context, thrift.TRequest) error {
+			return thrift.NewTApplicationException(thrift.OVERLOADED, "notify: global limit reached")
+		},
+	)
+
+	var oerr *Overloaded
+
+	require.ErrorAs(t, err, &oerr)
+	assert.True(t, exception.IsSafe(err))
+
+	err = m.HandleUnaryRequest(
+		context.Background(),
+		"notify",
+		1,
+		nil,
+		func(thrift.Context, thrift.TRequest) error {
+			return thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "boom")
+		},
+	)
+
+	assert.False(t, exception.IsSafe(err))
+}
@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+const (
+	OutcomeOK                = "ok"
+	OutcomeDeclaredException = "declared_exception"
+	OutcomeTransportError    = "transport_error"
+	OutcomeProtocolError     = "protocol_error"
+)
+
+var applicationExceptionOutcomes = map[int32]string{
+	thrift.UNKNOWN_APPLICATION_EXCEPTION:  "unknown_application_exception",
+	thrift.UNKNOWN_METHOD:                 "unknown_method",
+	thrift.INVALID_MESSAGE_TYPE_EXCEPTION: "invalid_message_type",
+	thrift.WRONG_METHOD_NAME:              "wrong_method_name",
+	thrift.BAD_SEQUENCE_ID:                "bad_sequence_id",
+	thrift.MISSING_RESULT:                 "missing_result",
+	thrift.INTERNAL_ERROR:                 "internal_error",
+	thrift.PROTOCOL_ERROR:                 "protocol_error",
+	thrift.INTERNAL_TIME_OUT_ERROR:        "internal_time_out_error",
+	thrift.INVALID_TRANSFORM:              "invalid_transform",
+	thrift.INVALID_PROTOCOL:               "invalid_protocol",
+	thrift.UNSUPPORTED_CLIENT_TYPE:        "unsupported_client_type",
+	thrift.METHOD_NOT_IMPLEMENTED:         "method_not_implemented",
+	thrift.OVERLOADED:                     "overloaded",
+	thrift.UNAUTHENTICATED:                "unauthenticated",
+	thrift.PERMISSION_DENIED:              "permission_denied",
+}
+
+// Outcome classifies the result of a call: OutcomeOK, OutcomeDeclaredException,
+// OutcomeTransportError, OutcomeProtocolError or the snake cased type of the
+// application exception. A handler error which is neither is reported as the
+// application exception the processor turns it into.
+func Outcome(res thrift.TResponse, err error) string {
+	var (
+		terr thrift.TTransportException
+		perr thrift.TProtocolException
+		aerr thrift.TApplicationException
+	)
+
+	switch {
+	case err == nil:
+		if res != nil && res.GetError() != nil {
+			return OutcomeDeclaredException
+		}
+
+		return OutcomeOK
+	case errors.As(err, &terr):
+		return OutcomeTransportError
+	case errors.As(err, &perr):
+		return OutcomeProtocolError
+	case errors.As(err, &aerr):
+		if o, ok := applicationExceptionOutcomes[aerr.TypeId()]; ok {
+			return o
+		}
+
+		return applicationExceptionOutcomes[thrift.UNKNOWN_APPLICATION_EXCEPTION]
+	case errors.IsTimeout(err):
+		return applicationExceptionOutcomes[thrift.INTERNAL_TIME_OUT_ERROR]
+	}
+
+	return applicationExceptionOutcomes[thrift.INTERNAL_ERROR]
+}
+
+func payloadSize(s thrift.TStruct) float64 {
+	if s == nil {
+		return 0
+	}
+
+	buf, err := thrift.NewTSerializer().Write(context.Background(), s)
+
+	if err != nil {
+		return 0
+	}
+
+	return float64(len(buf))
+}
+
+type builder struct {
+	requests  *family
+	durations *family
+	inFlight  *family
+	reqSizes  *family
+	resSizes  *family
+}
+
+// Option configures the metrics recorded by the middlewares.
+type Option func(*options)
+
+type options struct {
+	payloadSizes bool
+}
+
+// WithPayloadSizes records the *_request_size_bytes and *_response_size_bytes
+// histograms. The arguments and the results are serialized once more to be
+// measured, which roughly doubles the serialization cost of every call.
+func WithPayloadSizes() Option {
+	return func(o *options) { o.payloadSizes = true }
+}
+
+func newBuilder(r *Registry, side string, opts []Option) *builder {
+	var (
+		o options
+
+		prefix = "thrift_" + side + "_"
+		labels = []string{"namespace", "service", "method"}
+	)
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b := builder{
+		requests: r.family(
+			prefix+"requests_total",
+			"Number of "+side+" calls by outcome.",
+			counterType,
+			nil,
+			append(labels, "outcome")...,
+		),
+		durations: r.family(
+			prefix+"request_duration_seconds",
+			"Latency of the "+side+" calls.",
+			histogramType,
+			DefaultDurationBuckets,
+			labels...,
+		),
+		inFlight: r.family(
+			prefix+"in_flight_requests",
+			"Number of "+side+" calls in progress.",
+			gaugeType,
+			nil,
+			labels...,
+		),
+	}
+
+	if o.payloadSizes {
+		b.reqSizes = r.family(
+			prefix+"request_size_bytes",
+			"Size of the "+side+" call arguments, binary encoded.",
+			histogramType,
+			DefaultSizeBuckets,
+			labels...,
+		)
+		b.resSizes = r.family(
+			prefix+"response_size_bytes",
+			"Size of the "+side+" call results, binary encoded.",
+			histogramType,
+			DefaultSizeBuckets,
+			labels...,
+		)
+	}
+
+	return &b
+}
+
+// NewServerMiddlewareBuilder returns a TMiddlewareBuilder recording the
+// thrift_server_* metrics of the services it is built for in r.
+func NewServerMiddlewareBuilder(r *Registry, opts ...Option) thrift.TMiddlewareBuilder {
+	return newBuilder(r, "server", opts)
+}
+
+// NewClientMiddlewareBuilder returns a TMiddlewareBuilder recording the
+// thrift_client_* metrics of the services it is built for in r.
+func NewClientMiddlewareBuilder(r *Registry, opts ...Option) thrift.TMiddlewareBuilder {
+	return newBuilder(r, "client", opts)
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{builder: b, namespace: namespace, service: service}
+}
+
+type middleware struct {
+	*builder
+
+	namespace string
+	service   string
+}
+
+func (m *middleware) start(mth string, req thrift.TRequest) func(thrift.TResponse, error) {
+	var (
+		t0     = time.Now()
+		labels = []string{m.namespace, m.service, mth}
+	)
+
+	m.inFlight.add(1, labels...)
+
+	if m.reqSizes != nil {
+		m.reqSizes.observe(payloadSize(req), labels...)
+	}
+
+	return func(res thrift.TResponse, err error) {
+		m.inFlight.add(-1, labels...)
+		m.durations.observe(time.Since(t0).Seconds(), labels...)
+		m.requests.add(1, append(labels, Outcome(res, err))...)
+
+		if m.resSizes != nil && err == nil && res != nil {
+			m.resSizes.observe(payloadSize(res), labels...)
+		}
+	}
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	done := m.start(mth, req)
+
+	res, err := next(ctx, req)
+	done(res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	done := m.start(mth, req)
+
+	err := next(ctx, req)
+	done(nil, err)
+
+	return err
+}
+
+// The streams are recorded for their initial call, the messages exchanged
+// afterwards are not.
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	done := m.start(mth, req)
+
+	res, err := next(ctx, req, s)
+	done(res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, s thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	done := m.start(mth, req)
+
+	res, err := next(ctx, req, s)
+	done(res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	done := m.start(mth, req)
+
+	res, err := next(ctx, req, is, os)
+	done(res, err)
+
+	return res, err
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+type response struct {
+	thrift.TResponse
+
+	err error
+}
+
+func (r *response) GetError() error { return r.err }
+
+func TestOutcome(t *testing.T) {
+	for _, tt := range []struct {
+		res  thrift.TResponse
+		err  error
+		want string
+	}{
+		{want: OutcomeOK},
+		{res: &response{}, want: OutcomeOK},
+		{res: &response{err: errors.New("declared")}, want: OutcomeDeclaredException},
+		{err: thrift.NewTTransportException(thrift.NOT_OPEN, ""), want: OutcomeTransportError},
+		{err: thrift.NewTProtocolException(errors.New("bad")), want: OutcomeProtocolError},
+		{err: thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, ""), want: "unknown_method"},
+		{err: context.DeadlineExceeded, want: "internal_time_out_error"},
+		{err: errors.New("boom"), want: "internal_error"},
+	} {
+		assert.Equal(t, tt.want, Outcome(tt.res, tt.err), "%v", tt.err)
+	}
+}
+
+func TestMiddleware_Exposition(t *testing.T) {
+	var (
+		r = NewRegistry()
+		m = NewServerMiddlewareBuilder(r, WithPayloadSizes()).Build("metrics.test", "Service")
+
+		ctx = context.Background()
+	)
+
+	for _, err := range []error{nil, thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "")} {
+		m.HandleBinaryRequest(
+			ctx,
+			"get",
+			1,
+			nil,
+			func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+				return nil, err
+			},
+		)
+	}
+
+	// Built again for the same service, the metrics are shared.
+	NewServerMiddlewareBuilder(r, WithPayloadSizes()).Build("metrics.test", "Service").HandleUnaryRequest(
+		ctx,
+		"notify",
+		2,
+		nil,
+		func(thrift.Context, thrift.TRequest) error { return nil },
+	)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	for _, l := range []string{
+		"# TYPE thrift_server_requests_total counter",
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="get",outcome="ok"} 1`,
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="get",outcome="internal_error"} 1`,
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="notify",outcome="ok"} 1`,
+		"# TYPE thrift_server_request_duration_seconds histogram",
+		`thrift_server_request_duration_seconds_bucket{namespace="metrics.test",service="Service",method="get",le="+Inf"} 2`,
+		`thrift_server_request_duration_seconds_count{namespace="metrics.test",service="Service",method="get"} 2`,
+		`thrift_server_in_flight_requests{namespace="metrics.test",service="Service",method="get"} 0`,
+		`thrift_server_request_size_bytes_bucket{namespace="metrics.test",service="Service",method="get",le="64"} 2`,
+	} {
+		assert.Contains(t, body, l+"\n")
+	}
+
+	assert.NotContains(t, body, "thrift_server_response_size_bytes")
+}
+
+func TestMiddleware_NoPayloadSizes(t *testing.T) {
+	var (
+		r = NewRegistry()
+		m = NewClientMiddlewareBuilder(r).Build("metrics.test", "Service")
+	)
+
+	m.HandleUnaryRequest(
+		context.Background(),
+		"notify",
+		1,
+		nil,
+		func(thrift.Context, thrift.TRequest) error { return nil },
+	)
+
+	var buf strings.Builder
+
+	_, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "thrift_client_requests_total")
+	assert.NotContains(t, buf.String(), "size_bytes")
+}
+
+func TestMiddleware_Streams(t *testing.T) {
+	var (
+		r = NewRegistry()
+		m = NewServerMiddlewareBuilder(r).Build("metrics.test", "Service").(thrift.TStreamingMiddleware)
+
+		ctx = context.Background()
+	)
+
+	m.HandleInboundStream(
+		ctx,
+		"upload",
+		1,
+		nil,
+		nil,
+		func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error) {
+			return nil, nil
+		},
+	)
+	m.HandleOutboundStream(
+		ctx,
+		"watch",
+		2,
+		nil,
+		nil,
+		func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error) {
+			return nil, errors.New("boom")
+		},
+	)
+	m.HandleBidiStream(
+		ctx,
+		"chat",
+		3,
+		nil,
+		nil,
+		nil,
+		func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error) {
+			return nil, nil
+		},
+	)
+
+	var buf strings.Builder
+
+	_, err := r.WriteTo(&buf)
+	require.NoError(t, err)
+
+	for _, l := range []string{
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="upload",outcome="ok"} 1`,
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="watch",outcome="internal_error"} 1`,
+		`thrift_server_requests_total{namespace="metrics.test",service="Service",method="chat",outcome="ok"} 1`,
+		`thrift_server_request_duration_seconds_count{namespace="metrics.test",service="Service",method="watch"} 1`,
+	} {
+		assert.Contains(t, buf.String(), l+"\n")
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	require.Equal(
+		t,
+		`{a="x\"y\\z\n",le="1"}`,
+		formatLabels([]string{"a"}, []string{"x\"y\\z\n"}, "le", "1"),
+	)
+}
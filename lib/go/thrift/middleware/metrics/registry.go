@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	DefaultDurationBuckets = []float64{
+		.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+	}
+
+	DefaultSizeBuckets = []float64{
+		64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304,
+	}
+)
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+type series struct {
+	labels []string
+
+	mu      sync.Mutex
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (f *family) with(values ...string) *series {
+	k := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.series[k]
+
+	if !ok {
+		s = &series{labels: values}
+
+		if f.typ == histogramType {
+			s.buckets = make([]uint64, len(f.buckets))
+		}
+
+		f.series[k] = s
+	}
+
+	return s
+}
+
+func (f *family) add(v float64, values ...string) {
+	s := f.with(values...)
+
+	s.mu.Lock()
+	s.value += v
+	s.mu.Unlock()
+}
+
+func (f *family) observe(v float64, values ...string) {
+	s := f.with(values...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.value += v
+	s.count++
+
+	for i, b := range f.buckets {
+		if v <= b {
+			s.buckets[i]++
+		}
+	}
+}
+
+// Registry holds the metrics recorded by the middlewares, it is an
+// http.Handler exposing them in the Prometheus text format.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// family returns the family registered under name, registering it first if
+// needed, so that middlewares built many times share their metrics.
+func (r *Registry) family(name, help string, typ metricType, buckets []float64, labels ...string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		return f
+	}
+
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+
+	r.families[name] = f
+
+	return f
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+
+	fs := make([]*family, 0, len(r.families))
+
+	for _, f := range r.families {
+		fs = append(fs, f)
+	}
+
+	r.mu.Unlock()
+
+	sort.Slice(fs, func(i, j int) bool { return fs[i].name < fs[j].name })
+
+	var (
+		bw = bufio.NewWriter(w)
+		cw = &countingWriter{w: bw}
+	)
+
+	for _, f := range fs {
+		f.writeTo(cw)
+	}
+
+	err := bw.Flush()
+
+	if cw.err != nil {
+		err = cw.err
+	}
+
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(w.w, format, args...)
+
+	w.n += int64(n)
+	w.err = err
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('{')
+
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		fmt.Fprintf(&b, "%s=\"%s\"", n, labelValueEscaper.Replace(values[i]))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+
+		fmt.Fprintf(&b, "%s=\"%s\"", extra[i], extra[i+1])
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (f *family) writeTo(w *countingWriter) {
+	f.mu.Lock()
+
+	ss := make([]*series, 0, len(f.series))
+
+	for _, s := range f.series {
+		ss = append(ss, s)
+	}
+
+	f.mu.Unlock()
+
+	if len(ss) == 0 {
+		return
+	}
+
+	sort.Slice(ss, func(i, j int) bool {
+		return strings.Join(ss[i].labels, "\xff") < strings.Join(ss[j].labels, "\xff")
+	})
+
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+
+	for _, s := range ss {
+		s.mu.Lock()
+
+		switch f.typ {
+		case histogramType:
+			for i, b := range f.buckets {
+				w.printf(
+					"%s_bucket%s %d\n",
+					f.name,
+					formatLabels(f.labels, s.labels, "le", formatFloat(b)),
+					s.buckets[i],
+				)
+			}
+
+			w.printf("%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.labels, "le", "+Inf"), s.count)
+			w.printf("%s_sum%s %s\n", f.name, formatLabels(f.labels, s.labels), formatFloat(s.value))
+			w.printf("%s_count%s %d\n", f.name, formatLabels(f.labels, s.labels), s.count)
+		default:
+			w.printf("%s%s %s\n", f.name, formatLabels(f.labels, s.labels), formatFloat(s.value))
+		}
+
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,294 @@
+package mirror
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/middleware/record"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+const (
+	DefaultTimeout     = 5 * time.Second
+	DefaultMaxInFlight = 64
+)
+
+// Divergence is a call whose shadow outcome differs from the primary one,
+// or whose shadow call failed.
+type Divergence struct {
+	Service string
+	Method  string
+	Reason  string
+
+	Primary *record.Entry
+	// Shadow is nil when the shadow call failed.
+	Shadow *record.Entry
+}
+
+// Reporter reports the divergences.
+type Reporter func(Divergence)
+
+// DefaultReporter logs the divergences with the standard logger.
+func DefaultReporter(d Divergence) {
+	log.Printf("thrift: %s/%s: shadow diverged: %s", d.Service, d.Method, d.Reason)
+}
+
+type Options struct {
+	// Shadow is the client the calls are mirrored to. The calls to the
+	// functions not registered along with their service are only mirrored
+	// over the binary and compact protocols, they fail otherwise.
+	Shadow thrift.TClient
+
+	// Rate is the fraction of the eligible calls mirrored, from 0, none of
+	// them, to 1, all of them.
+	Rate float64
+	// Methods mirrored on top of the oneway and ReadOnly ones, the calls to
+	// the other functions may have side effects and are not mirrored.
+	Methods []string
+
+	// Defaults to DefaultReporter.
+	Reporter Reporter
+	// Timeout of the shadow calls.
+	Timeout time.Duration
+	// MaxInFlight bounds the number of shadow calls running, the calls
+	// happening beyond it are not mirrored.
+	MaxInFlight int
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Reporter == nil {
+		opts.Reporter = DefaultReporter
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = DefaultMaxInFlight
+	}
+
+	return opts
+}
+
+type kind int
+
+const (
+	client kind = iota
+	server
+)
+
+// Builder is a TMiddlewareBuilder mirroring calls. The shadow calls of all
+// the middlewares it builds share its MaxInFlight slots.
+type Builder struct {
+	opts    Options
+	kind    kind
+	methods map[string]bool
+	slots   chan struct{}
+
+	wg sync.WaitGroup
+}
+
+func newBuilder(opts Options, k kind) *Builder {
+	opts = opts.withDefaults()
+
+	methods := make(map[string]bool, len(opts.Methods))
+
+	for _, m := range opts.Methods {
+		methods[m] = true
+	}
+
+	return &Builder{
+		opts:    opts,
+		kind:    k,
+		methods: methods,
+		slots:   make(chan struct{}, opts.MaxInFlight),
+	}
+}
+
+// NewClientMiddlewareBuilder returns a client TMiddlewareBuilder mirroring
+// the calls to the Shadow client of opts, with the headers sent along with
+// them. The shadow calls are made once the primary ones returned and never
+// change their outcome, the divergences are reported.
+func NewClientMiddlewareBuilder(opts Options) *Builder {
+	return newBuilder(opts, client)
+}
+
+// NewServerMiddlewareBuilder returns a server TMiddlewareBuilder mirroring
+// the calls received to the Shadow client of opts, with the headers read
+// along with them. The shadow calls are made once the primary ones were
+// processed and never change their outcome, the divergences are reported.
+func NewServerMiddlewareBuilder(opts Options) *Builder {
+	return newBuilder(opts, server)
+}
+
+func (b *Builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{
+		Builder:       b,
+		service:       service,
+		canonicalName: namespace + "." + service,
+		target:        record.NewClientTarget(b.opts.Shadow),
+	}
+}
+
+type middleware struct {
+	thrift.TNopMiddleware
+
+	*Builder
+
+	service string
+	// canonicalName of the service lets the target find the structs of the
+	// calls, to send them over any protocol.
+	canonicalName string
+	target        record.Target
+}
+
+// Wait waits for the shadow calls in flight, to let them end before the
+// Shadow client is closed.
+func (b *Builder) Wait() {
+	b.wg.Wait()
+}
+
+func (m *middleware) sampled() bool {
+	return m.opts.Rate >= 1 || rand.Float64() < m.opts.Rate
+}
+
+func (m *middleware) eligible(ctx thrift.Context, mth string) bool {
+	if m.methods[mth] {
+		return true
+	}
+
+	md, ok := thrift.GetMethodDefinition(ctx)
+
+	return ok && rpc.IsReadOnly(md.Service, md.Function)
+}
+
+// entry returns the entry of the call to mirror, or nil if it should not.
+func (m *middleware) entry(ctx thrift.Context, mth string, req thrift.TRequest) *record.Entry {
+	if !m.sampled() {
+		return nil
+	}
+
+	args, err := thrift.NewTSerializer().Write(ctx, req)
+
+	if err != nil {
+		return nil
+	}
+
+	keys := thrift.GetWriteHeaderList(ctx)
+
+	if m.kind == server {
+		keys = thrift.GetReadHeaderList(ctx)
+	}
+
+	e := record.Entry{Service: m.canonicalName, Method: mth, Args: args}
+
+	for _, k := range keys {
+		// The shadow call gets a deadline of its own.
+		if k == thrift.THeaderDeadlineKey {
+			continue
+		}
+
+		if v, ok := thrift.GetHeader(ctx, k); ok {
+			if e.Headers == nil {
+				e.Headers = make(map[string]string)
+			}
+
+			e.Headers[k] = v
+		}
+	}
+
+	return &e
+}
+
+// mirror makes the shadow call of primary in the background, when a slot
+// is free.
+func (m *middleware) mirror(primary *record.Entry) {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	m.wg.Add(1)
+
+	go func() {
+		defer func() {
+			<-m.slots
+			m.wg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.opts.Timeout)
+		defer cancel()
+
+		shadow, err := m.target.Call(ctx, primary)
+
+		d := Divergence{
+			Service: m.service,
+			Method:  primary.Method,
+			Primary: primary,
+			Shadow:  shadow,
+		}
+
+		switch {
+		case err != nil:
+			d.Reason = "shadow call failed: " + err.Error()
+		case !primary.Oneway:
+			var ok bool
+
+			if d.Reason, ok = record.Compare(primary, shadow); !ok {
+				return
+			}
+		default:
+			return
+		}
+
+		m.opts.Reporter(d)
+	}()
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	if !m.eligible(ctx, mth) {
+		return next(ctx, req)
+	}
+
+	// The request is serialized first, the handler may change it.
+	e := m.entry(ctx, mth, req)
+
+	res, err := next(ctx, req)
+
+	if e == nil {
+		return res, err
+	}
+
+	var aerr thrift.TApplicationException
+
+	// The primary call failed before getting an outcome to compare with.
+	if m.kind == client && err != nil && !errors.As(err, &aerr) {
+		return res, err
+	}
+
+	e.SetOutcome(ctx, res, err)
+	m.mirror(e)
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	e := m.entry(ctx, mth, req)
+
+	err := next(ctx, req)
+
+	if e != nil {
+		e.Oneway = true
+		m.mirror(e)
+	}
+
+	return err
+}
@@ -0,0 +1,432 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+type getResult struct {
+	Success *string
+}
+
+func (r *getResult) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("get_result")
+
+	if r.Success != nil {
+		p.WriteFieldBegin("success", thrift.STRING, 0)
+		p.WriteString(*r.Success)
+		p.WriteFieldEnd()
+	}
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (r *getResult) Read(p thrift.TProtocol) error {
+	v, ok, err := readString(p, 0)
+
+	if ok {
+		r.Success = &v
+	}
+
+	return err
+}
+
+func (r *getResult) String() string         { return fmt.Sprintf("%+v", *r) }
+func (r *getResult) GetResult() interface{} { return r.Success }
+func (r *getResult) GetError() error        { return nil }
+
+var getResultDefinition = thrift.StructDefinition{
+	Namespace:           "mirror.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "get_result"},
+}
+
+func (*getResult) StructDefinition() thrift.StructDefinition { return getResultDefinition }
+
+type getArgs struct {
+	Key string
+}
+
+func (a *getArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("get_args")
+	p.WriteFieldBegin("key", thrift.STRING, 1)
+	p.WriteString(a.Key)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (a *getArgs) Read(p thrift.TProtocol) error {
+	var err error
+
+	a.Key, _, err = readString(p, 1)
+
+	return err
+}
+
+func (*getArgs) String() string { return "get_args" }
+
+var getArgsDefinition = thrift.StructDefinition{
+	Namespace:           "mirror.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "get_args"},
+}
+
+func (*getArgs) StructDefinition() thrift.StructDefinition { return getArgsDefinition }
+
+// readString reads a struct whose only field read is the string field id.
+func readString(p thrift.TProtocol, id int16) (v string, ok bool, err error) {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return "", false, err
+	}
+
+	for {
+		_, typeID, fid, err := p.ReadFieldBegin()
+
+		if err != nil {
+			return "", false, err
+		}
+
+		if typeID == thrift.STOP {
+			break
+		}
+
+		if fid == id && typeID == thrift.STRING {
+			v, err = p.ReadString()
+			ok = true
+		} else {
+			err = p.Skip(typeID)
+		}
+
+		if err != nil {
+			return "", false, err
+		}
+
+		p.ReadFieldEnd()
+	}
+
+	return v, ok, p.ReadStructEnd()
+}
+
+var testService = thrift.ServiceDefinition{
+	Namespace:           "mirror.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "Service"},
+	Functions: []thrift.FunctionDefinition{
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "get",
+				StructuredAnnotations: []thrift.RegistrableStruct{&rpc.ReadOnly{}},
+			},
+			Args:   getArgsDefinition,
+			Result: &getResultDefinition,
+		},
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "update"}},
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "notify"}, IsOneway: true},
+	},
+}
+
+func init() {
+	thrift.RegisterStruct((*getArgs)(nil))
+	thrift.RegisterStruct((*getResult)(nil))
+	thrift.RegisterService(testService)
+}
+
+// shadow answers the calls with value, or fails them with err.
+type shadow struct {
+	value string
+	err   error
+
+	mu      sync.Mutex
+	calls   []string
+	headers []string
+}
+
+func (s *shadow) record(ctx thrift.Context, mth string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, mth)
+
+	if v, ok := thrift.GetHeader(ctx, thrift.THeaderCallerKey); ok {
+		s.headers = append(s.headers, v)
+	}
+}
+
+func (s *shadow) CallBinary(ctx thrift.Context, mth string, _ thrift.TRequest, res thrift.TResponse) error {
+	s.record(ctx, mth)
+
+	if s.err != nil {
+		return s.err
+	}
+
+	v := s.value
+
+	data, err := thrift.NewTSerializer().Write(ctx, &getResult{Success: &v})
+
+	if err != nil {
+		return err
+	}
+
+	return thrift.NewTDeserializer().Read(res, data)
+}
+
+func (s *shadow) CallUnary(ctx thrift.Context, mth string, _ thrift.TRequest) error {
+	s.record(ctx, mth)
+
+	return s.err
+}
+
+func next(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+	v := "foo"
+
+	return &getResult{Success: &v}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		mth     string
+		methods []string
+		shadow  *shadow
+
+		wantCalls   []string
+		wantReasons []string
+	}{
+		{
+			name:      "read only",
+			mth:       "get",
+			shadow:    &shadow{value: "foo"},
+			wantCalls: []string{"get"},
+		},
+		{
+			name:        "diverging",
+			mth:         "get",
+			shadow:      &shadow{value: "bar"},
+			wantCalls:   []string{"get"},
+			wantReasons: []string{"result differs"},
+		},
+		{
+			name:        "shadow failure",
+			mth:         "get",
+			shadow:      &shadow{err: errors.New("connection refused")},
+			wantCalls:   []string{"get"},
+			wantReasons: []string{"shadow call failed: connection refused"},
+		},
+		{
+			name:        "shadow exception",
+			mth:         "get",
+			shadow:      &shadow{err: thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, "get")},
+			wantCalls:   []string{"get"},
+			wantReasons: []string{`error "get", want ""`},
+		},
+		{name: "not read only", mth: "update", shadow: &shadow{value: "foo"}},
+		{
+			name:      "opted in",
+			mth:       "update",
+			methods:   []string{"update"},
+			shadow:    &shadow{value: "foo"},
+			wantCalls: []string{"update"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				reasons []string
+
+				b = NewClientMiddlewareBuilder(
+					Options{
+						Shadow:  tt.shadow,
+						Rate:    1,
+						Methods: tt.methods,
+						Reporter: func(d Divergence) {
+							mu.Lock()
+							reasons = append(reasons, d.Reason)
+							mu.Unlock()
+						},
+					},
+				)
+				m = b.Build("mirror.test", "Service")
+
+				ctx = thrift.SetWriteHeaderList(
+					thrift.SetHeader(
+						thrift.WithMethod(context.Background(), testService, tt.mth),
+						thrift.THeaderCallerKey,
+						"test",
+					),
+					[]string{thrift.THeaderCallerKey},
+				)
+			)
+
+			res, err := m.HandleBinaryRequest(ctx, tt.mth, 1, &getArgs{}, next)
+			require.NoError(t, err)
+			assert.Equal(t, "foo", *res.(*getResult).Success)
+
+			b.Wait()
+
+			assert.Equal(t, tt.wantCalls, tt.shadow.calls)
+			assert.Equal(t, tt.wantReasons, reasons)
+
+			if len(tt.wantCalls) > 0 {
+				assert.Equal(t, []string{"test"}, tt.shadow.headers)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Oneway(t *testing.T) {
+	var (
+		s shadow
+		b = NewServerMiddlewareBuilder(Options{Shadow: &s, Rate: 1})
+		m = b.Build("mirror.test", "Service")
+
+		ctx = thrift.AddReadTHeaderToContext(
+			thrift.WithMethod(context.Background(), testService, "notify"),
+			thrift.THeaderMap{thrift.THeaderCallerKey: "test", thrift.THeaderDeadlineKey: "1s"},
+		)
+	)
+
+	err := m.HandleUnaryRequest(
+		ctx,
+		"notify",
+		1,
+		&getArgs{},
+		func(thrift.Context, thrift.TRequest) error { return nil },
+	)
+	require.NoError(t, err)
+
+	b.Wait()
+
+	assert.Equal(t, []string{"notify"}, s.calls)
+	assert.Equal(t, []string{"test"}, s.headers)
+}
+
+func TestMiddleware_MaxInFlight(t *testing.T) {
+	var (
+		s       shadow
+		release = make(chan struct{})
+
+		b = NewClientMiddlewareBuilder(
+			Options{Shadow: &blockingShadow{shadow: &s, release: release}, Rate: 1, MaxInFlight: 1},
+		)
+		m = b.Build("mirror.test", "Service")
+	)
+
+	for i := 0; i < 3; i++ {
+		_, err := m.HandleBinaryRequest(thrift.WithMethod(context.Background(), testService, "get"), "get", 1, &getArgs{}, next)
+		require.NoError(t, err)
+	}
+
+	close(release)
+	b.Wait()
+
+	assert.Equal(t, []string{"get"}, s.calls)
+}
+
+func TestMiddleware_NoRate(t *testing.T) {
+	var (
+		s shadow
+		b = NewClientMiddlewareBuilder(Options{Shadow: &s})
+		m = b.Build("mirror.test", "Service")
+	)
+
+	_, err := m.HandleBinaryRequest(thrift.WithMethod(context.Background(), testService, "get"), "get", 1, &getArgs{}, next)
+	require.NoError(t, err)
+
+	b.Wait()
+
+	assert.Empty(t, s.calls)
+}
+
+// jsonShadow is a shadow reading the calls and writing their responses with
+// the JSON protocol, it answers the key of the calls.
+type jsonShadow struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (s *jsonShadow) CallBinary(ctx thrift.Context, mth string, req thrift.TRequest, res thrift.TResponse) error {
+	var (
+		p    = thrift.NewTJSONProtocol(thrift.NewTMemoryBuffer())
+		args getArgs
+	)
+
+	if err := req.Write(p); err != nil {
+		return err
+	}
+
+	if err := p.Flush(); err != nil {
+		return err
+	}
+
+	if err := args.Read(p); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = append(s.keys, args.Key)
+	s.mu.Unlock()
+
+	if err := (&getResult{Success: &args.Key}).Write(p); err != nil {
+		return err
+	}
+
+	if err := p.Flush(); err != nil {
+		return err
+	}
+
+	return res.Read(p)
+}
+
+func (s *jsonShadow) CallUnary(thrift.Context, string, thrift.TRequest) error { return nil }
+
+// TestMiddleware_JSONShadow verifies that the calls are mirrored as they
+// are to a shadow using a protocol telling the strings and the binaries
+// apart.
+func TestMiddleware_JSONShadow(t *testing.T) {
+	var (
+		s       jsonShadow
+		reasons []string
+
+		b = NewClientMiddlewareBuilder(
+			Options{
+				Shadow:   &s,
+				Rate:     1,
+				Reporter: func(d Divergence) { reasons = append(reasons, d.Reason) },
+			},
+		)
+		m = b.Build("mirror.test", "Service")
+	)
+
+	_, err := m.HandleBinaryRequest(thrift.WithMethod(context.Background(), testService, "get"), "get", 1, &getArgs{Key: "foo"}, next)
+	require.NoError(t, err)
+
+	b.Wait()
+
+	assert.Equal(t, []string{"foo"}, s.keys)
+	assert.Empty(t, reasons)
+}
+
+type blockingShadow struct {
+	*shadow
+
+	release chan struct{}
+}
+
+func (s *blockingShadow) CallBinary(ctx thrift.Context, mth string, req thrift.TRequest, res thrift.TResponse) error {
+	<-s.release
+
+	s.shadow.value = "foo"
+
+	return s.shadow.CallBinary(ctx, mth, req, res)
+}
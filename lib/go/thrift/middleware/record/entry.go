@@ -0,0 +1,41 @@
+package record
+
+import (
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// SetOutcome sets the outcome of the call recorded by e: its response, when
+// it succeeded, or its error.
+func (e *Entry) SetOutcome(ctx thrift.Context, res thrift.TResponse, err error) {
+	e.setError(err)
+
+	if err != nil || res == nil {
+		return
+	}
+
+	if data, err := thrift.NewTSerializer().Write(ctx, res); err == nil {
+		e.Result = data
+		e.Exception = res.GetError() != nil
+	}
+}
+
+func (e *Entry) setError(err error) {
+	if err == nil {
+		return
+	}
+
+	var aerr thrift.TApplicationException
+
+	e.Error = err.Error()
+
+	switch {
+	case errors.As(err, &aerr):
+		e.ErrorType = aerr.TypeId()
+	case errors.IsTimeout(err):
+		e.ErrorType = thrift.INTERNAL_TIME_OUT_ERROR
+	default:
+		e.ErrorType = thrift.INTERNAL_ERROR
+	}
+}
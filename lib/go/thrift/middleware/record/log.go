@@ -0,0 +1,158 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// A log starts with magic, then holds every entry serialized with the
+// compact protocol, prefixed by its size as an uvarint.
+var magic = []byte("TREC\x01")
+
+// maxEntrySize bounds the size of the entries read from a log, it protects
+// against corrupted sizes.
+const maxEntrySize = 64 << 20
+
+// ErrInvalidLog is returned when reading something else than a log.
+var ErrInvalidLog = errors.New("record: invalid log")
+
+// Recorder records the entries of the calls.
+type Recorder interface {
+	Record(*Entry) error
+}
+
+// Writer writes entries to a log, it is safe for concurrent use. The entries
+// are buffered until Flush is called.
+type Writer struct {
+	mu sync.Mutex
+
+	w       *bufio.Writer
+	started bool
+
+	buf  *thrift.TMemoryBuffer
+	prot thrift.TProtocol
+	size [binary.MaxVarintLen64]byte
+}
+
+func NewWriter(w io.Writer) *Writer {
+	buf := thrift.NewTMemoryBuffer()
+
+	return &Writer{
+		w:    bufio.NewWriter(w),
+		buf:  buf,
+		prot: thrift.NewTCompactProtocolFactory().GetProtocol(buf),
+	}
+}
+
+// Record writes e to the log.
+func (w *Writer) Record(e *Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.started {
+		if _, err := w.w.Write(magic); err != nil {
+			return err
+		}
+
+		w.started = true
+	}
+
+	w.buf.Reset()
+
+	if err := e.Write(w.prot); err != nil {
+		return err
+	}
+
+	if err := w.prot.Flush(); err != nil {
+		return err
+	}
+
+	n := binary.PutUvarint(w.size[:], uint64(w.buf.Len()))
+
+	if _, err := w.w.Write(w.size[:n]); err != nil {
+		return err
+	}
+
+	_, err := w.w.Write(w.buf.Bytes())
+
+	return err
+}
+
+// Flush writes the buffered entries to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.w.Flush()
+}
+
+// Reader reads the entries of a log.
+type Reader struct {
+	r       *bufio.Reader
+	started bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read returns the next entry of the log, or io.EOF at its end.
+func (r *Reader) Read() (*Entry, error) {
+	if !r.started {
+		b := make([]byte, len(magic))
+
+		if _, err := io.ReadFull(r.r, b); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, ErrInvalidLog
+			}
+
+			return nil, err
+		}
+
+		if !bytes.Equal(b, magic) {
+			return nil, ErrInvalidLog
+		}
+
+		r.started = true
+	}
+
+	n, err := binary.ReadUvarint(r.r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n > maxEntrySize {
+		return nil, ErrInvalidLog
+	}
+
+	data := make([]byte, n)
+
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+
+	var (
+		buf = thrift.NewTMemoryBuffer()
+		e   Entry
+	)
+
+	buf.Write(data)
+
+	if err := e.Read(thrift.NewTCompactProtocolFactory().GetProtocol(buf)); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
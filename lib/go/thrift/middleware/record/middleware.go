@@ -0,0 +1,124 @@
+package record
+
+import (
+	"strings"
+	"time"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/duration"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/timestamp"
+)
+
+// DefaultOmittedHeaders are not recorded by default, they carry credentials.
+var DefaultOmittedHeaders = []string{"authorization"}
+
+type Options struct {
+	Recorder Recorder
+
+	// OmittedHeaders are not recorded, defaults to DefaultOmittedHeaders.
+	OmittedHeaders []string
+	// OnError is called with the errors of the Recorder, they do not fail
+	// the calls.
+	OnError func(error)
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.OmittedHeaders == nil {
+		opts.OmittedHeaders = DefaultOmittedHeaders
+	}
+
+	if opts.OnError == nil {
+		opts.OnError = func(error) {}
+	}
+
+	return opts
+}
+
+type builder struct {
+	opts    Options
+	omitted map[string]struct{}
+}
+
+// NewServerMiddlewareBuilder returns a server TMiddlewareBuilder recording
+// every call, with its headers, its arguments, its outcome and its timing,
+// to the Recorder of opts. The streams are not recorded.
+func NewServerMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	opts = opts.withDefaults()
+
+	omitted := make(map[string]struct{}, len(opts.OmittedHeaders))
+
+	for _, h := range opts.OmittedHeaders {
+		omitted[strings.ToLower(h)] = struct{}{}
+	}
+
+	return &builder{opts: opts, omitted: omitted}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{builder: b, service: namespace + "." + service}
+}
+
+type middleware struct {
+	thrift.TNopMiddleware
+
+	*builder
+
+	service string
+}
+
+func (m *middleware) entry(ctx thrift.Context, mth string, req thrift.TRequest) *Entry {
+	e := Entry{Service: m.service, Method: mth, Start: timestamp.Now()}
+
+	for _, k := range thrift.GetReadHeaderList(ctx) {
+		if _, ok := m.omitted[strings.ToLower(k)]; ok {
+			continue
+		}
+
+		if v, ok := thrift.GetHeader(ctx, k); ok {
+			if e.Headers == nil {
+				e.Headers = make(map[string]string)
+			}
+
+			e.Headers[k] = v
+		}
+	}
+
+	if req != nil {
+		if data, err := thrift.NewTSerializer().Write(ctx, req); err == nil {
+			e.Args = data
+		}
+	}
+
+	return &e
+}
+
+func (m *middleware) record(e *Entry) {
+	e.Duration = duration.New(time.Since(e.Start.ToTime()))
+
+	if err := m.opts.Recorder.Record(e); err != nil {
+		m.opts.OnError(err)
+	}
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	e := m.entry(ctx, mth, req)
+
+	res, err := next(ctx, req)
+
+	e.SetOutcome(ctx, res, err)
+	m.record(e)
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	e := m.entry(ctx, mth, req)
+	e.Oneway = true
+
+	err := next(ctx, req)
+
+	e.setError(err)
+	m.record(e)
+
+	return err
+}
@@ -0,0 +1,34 @@
+// Autogenerated by Thrift Compiler (2.7.0-upfluence)
+// DO NOT EDIT UNLESS YOU ARE SURE THAT YOU KNOW WHAT YOU ARE DOING
+
+package record
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/duration"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/timestamp"
+	"io"
+	"reflect"
+)
+
+// (needed to ensure safety because of naive import list construction.)
+var _ = thrift.ZERO
+var _ = fmt.Printf
+var _ = context.Background
+var _ = reflect.DeepEqual
+var _ = bytes.Equal
+var _ = io.EOF
+
+var _ = duration.GoUnusedProtection__
+var _ = timestamp.GoUnusedProtection__
+
+var GoUnusedProtection__ int
+
+const Namespace = "middleware.record"
+
+func init() {
+	thrift.RegisterStruct((*Entry)(nil))
+}
@@ -0,0 +1,750 @@
+// Autogenerated by Thrift Compiler (2.7.0-upfluence)
+// DO NOT EDIT UNLESS YOU ARE SURE THAT YOU KNOW WHAT YOU ARE DOING
+
+package record
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/duration"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/timestamp"
+	"io"
+	"reflect"
+)
+
+// (needed to ensure safety because of naive import list construction.)
+var _ = thrift.ZERO
+var _ = fmt.Printf
+var _ = context.Background
+var _ = reflect.DeepEqual
+var _ = bytes.Equal
+var _ = io.EOF
+
+var _ = duration.GoUnusedProtection__
+var _ = timestamp.GoUnusedProtection__
+
+// Attributes:
+//   - Method
+//   - Oneway
+//   - Headers
+//   - Args
+//   - Result
+//   - Exception
+//   - Error
+//   - ErrorType
+//   - Start
+//   - Duration
+//   - Service
+type Entry struct {
+	Method    string               `thrift:"method,1,required" db:"method" json:"method"`
+	Oneway    bool                 `thrift:"oneway,2" db:"oneway" json:"oneway"`
+	Headers   map[string]string    `thrift:"headers,3" db:"headers" json:"headers"`
+	Args      []byte               `thrift:"args,4" db:"args" json:"args"`
+	Result    []byte               `thrift:"result,5" db:"result" json:"result"`
+	Exception bool                 `thrift:"exception,6" db:"exception" json:"exception"`
+	Error     string               `thrift:"error,7" db:"error" json:"error"`
+	ErrorType int32                `thrift:"error_type,8" db:"error_type" json:"error_type"`
+	Start     *timestamp.Timestamp `thrift:"start,9" db:"start" json:"start,omitempty"`
+	Duration  *duration.Duration   `thrift:"duration,10" db:"duration" json:"duration,omitempty"`
+	Service   string               `thrift:"service,11" db:"service" json:"service"`
+}
+
+func NewEntry() *Entry {
+	return &Entry{}
+}
+
+var entryStructDefinition = thrift.StructDefinition{
+	Namespace: Namespace,
+	AnnotatedDefinition: thrift.AnnotatedDefinition{
+		Name:                  "Entry",
+		LegacyAnnotations:     map[string]string{},
+		StructuredAnnotations: []thrift.RegistrableStruct{},
+	},
+	Fields: []thrift.FieldDefinition{
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "method",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "oneway",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "headers",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "args",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "result",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "exception",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "error",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "error_type",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "start",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "duration",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "service",
+				LegacyAnnotations:     map[string]string{},
+				StructuredAnnotations: []thrift.RegistrableStruct{},
+			},
+		},
+	},
+}
+
+func (p *Entry) StructDefinition() thrift.StructDefinition {
+	return entryStructDefinition
+}
+
+func (p *Entry) GetMethod() string {
+	return p.Method
+}
+
+func (p *Entry) SetMethod(v string) {
+	p.Method = v
+}
+
+func (p *Entry) GetOneway() bool {
+	return p.Oneway
+}
+
+func (p *Entry) SetOneway(v bool) {
+	p.Oneway = v
+}
+
+func (p *Entry) GetHeaders() map[string]string {
+	return p.Headers
+}
+
+func (p *Entry) SetHeaders(v map[string]string) {
+	p.Headers = v
+}
+
+func (p *Entry) GetArgs() []byte {
+	return p.Args
+}
+
+func (p *Entry) SetArgs(v []byte) {
+	p.Args = v
+}
+
+func (p *Entry) GetResult() []byte {
+	return p.Result
+}
+
+func (p *Entry) SetResult(v []byte) {
+	p.Result = v
+}
+
+func (p *Entry) GetException() bool {
+	return p.Exception
+}
+
+func (p *Entry) SetException(v bool) {
+	p.Exception = v
+}
+
+func (p *Entry) GetError() string {
+	return p.Error
+}
+
+func (p *Entry) SetError(v string) {
+	p.Error = v
+}
+
+func (p *Entry) GetErrorType() int32 {
+	return p.ErrorType
+}
+
+func (p *Entry) SetErrorType(v int32) {
+	p.ErrorType = v
+}
+
+var Entry_Start_DEFAULT *timestamp.Timestamp
+
+func (p *Entry) GetStart() *timestamp.Timestamp {
+	if !p.IsSetStart() {
+		return Entry_Start_DEFAULT
+	}
+	return p.Start
+}
+
+func (p *Entry) SetStart(v *timestamp.Timestamp) {
+	p.Start = v
+}
+
+var Entry_Duration_DEFAULT *duration.Duration
+
+func (p *Entry) GetDuration() *duration.Duration {
+	if !p.IsSetDuration() {
+		return Entry_Duration_DEFAULT
+	}
+	return p.Duration
+}
+
+func (p *Entry) SetDuration(v *duration.Duration) {
+	p.Duration = v
+}
+
+func (p *Entry) GetService() string {
+	return p.Service
+}
+
+func (p *Entry) SetService(v string) {
+	p.Service = v
+}
+func (p *Entry) IsSetStart() bool {
+	return p.Start != nil
+}
+
+func (p *Entry) IsSetDuration() bool {
+	return p.Duration != nil
+}
+
+func (p *Entry) Read(iprot thrift.TProtocol) error {
+	if _, err := iprot.ReadStructBegin(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T read error: ", p), err)
+	}
+
+	var issetMethod bool = false
+
+	for {
+		_, fieldTypeId, fieldId, err := iprot.ReadFieldBegin()
+		if err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T field %d read error: ", p, fieldId), err)
+		}
+		if fieldTypeId == thrift.STOP {
+			break
+		}
+		switch fieldId {
+		case 1:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField1(iprot); err != nil {
+					return err
+				}
+				issetMethod = true
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 2:
+			if fieldTypeId == thrift.BOOL {
+				if err := p.ReadField2(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 3:
+			if fieldTypeId == thrift.MAP {
+				if err := p.ReadField3(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 4:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField4(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 5:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField5(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 6:
+			if fieldTypeId == thrift.BOOL {
+				if err := p.ReadField6(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 7:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField7(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 8:
+			if fieldTypeId == thrift.I32 {
+				if err := p.ReadField8(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 9:
+			if fieldTypeId == thrift.STRUCT {
+				if err := p.ReadField9(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 10:
+			if fieldTypeId == thrift.STRUCT {
+				if err := p.ReadField10(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		case 11:
+			if fieldTypeId == thrift.STRING {
+				if err := p.ReadField11(iprot); err != nil {
+					return err
+				}
+			} else {
+				if err := iprot.Skip(fieldTypeId); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := iprot.Skip(fieldTypeId); err != nil {
+				return err
+			}
+		}
+		if err := iprot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := iprot.ReadStructEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T read struct end error: ", p), err)
+	}
+	if !issetMethod {
+		return thrift.NewTProtocolExceptionWithType(thrift.INVALID_DATA, fmt.Errorf("Required field Method is not set"))
+	}
+	return nil
+}
+
+func (p *Entry) ReadField1(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return thrift.PrependError("error reading field 1: ", err)
+	} else {
+		p.Method = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField2(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadBool(); err != nil {
+		return thrift.PrependError("error reading field 2: ", err)
+	} else {
+		p.Oneway = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField3(iprot thrift.TProtocol) error {
+	_, _, size, err := iprot.ReadMapBegin()
+	if err != nil {
+		return thrift.PrependError("error reading map begin: ", err)
+	}
+	tMap := make(map[string]string, size)
+	p.Headers = tMap
+	for i := 0; i < size; i++ {
+		var _key0 string
+		if v, err := iprot.ReadString(); err != nil {
+			return thrift.PrependError("error reading field 0: ", err)
+		} else {
+			_key0 = v
+		}
+		var _val1 string
+		if v, err := iprot.ReadString(); err != nil {
+			return thrift.PrependError("error reading field 0: ", err)
+		} else {
+			_val1 = v
+		}
+		p.Headers[_key0] = _val1
+	}
+	if err := iprot.ReadMapEnd(); err != nil {
+		return thrift.PrependError("error reading map end: ", err)
+	}
+	return nil
+}
+
+func (p *Entry) ReadField4(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadBinary(); err != nil {
+		return thrift.PrependError("error reading field 4: ", err)
+	} else {
+		p.Args = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField5(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadBinary(); err != nil {
+		return thrift.PrependError("error reading field 5: ", err)
+	} else {
+		p.Result = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField6(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadBool(); err != nil {
+		return thrift.PrependError("error reading field 6: ", err)
+	} else {
+		p.Exception = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField7(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return thrift.PrependError("error reading field 7: ", err)
+	} else {
+		p.Error = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField8(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadI32(); err != nil {
+		return thrift.PrependError("error reading field 8: ", err)
+	} else {
+		p.ErrorType = v
+	}
+	return nil
+}
+
+func (p *Entry) ReadField9(iprot thrift.TProtocol) error {
+	p.Start = timestamp.NewTimestamp()
+	if err := p.Start.Read(iprot); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T error reading struct: ", p.Start), err)
+	}
+	return nil
+}
+
+func (p *Entry) ReadField10(iprot thrift.TProtocol) error {
+	p.Duration = duration.NewDuration()
+	if err := p.Duration.Read(iprot); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T error reading struct: ", p.Duration), err)
+	}
+	return nil
+}
+
+func (p *Entry) ReadField11(iprot thrift.TProtocol) error {
+	if v, err := iprot.ReadString(); err != nil {
+		return thrift.PrependError("error reading field 11: ", err)
+	} else {
+		p.Service = v
+	}
+	return nil
+}
+
+func (p *Entry) Write(oprot thrift.TProtocol) error {
+	if err := oprot.WriteStructBegin("Entry"); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write struct begin error: ", p), err)
+	}
+	if p != nil {
+		if err := p.writeField1(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField2(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField3(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField4(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField5(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField6(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField7(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField8(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField9(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField10(oprot); err != nil {
+			return err
+		}
+		if err := p.writeField11(oprot); err != nil {
+			return err
+		}
+	}
+	if err := oprot.WriteFieldStop(); err != nil {
+		return thrift.PrependError("write field stop error: ", err)
+	}
+	if err := oprot.WriteStructEnd(); err != nil {
+		return thrift.PrependError("write struct stop error: ", err)
+	}
+	return nil
+}
+
+func (p *Entry) writeField1(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("method", thrift.STRING, 1); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 1:method: ", p), err)
+	}
+	if err := oprot.WriteString(string(p.Method)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.method (1) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 1:method: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField2(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("oneway", thrift.BOOL, 2); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 2:oneway: ", p), err)
+	}
+	if err := oprot.WriteBool(bool(p.Oneway)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.oneway (2) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 2:oneway: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField3(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("headers", thrift.MAP, 3); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 3:headers: ", p), err)
+	}
+	if err := oprot.WriteMapBegin(thrift.STRING, thrift.STRING, len(p.Headers)); err != nil {
+		return thrift.PrependError("error writing map begin: ", err)
+	}
+	for k, v := range p.Headers {
+		if err := oprot.WriteString(string(k)); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T. (0) field write error: ", p), err)
+		}
+		if err := oprot.WriteString(string(v)); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T. (0) field write error: ", p), err)
+		}
+	}
+	if err := oprot.WriteMapEnd(); err != nil {
+		return thrift.PrependError("error writing map end: ", err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 3:headers: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField4(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("args", thrift.STRING, 4); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 4:args: ", p), err)
+	}
+	if err := oprot.WriteBinary(p.Args); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.args (4) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 4:args: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField5(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("result", thrift.STRING, 5); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 5:result: ", p), err)
+	}
+	if err := oprot.WriteBinary(p.Result); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.result (5) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 5:result: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField6(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("exception", thrift.BOOL, 6); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 6:exception: ", p), err)
+	}
+	if err := oprot.WriteBool(bool(p.Exception)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.exception (6) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 6:exception: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField7(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("error", thrift.STRING, 7); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 7:error: ", p), err)
+	}
+	if err := oprot.WriteString(string(p.Error)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.error (7) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 7:error: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField8(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("error_type", thrift.I32, 8); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 8:error_type: ", p), err)
+	}
+	if err := oprot.WriteI32(int32(p.ErrorType)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.error_type (8) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 8:error_type: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) writeField9(oprot thrift.TProtocol) (err error) {
+	if p.IsSetStart() {
+		if err := oprot.WriteFieldBegin("start", thrift.STRUCT, 9); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field begin error 9:start: ", p), err)
+		}
+		if err := p.Start.Write(oprot); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T error writing struct: ", p.Start), err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field end error 9:start: ", p), err)
+		}
+	}
+	return err
+}
+
+func (p *Entry) writeField10(oprot thrift.TProtocol) (err error) {
+	if p.IsSetDuration() {
+		if err := oprot.WriteFieldBegin("duration", thrift.STRUCT, 10); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field begin error 10:duration: ", p), err)
+		}
+		if err := p.Duration.Write(oprot); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T error writing struct: ", p.Duration), err)
+		}
+		if err := oprot.WriteFieldEnd(); err != nil {
+			return thrift.PrependError(fmt.Sprintf("%T write field end error 10:duration: ", p), err)
+		}
+	}
+	return err
+}
+
+func (p *Entry) writeField11(oprot thrift.TProtocol) (err error) {
+	if err := oprot.WriteFieldBegin("service", thrift.STRING, 11); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field begin error 11:service: ", p), err)
+	}
+	if err := oprot.WriteString(string(p.Service)); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T.service (11) field write error: ", p), err)
+	}
+	if err := oprot.WriteFieldEnd(); err != nil {
+		return thrift.PrependError(fmt.Sprintf("%T write field end error 11:service: ", p), err)
+	}
+	return err
+}
+
+func (p *Entry) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf(
+		"Entry({method: %v, oneway: %v, headers: %v, args: %v, result: %v, exception: %v, error: %v, error_type: %v, start: %v, duration: %v, service: %v})",
+		p.GetMethod(),
+		p.GetOneway(),
+		p.GetHeaders(),
+		p.GetArgs(),
+		p.GetResult(),
+		p.GetException(),
+		p.GetError(),
+		p.GetErrorType(),
+		p.GetStart(),
+		p.GetDuration(),
+		p.GetService(),
+	)
+}
@@ -0,0 +1,32 @@
+namespace * middleware.record
+
+include "types/known/duration.thrift"
+include "types/known/timestamp.thrift"
+
+// Entry is a call recorded along with its outcome.
+struct Entry {
+  1: required string method;
+  2: bool oneway;
+  // Headers read along with the request.
+  3: map<string, string> headers;
+
+  // Args is the request serialized with the binary protocol.
+  4: binary args;
+  // Result is the result struct serialized with the binary protocol, it is
+  // empty for oneway and failed calls.
+  5: binary result;
+  // Exception tells whether the result carries a declared exception.
+  6: bool exception;
+
+  // Error is the message of the error the call failed with, if any.
+  7: string error;
+  // ErrorType is the type of the TApplicationException the client got back
+  // for the error, as the processor answers it.
+  8: i32 error_type;
+
+  9: optional timestamp.Timestamp start;
+  10: optional duration.Duration duration;
+
+  // Service is the canonical name of the service called.
+  11: string service;
+}
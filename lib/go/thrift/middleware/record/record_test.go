@@ -0,0 +1,538 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/duration"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/timestamp"
+)
+
+type echoArgs struct {
+	Msg string
+}
+
+func (a *echoArgs) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("echo_args")
+	p.WriteFieldBegin("msg", thrift.STRING, 1)
+	p.WriteString(a.Msg)
+	p.WriteFieldEnd()
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (a *echoArgs) Read(p thrift.TProtocol) error {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typeID == thrift.STOP {
+			break
+		}
+
+		if id == 1 && typeID == thrift.STRING {
+			if a.Msg, err = p.ReadString(); err != nil {
+				return err
+			}
+		} else if err := p.Skip(typeID); err != nil {
+			return err
+		}
+
+		p.ReadFieldEnd()
+	}
+
+	return p.ReadStructEnd()
+}
+
+func (a *echoArgs) String() string { return fmt.Sprintf("%+v", *a) }
+
+var echoArgsDefinition = thrift.StructDefinition{
+	Namespace:           "record.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "echo_args"},
+}
+
+func (*echoArgs) StructDefinition() thrift.StructDefinition { return echoArgsDefinition }
+
+type echoError struct{}
+
+func (*echoError) Error() string { return "echo error" }
+
+type echoResult struct {
+	Success map[string]bool
+	Failure *echoError
+}
+
+func (r *echoResult) Write(p thrift.TProtocol) error {
+	p.WriteStructBegin("echo_result")
+
+	if r.Success != nil {
+		p.WriteFieldBegin("success", thrift.MAP, 0)
+		p.WriteMapBegin(thrift.STRING, thrift.BOOL, len(r.Success))
+
+		for k, v := range r.Success {
+			p.WriteString(k)
+			p.WriteBool(v)
+		}
+
+		p.WriteMapEnd()
+		p.WriteFieldEnd()
+	}
+
+	if r.Failure != nil {
+		p.WriteFieldBegin("failure", thrift.STRUCT, 1)
+		p.WriteStructBegin("echo_error")
+		p.WriteFieldStop()
+		p.WriteStructEnd()
+		p.WriteFieldEnd()
+	}
+
+	p.WriteFieldStop()
+
+	return p.WriteStructEnd()
+}
+
+func (r *echoResult) Read(p thrift.TProtocol) error {
+	if _, err := p.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typeID == thrift.STOP {
+			break
+		}
+
+		switch {
+		case id == 0 && typeID == thrift.MAP:
+			_, _, n, err := p.ReadMapBegin()
+
+			if err != nil {
+				return err
+			}
+
+			r.Success = make(map[string]bool, n)
+
+			for i := 0; i < n; i++ {
+				k, _ := p.ReadString()
+				v, err := p.ReadBool()
+
+				if err != nil {
+					return err
+				}
+
+				r.Success[k] = v
+			}
+
+			err = p.ReadMapEnd()
+		case id == 1 && typeID == thrift.STRUCT:
+			r.Failure = &echoError{}
+			err = p.Skip(typeID)
+		default:
+			err = p.Skip(typeID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		p.ReadFieldEnd()
+	}
+
+	return p.ReadStructEnd()
+}
+
+func (r *echoResult) String() string         { return fmt.Sprintf("%+v", *r) }
+func (r *echoResult) GetResult() interface{} { return r.Success }
+
+var echoResultDefinition = thrift.StructDefinition{
+	Namespace:           "record.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "echo_result"},
+}
+
+func (*echoResult) StructDefinition() thrift.StructDefinition { return echoResultDefinition }
+
+func init() {
+	thrift.RegisterStruct((*echoArgs)(nil))
+	thrift.RegisterStruct((*echoResult)(nil))
+
+	thrift.RegisterService(
+		thrift.ServiceDefinition{
+			Namespace:           "record.test",
+			AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "Service"},
+			Functions: []thrift.FunctionDefinition{
+				{
+					AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "echo"},
+					Args:                echoArgsDefinition,
+					Result:              &echoResultDefinition,
+				},
+				{
+					AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "notify"},
+					IsOneway:            true,
+					Args:                echoArgsDefinition,
+				},
+			},
+		},
+	)
+}
+
+func (r *echoResult) GetError() error {
+	if r.Failure != nil {
+		return r.Failure
+	}
+
+	return nil
+}
+
+// echoHandler answers a map holding every word of the message, the ones in
+// upper case set to true.
+type echoHandler struct {
+	// upper is the casing considered as upper, it lets the tests change the
+	// behavior of the handler.
+	upper func(string) string
+}
+
+func (h *echoHandler) Handle(_ thrift.Context, req thrift.TRequest) (thrift.TResponse, error) {
+	msg := req.(*echoArgs).Msg
+
+	switch msg {
+	case "fail":
+		return &echoResult{Failure: &echoError{}}, nil
+	case "boom":
+		return nil, errors.New("boom")
+	}
+
+	res := echoResult{Success: make(map[string]bool)}
+
+	for _, w := range strings.Fields(msg) {
+		res.Success[w] = h.upper(w) == w
+	}
+
+	return &res, nil
+}
+
+type notifyHandler struct{}
+
+func (notifyHandler) Handle(thrift.Context, thrift.TRequest) error { return nil }
+
+func newProcessor(upper func(string) string, ms ...thrift.TMiddleware) thrift.TProcessor {
+	p := thrift.NewTStandardProcessor(ms)
+
+	p.AddProcessor(
+		"echo",
+		thrift.NewTBinaryProcessorFunction(
+			p,
+			"echo",
+			func() thrift.TRequest { return &echoArgs{} },
+			&echoHandler{upper: upper},
+		),
+	)
+
+	p.AddProcessor(
+		"notify",
+		thrift.NewTUnaryProcessorFunction(
+			p,
+			"notify",
+			func() thrift.TRequest { return &echoArgs{} },
+			notifyHandler{},
+		),
+	)
+
+	return p
+}
+
+func entry(t *testing.T, mth, msg string) *Entry {
+	t.Helper()
+
+	args, err := thrift.NewTSerializer().Write(context.Background(), &echoArgs{Msg: msg})
+	require.NoError(t, err)
+
+	return &Entry{
+		Method:  mth,
+		Oneway:  mth == "notify",
+		Args:    args,
+		Headers: map[string]string{"thrift-caller": "test", "authorization": "secret"},
+	}
+}
+
+// record records the traffic of a few calls processed by a processor.
+func record(t *testing.T) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   = NewWriter(&buf)
+
+		target = NewProcessorTarget(
+			newProcessor(strings.ToUpper, NewServerMiddlewareBuilder(Options{Recorder: w}).Build("record.test", "Service")),
+		)
+	)
+
+	for _, e := range []*Entry{
+		entry(t, "echo", "a B c D e F g H i J k L"),
+		entry(t, "echo", "fail"),
+		entry(t, "echo", "boom"),
+		entry(t, "notify", "hello"),
+		entry(t, "unknown", "hello"),
+	} {
+		_, err := target.Call(context.Background(), e)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Flush())
+
+	return buf.Bytes()
+}
+
+func TestLog(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		w   = NewWriter(&buf)
+
+		entries = []*Entry{
+			{
+				Method:   "echo",
+				Headers:  map[string]string{"foo": "bar"},
+				Args:     []byte{1, 2, 3},
+				Result:   []byte{4, 5},
+				Start:    timestamp.New(time.Unix(0, 12345)),
+				Duration: duration.New(time.Millisecond),
+			},
+			{Method: "notify", Oneway: true},
+			{Method: "echo", Error: "boom", ErrorType: thrift.INTERNAL_ERROR},
+		}
+	)
+
+	for _, e := range entries {
+		require.NoError(t, w.Record(e))
+	}
+
+	require.NoError(t, w.Flush())
+
+	data := buf.Bytes()
+	r := NewReader(bytes.NewReader(data))
+
+	for _, want := range entries {
+		e, err := r.Read()
+		require.NoError(t, err)
+
+		// The empty fields are read back empty rather than nil.
+		assert.Equal(t, want.String(), e.String())
+	}
+
+	_, err := r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewReader(strings.NewReader("nope")).Read()
+	assert.Equal(t, ErrInvalidLog, err)
+
+	r = NewReader(bytes.NewReader(data[:len(data)-1]))
+
+	for range entries[:2] {
+		_, err = r.Read()
+		require.NoError(t, err)
+	}
+
+	_, err = r.Read()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	r := NewReader(bytes.NewReader(record(t)))
+
+	for _, want := range []struct {
+		mth       string
+		oneway    bool
+		result    bool
+		exception bool
+		errorType int32
+	}{
+		{mth: "echo", result: true},
+		{mth: "echo", result: true, exception: true},
+		{mth: "echo", errorType: thrift.INTERNAL_ERROR},
+		{mth: "notify", oneway: true},
+	} {
+		e, err := r.Read()
+		require.NoError(t, err)
+
+		assert.Equal(t, want.mth, e.Method)
+		assert.Equal(t, want.oneway, e.Oneway)
+		assert.Equal(t, want.result, len(e.Result) > 0)
+		assert.Equal(t, want.exception, e.Exception)
+		assert.Equal(t, want.errorType, e.ErrorType)
+		assert.Equal(t, map[string]string{"thrift-caller": "test"}, e.Headers)
+		assert.NotNil(t, e.Start)
+		assert.NotEmpty(t, e.Args)
+	}
+
+	// The unknown method never reaches the middlewares.
+	_, err := r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReplay_Processor(t *testing.T) {
+	log := record(t)
+
+	diffs, err := Replay(
+		context.Background(),
+		NewReader(bytes.NewReader(log)),
+		NewProcessorTarget(newProcessor(strings.ToUpper)),
+	)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	diffs, err = Replay(
+		context.Background(),
+		NewReader(bytes.NewReader(log)),
+		NewProcessorTarget(newProcessor(strings.ToLower)),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "echo: result differs", diffs[0].String())
+}
+
+func TestReplay_Client(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		pf   thrift.TProtocolFactory
+	}{
+		{name: "binary", pf: thrift.NewTBinaryProtocolFactoryDefault()},
+		{name: "json", pf: thrift.NewTJSONProtocolFactory()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Replay(
+				context.Background(),
+				NewReader(bytes.NewReader(record(t))),
+				NewClientTarget(clientPipe(t, tt.pf, newProcessor(strings.ToUpper))),
+			)
+			require.NoError(t, err)
+			assert.Empty(t, diffs)
+		})
+	}
+}
+
+// TestReplay_UnknownService verifies that the calls to an unknown service
+// fail over a protocol the raw structs can not be transcoded to.
+func TestReplay_UnknownService(t *testing.T) {
+	cl := clientPipe(t, thrift.NewTJSONProtocolFactory(), newProcessor(strings.ToUpper))
+
+	e := entry(t, "echo", "a b")
+	e.Service = "record.test.Unknown"
+
+	_, err := NewClientTarget(cl).Call(context.Background(), e)
+	assert.Error(t, err)
+}
+
+// clientPipe returns a client of p, using the protocol of pf.
+func clientPipe(t *testing.T, pf thrift.TProtocolFactory, p thrift.TProcessor) thrift.TClient {
+	var (
+		pr1, pw1 = io.Pipe()
+		pr2, pw2 = io.Pipe()
+
+		serverProt = pf.GetProtocol(thrift.NewStreamTransport(pr2, pw1))
+	)
+
+	t.Cleanup(func() {
+		pw1.Close()
+		pw2.Close()
+	})
+
+	go func() {
+		for {
+			if ok, err := p.Process(context.Background(), serverProt, serverProt); !ok && err != nil {
+				var aerr thrift.TApplicationException
+
+				if !errors.As(err, &aerr) {
+					return
+				}
+			}
+		}
+	}()
+
+	return thrift.NewTSyncClient(thrift.NewStreamTransport(pr1, pw2), pf)
+}
+
+func TestCompare(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		recorded Entry
+		actual   Entry
+		want     string
+	}{
+		{name: "same", recorded: Entry{Result: []byte{0}}, actual: Entry{Result: []byte{0}}},
+		{
+			name:     "same error type",
+			recorded: Entry{Error: "a", ErrorType: thrift.INTERNAL_ERROR},
+			actual:   Entry{Error: "b", ErrorType: thrift.INTERNAL_ERROR},
+		},
+		{
+			name:     "error type",
+			recorded: Entry{Error: "a", ErrorType: thrift.INTERNAL_ERROR},
+			actual:   Entry{Error: "a", ErrorType: thrift.UNKNOWN_METHOD},
+			want:     "error type 1, want 6",
+		},
+		{
+			name:     "error",
+			recorded: Entry{Result: []byte{0}},
+			actual:   Entry{Error: "a"},
+			want:     `error "a", want ""`,
+		},
+		{
+			name:     "exception",
+			recorded: Entry{Result: []byte{0}, Exception: true},
+			actual:   Entry{Result: []byte{0}},
+			want:     "exception false, want true",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := Compare(&tt.recorded, &tt.actual)
+
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, reason)
+		})
+	}
+}
+
+func TestTapTransport(t *testing.T) {
+	var (
+		in, out bytes.Buffer
+
+		buf = thrift.NewTMemoryBuffer()
+		tap = NewTapTransport(buf, &in, &out)
+	)
+
+	tap.Write([]byte("foo"))
+	assert.Zero(t, out.Len())
+
+	require.NoError(t, tap.Flush())
+	assert.Equal(t, "foo", out.String())
+
+	b := make([]byte, 3)
+	tap.Read(b)
+
+	assert.Equal(t, "foo", in.String())
+}
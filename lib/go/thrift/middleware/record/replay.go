@@ -0,0 +1,368 @@
+package record
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/internal/wire"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/duration"
+	"github.com/upfluence/thrift/lib/go/thrift/types/known/timestamp"
+)
+
+// errLossyProtocol is the error of a rawStruct written to or read from a
+// protocol Transcode does not preserve the values of.
+var errLossyProtocol = errors.New(
+	"record: the struct is not registered, only the binary and compact protocols can carry it",
+)
+
+// rawStruct is a struct known only as its serialization with the binary
+// protocol, it is transcoded from and to the protocol in use. The protocol
+// must be binary or compact, the other ones tell the strings and the
+// binaries apart.
+type rawStruct struct {
+	data []byte
+}
+
+func (s *rawStruct) Write(p thrift.TProtocol) error {
+	if !wire.Lossless(p) {
+		return errLossyProtocol
+	}
+
+	buf := thrift.NewTMemoryBuffer()
+
+	if _, err := buf.Write(s.data); err != nil {
+		return err
+	}
+
+	return wire.Transcode(thrift.NewTBinaryProtocolTransport(buf), p, thrift.STRUCT)
+}
+
+func (s *rawStruct) Read(p thrift.TProtocol) error {
+	if !wire.Lossless(p) {
+		return errLossyProtocol
+	}
+
+	buf := thrift.NewTMemoryBuffer()
+
+	if err := wire.Transcode(p, thrift.NewTBinaryProtocolTransport(buf), thrift.STRUCT); err != nil {
+		return err
+	}
+
+	s.data = append([]byte(nil), buf.Bytes()...)
+
+	return nil
+}
+
+func (s *rawStruct) String() string         { return fmt.Sprintf("raw(%d bytes)", len(s.data)) }
+func (s *rawStruct) GetResult() interface{} { return nil }
+func (s *rawStruct) GetError() error        { return nil }
+
+// carriesException tells whether the result struct serialized in data has
+// a field set other than the success one, whose id is 0.
+func carriesException(data []byte) bool {
+	buf := thrift.NewTMemoryBuffer()
+	buf.Write(data)
+
+	p := thrift.NewTBinaryProtocolTransport(buf)
+
+	if _, err := p.ReadStructBegin(); err != nil {
+		return false
+	}
+
+	for {
+		_, typeID, id, err := p.ReadFieldBegin()
+
+		if err != nil || typeID == thrift.STOP {
+			return false
+		}
+
+		if id != 0 {
+			return true
+		}
+
+		if err := p.Skip(typeID); err != nil {
+			return false
+		}
+
+		p.ReadFieldEnd()
+	}
+}
+
+// Target processes the calls replayed, it returns their outcome as an entry.
+// An error is returned only when the call could not be made.
+type Target interface {
+	Call(thrift.Context, *Entry) (*Entry, error)
+}
+
+type clientTarget struct {
+	c thrift.TClient
+}
+
+// NewClientTarget returns a Target replaying the calls with c, against a
+// remote endpoint. The headers recorded are sent along with the calls. The
+// arguments and the results of the functions registered along with their
+// service are sent over any protocol, the other ones only over the binary
+// and compact protocols.
+func NewClientTarget(c thrift.TClient) Target {
+	return &clientTarget{c: c}
+}
+
+func (t *clientTarget) Call(ctx thrift.Context, e *Entry) (*Entry, error) {
+	if len(e.Headers) > 0 {
+		keys := append([]string(nil), thrift.GetWriteHeaderList(ctx)...)
+
+		for k, v := range e.Headers {
+			ctx = thrift.SetHeader(ctx, k, v)
+			keys = append(keys, k)
+		}
+
+		ctx = thrift.SetWriteHeaderList(ctx, keys)
+	}
+
+	req, res, err := callStructs(e)
+
+	if err != nil {
+		return nil, err
+	}
+
+	actual := Entry{Service: e.Service, Method: e.Method, Oneway: e.Oneway, Start: timestamp.Now()}
+
+	if e.Oneway {
+		err = t.c.CallUnary(ctx, e.Method, req)
+	} else if err = t.c.CallBinary(ctx, e.Method, req, res); err == nil {
+		if actual.Result, err = thrift.NewTSerializer().Write(ctx, res); err != nil {
+			return nil, err
+		}
+
+		actual.Exception = carriesException(actual.Result)
+	}
+
+	actual.Duration = duration.New(time.Since(actual.Start.ToTime()))
+
+	var aerr thrift.TApplicationException
+
+	if err != nil && !errors.As(err, &aerr) {
+		return nil, err
+	}
+
+	actual.setError(err)
+
+	return &actual, nil
+}
+
+// callStructs returns the arguments of the call of e and the result to read
+// its response into. They are the structs registered for the function
+// called, or rawStructs when the function or the structs are unknown.
+func callStructs(e *Entry) (thrift.TRequest, thrift.TResponse, error) {
+	var (
+		req thrift.TRequest  = &rawStruct{data: e.Args}
+		res thrift.TResponse = &rawStruct{}
+	)
+
+	sd, ok := thrift.GetServiceDefinition(e.Service)
+
+	if !ok {
+		return req, res, nil
+	}
+
+	fd, ok := sd.Function(e.Method)
+
+	if !ok {
+		return req, res, nil
+	}
+
+	if v, ok := newStruct(&fd.Args); ok && len(e.Args) > 0 {
+		if err := thrift.NewTDeserializer().Read(v, e.Args); err != nil {
+			return nil, nil, err
+		}
+
+		req = v
+	}
+
+	if fd.Result != nil {
+		if v, ok := newStruct(fd.Result); ok {
+			if r, ok := v.(thrift.TResponse); ok {
+				res = r
+			}
+		}
+	}
+
+	return req, res, nil
+}
+
+func newStruct(sd *thrift.StructDefinition) (thrift.TStruct, bool) {
+	t, ok := thrift.StructType(sd.CanonicalName())
+
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := reflect.New(t).Interface().(thrift.TStruct)
+
+	return v, ok
+}
+
+type processorTarget struct {
+	p thrift.TProcessor
+
+	mu    sync.Mutex
+	seqID int32
+}
+
+// NewProcessorTarget returns a Target replaying the calls against p, in
+// process.
+func NewProcessorTarget(p thrift.TProcessor) Target {
+	return &processorTarget{p: p}
+}
+
+func (t *processorTarget) Call(ctx thrift.Context, e *Entry) (*Entry, error) {
+	t.mu.Lock()
+	t.seqID++
+	seqID := t.seqID
+	t.mu.Unlock()
+
+	var (
+		in  = thrift.NewTMemoryBuffer()
+		out = thrift.NewTMemoryBuffer()
+
+		iprot = thrift.NewTBinaryProtocolTransport(in)
+		oprot = thrift.NewTBinaryProtocolTransport(out)
+
+		typeID thrift.TMessageType = thrift.CALL
+	)
+
+	if e.Oneway {
+		typeID = thrift.ONEWAY
+	}
+
+	if err := iprot.WriteMessageBegin(e.Method, typeID, seqID); err != nil {
+		return nil, err
+	}
+
+	if err := (&rawStruct{data: e.Args}).Write(iprot); err != nil {
+		return nil, err
+	}
+
+	if err := iprot.WriteMessageEnd(); err != nil {
+		return nil, err
+	}
+
+	actual := Entry{Service: e.Service, Method: e.Method, Oneway: e.Oneway, Start: timestamp.Now()}
+
+	t.p.Process(thrift.AddReadTHeaderToContext(ctx, e.Headers), iprot, oprot)
+
+	actual.Duration = duration.New(time.Since(actual.Start.ToTime()))
+
+	if out.Len() == 0 {
+		return &actual, nil
+	}
+
+	_, rTypeID, _, err := oprot.ReadMessageBegin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch rTypeID {
+	case thrift.EXCEPTION:
+		aerr := thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "")
+
+		if err := aerr.Read(oprot); err != nil {
+			return nil, err
+		}
+
+		actual.setError(aerr)
+	case thrift.REPLY:
+		var res rawStruct
+
+		if err := res.Read(oprot); err != nil {
+			return nil, err
+		}
+
+		actual.Result = res.data
+		actual.Exception = carriesException(res.data)
+	default:
+		return nil, thrift.NewTApplicationException(
+			thrift.INVALID_MESSAGE_TYPE_EXCEPTION,
+			fmt.Sprintf("%s: invalid message type", e.Method),
+		)
+	}
+
+	return &actual, oprot.ReadMessageEnd()
+}
+
+// Diff is a call whose replayed outcome differs from the recorded one.
+type Diff struct {
+	Recorded *Entry
+	Actual   *Entry
+
+	Reason string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: %s", d.Recorded.Method, d.Reason)
+}
+
+// Compare returns why the actual outcome of a call differs from the recorded
+// one, or false when they match. The results are compared regardless of the
+// order of their maps and sets, the error messages and the timings are not
+// compared.
+func Compare(recorded, actual *Entry) (string, bool) {
+	switch {
+	case (recorded.Error == "") != (actual.Error == ""):
+		return fmt.Sprintf("error %q, want %q", actual.Error, recorded.Error), true
+	case recorded.Error != "" && recorded.ErrorType != actual.ErrorType:
+		return fmt.Sprintf("error type %d, want %d", actual.ErrorType, recorded.ErrorType), true
+	case recorded.Exception != actual.Exception:
+		return fmt.Sprintf("exception %t, want %t", actual.Exception, recorded.Exception), true
+	}
+
+	if bytes.Equal(recorded.Result, actual.Result) {
+		return "", false
+	}
+
+	rb, rerr := wire.Canonicalize(recorded.Result)
+	ab, aerr := wire.Canonicalize(actual.Result)
+
+	if rerr != nil || aerr != nil || !bytes.Equal(rb, ab) {
+		return "result differs", true
+	}
+
+	return "", false
+}
+
+// Replay replays, in order, every call of the log read by r against t and
+// returns the ones whose outcome differs from the recorded one. It stops at
+// the first call that could not be made.
+func Replay(ctx thrift.Context, r *Reader, t Target) ([]Diff, error) {
+	var diffs []Diff
+
+	for {
+		e, err := r.Read()
+
+		if errors.Is(err, io.EOF) {
+			return diffs, nil
+		}
+
+		if err != nil {
+			return diffs, err
+		}
+
+		actual, err := t.Call(ctx, e)
+
+		if err != nil {
+			return diffs, errors.Wrap(err, e.Method)
+		}
+
+		if reason, ok := Compare(e, actual); ok {
+			diffs = append(diffs, Diff{Recorded: e, Actual: actual, Reason: reason})
+		}
+	}
+}
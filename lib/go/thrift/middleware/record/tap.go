@@ -0,0 +1,84 @@
+package record
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// TapTransport is a TTransport copying the raw traffic of the transport it
+// wraps: the bytes read go to In as they are read, the bytes written go to
+// Out once flushed. It captures what the middleware can not, such as the
+// messages failing to decode or the streams.
+//
+// A TapTransport does not synchronize the writes to In and Out: a transport
+// read and written from distinct goroutines writes to them concurrently, In
+// and Out must then not share a writer unsafe for concurrent use.
+type TapTransport struct {
+	thrift.TTransport
+
+	In  io.Writer
+	Out io.Writer
+
+	pending bytes.Buffer
+}
+
+func NewTapTransport(t thrift.TTransport, in, out io.Writer) *TapTransport {
+	return &TapTransport{TTransport: t, In: in, Out: out}
+}
+
+func (t *TapTransport) Read(p []byte) (int, error) {
+	n, err := t.TTransport.Read(p)
+
+	if n > 0 && t.In != nil {
+		t.In.Write(p[:n]) //nolint:errcheck
+	}
+
+	return n, err
+}
+
+func (t *TapTransport) Write(p []byte) (int, error) {
+	n, err := t.TTransport.Write(p)
+
+	if n > 0 && t.Out != nil {
+		t.pending.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (t *TapTransport) Flush() error {
+	err := t.TTransport.Flush()
+
+	if t.pending.Len() > 0 {
+		t.Out.Write(t.pending.Bytes()) //nolint:errcheck
+		t.pending.Reset()
+	}
+
+	return err
+}
+
+type tapTransportFactory struct {
+	f   thrift.TTransportFactory
+	tap func() (in, out io.Writer)
+}
+
+// NewTapTransportFactory returns a TTransportFactory wrapping the
+// transports of f, if not nil, with a TapTransport writing to the writers
+// returned by tap for every transport. The transports are used concurrently,
+// tap must return distinct writers on every call, or writers safe for
+// concurrent use.
+func NewTapTransportFactory(f thrift.TTransportFactory, tap func() (in, out io.Writer)) thrift.TTransportFactory {
+	return &tapTransportFactory{f: f, tap: tap}
+}
+
+func (f *tapTransportFactory) GetTransport(t thrift.TTransport) thrift.TTransport {
+	if f.f != nil {
+		t = f.f.GetTransport(t)
+	}
+
+	in, out := f.tap()
+
+	return NewTapTransport(t, in, out)
+}
@@ -0,0 +1,61 @@
+package retry
+
+import "sync"
+
+const (
+	DefaultBudgetRatio   = 0.2
+	DefaultBudgetReserve = 10
+)
+
+// Budget bounds the extra load retries put on a service: every call deposits
+// Ratio token and every retry withdraws a whole one. Reserve tokens are
+// available from the start so that low traffic services can still retry.
+type Budget struct {
+	ratio float64
+	max   float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewBudget returns a budget letting retries add at most ratio times the
+// number of calls, on top of reserve retries. A zero ratio and reserve mean
+// DefaultBudgetRatio and DefaultBudgetReserve, a negative ratio disables the
+// deposits.
+func NewBudget(ratio float64, reserve int) *Budget {
+	if ratio == 0 {
+		ratio = DefaultBudgetRatio
+	}
+
+	if ratio < 0 {
+		ratio = 0
+	}
+
+	if reserve == 0 {
+		reserve = DefaultBudgetReserve
+	}
+
+	return &Budget{ratio: ratio, max: float64(reserve), tokens: float64(reserve)}
+}
+
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens += b.ratio; b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
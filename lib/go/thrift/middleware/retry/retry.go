@@ -0,0 +1,223 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/exception"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+const (
+	DefaultMaxAttempts    = 3
+	DefaultInitialBackoff = 10 * time.Millisecond
+	DefaultMaxBackoff     = time.Second
+	DefaultMultiplier     = 2.
+)
+
+type Options struct {
+	// Maximum number of attempts of a single call, the first one included.
+	MaxAttempts int
+
+	// The backoff before the n-th retry is drawn at random between zero and
+	// InitialBackoff * Multiplier^(n-1), capped to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Each attempt gets its own deadline when set, on top of the one of the
+	// call context.
+	AttemptTimeout time.Duration
+
+	// Shared by every method of the service when nil.
+	Budget *Budget
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if opts.InitialBackoff == 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if opts.Multiplier == 0 {
+		opts.Multiplier = DefaultMultiplier
+	}
+
+	if opts.Budget == nil {
+		opts.Budget = NewBudget(0, 0)
+	}
+
+	return opts
+}
+
+type builder struct {
+	opts Options
+}
+
+// NewMiddlewareBuilder returns a client side TMiddlewareBuilder retrying the
+// calls of the services it is built for. A call is retried when it failed
+// with an exception annotated Safe, when the server rejected it as OVERLOADED
+// before handling it or, when the function or its service is
+// annotated Idempotent (or ReadOnly), when it failed on a transport error, a
+// timeout or an internal error of the server.
+func NewMiddlewareBuilder(opts Options) thrift.TMiddlewareBuilder {
+	return &builder{opts: opts}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	sd, _ := thrift.GetServiceDefinition(namespace + "." + service)
+
+	return NewMiddleware(sd, b.opts)
+}
+
+type middleware struct {
+	thrift.TNopMiddleware
+
+	opts       Options
+	idempotent map[string]bool
+}
+
+// NewMiddleware returns the retry middleware of the given service, see
+// NewMiddlewareBuilder.
+func NewMiddleware(sd thrift.ServiceDefinition, opts Options) thrift.TStreamingMiddleware {
+	m := &middleware{opts: opts.withDefaults(), idempotent: make(map[string]bool)}
+
+	for _, fd := range sd.Functions {
+		m.idempotent[fd.Name] = rpc.IsIdempotent(sd, fd)
+	}
+
+	return m
+}
+
+func (m *middleware) backoff(retry int) time.Duration {
+	d := float64(m.opts.InitialBackoff)
+
+	for i := 1; i < retry && d < float64(m.opts.MaxBackoff); i++ {
+		d *= m.opts.Multiplier
+	}
+
+	if d > float64(m.opts.MaxBackoff) {
+		d = float64(m.opts.MaxBackoff)
+	}
+
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func (m *middleware) wait(ctx thrift.Context, retry int) error {
+	t := time.NewTimer(m.backoff(retry))
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *middleware) attemptContext(ctx thrift.Context) (thrift.Context, context.CancelFunc) {
+	if m.opts.AttemptTimeout > 0 {
+		return context.WithTimeout(ctx, m.opts.AttemptTimeout)
+	}
+
+	return ctx, func() {}
+}
+
+func isRetryable(err error) bool {
+	var (
+		terr thrift.TTransportException
+		aerr thrift.TApplicationException
+	)
+
+	switch {
+	case errors.IsTimeout(err), errors.As(err, &terr):
+		return true
+	case errors.As(err, &aerr):
+		switch aerr.TypeId() {
+		case thrift.INTERNAL_ERROR, thrift.INTERNAL_TIME_OUT_ERROR, thrift.BAD_SEQUENCE_ID:
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOverloaded reports whether the server rejected the call before handling
+// it, even without the limit client middleware decoding it.
+func isOverloaded(err error) bool {
+	var aerr thrift.TApplicationException
+
+	return errors.As(err, &aerr) && aerr.TypeId() == thrift.OVERLOADED
+}
+
+func (m *middleware) shouldRetry(ctx thrift.Context, mth string, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	return exception.IsSafe(err) || isOverloaded(err) || (m.idempotent[mth] && isRetryable(err))
+}
+
+// resetResponse clears what a failed attempt may have decoded in res, the
+// generated results only set the fields present on the wire.
+func resetResponse(res thrift.TResponse) {
+	if v := reflect.ValueOf(res); v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().CanSet() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	m.opts.Budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		actx, cancel := m.attemptContext(ctx)
+		res, err := next(actx, req)
+		cancel()
+
+		rerr := err
+
+		if rerr == nil && res != nil {
+			rerr = res.GetError()
+		}
+
+		if attempt >= m.opts.MaxAttempts || !m.shouldRetry(ctx, mth, rerr) || !m.opts.Budget.withdraw() {
+			return res, err
+		}
+
+		if werr := m.wait(ctx, attempt); werr != nil {
+			return res, err
+		}
+
+		resetResponse(res)
+	}
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	m.opts.Budget.deposit()
+
+	for attempt := 1; ; attempt++ {
+		actx, cancel := m.attemptContext(ctx)
+		err := next(actx, req)
+		cancel()
+
+		if attempt >= m.opts.MaxAttempts || !m.shouldRetry(ctx, mth, err) || !m.opts.Budget.withdraw() {
+			return err
+		}
+
+		if werr := m.wait(ctx, attempt); werr != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/exception"
+	"github.com/upfluence/thrift/lib/go/thrift/types/annotation/rpc"
+)
+
+type safeError struct{}
+
+func (safeError) Error() string { return "safe" }
+
+func (safeError) StructDefinition() thrift.StructDefinition {
+	return thrift.StructDefinition{
+		AnnotatedDefinition: thrift.AnnotatedDefinition{
+			Name:                  "SafeError",
+			StructuredAnnotations: []thrift.RegistrableStruct{&exception.Safe{}},
+		},
+	}
+}
+
+type response struct {
+	thrift.TResponse
+
+	Err error
+}
+
+func (r *response) GetError() error { return r.Err }
+
+var testService = thrift.ServiceDefinition{
+	Namespace: "retry.test",
+	AnnotatedDefinition: thrift.AnnotatedDefinition{
+		Name: "Service",
+	},
+	Functions: []thrift.FunctionDefinition{
+		{AnnotatedDefinition: thrift.AnnotatedDefinition{Name: "plain"}},
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "idempotent",
+				StructuredAnnotations: []thrift.RegistrableStruct{&rpc.Idempotent{}},
+			},
+		},
+		{
+			AnnotatedDefinition: thrift.AnnotatedDefinition{
+				Name:                  "read_only",
+				StructuredAnnotations: []thrift.RegistrableStruct{&rpc.ReadOnly{}},
+			},
+		},
+	},
+}
+
+func init() {
+	thrift.RegisterService(testService)
+}
+
+func failing(n int, err error) (func(thrift.Context, thrift.TRequest) (thrift.TResponse, error), *int) {
+	var calls int
+
+	return func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+		calls++
+
+		if calls <= n {
+			return nil, err
+		}
+
+		return &response{}, nil
+	}, &calls
+}
+
+func testMiddleware(opts Options) thrift.TMiddleware {
+	opts.InitialBackoff = time.Microsecond
+
+	return NewMiddlewareBuilder(opts).Build("retry.test", "Service")
+}
+
+func TestMiddleware_Policy(t *testing.T) {
+	var (
+		terr = thrift.NewTTransportException(thrift.NOT_OPEN, "broken")
+		aerr = thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, "unknown")
+		oerr = thrift.NewTApplicationException(thrift.OVERLOADED, "overloaded")
+	)
+
+	for _, tt := range []struct {
+		name      string
+		mth       string
+		err       error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "not idempotent", mth: "plain", err: terr, wantCalls: 1, wantErr: terr},
+		{name: "idempotent", mth: "idempotent", err: terr, wantCalls: 2},
+		{name: "read only", mth: "read_only", err: terr, wantCalls: 2},
+		{name: "not retryable", mth: "idempotent", err: aerr, wantCalls: 1, wantErr: aerr},
+		{name: "safe exception", mth: "plain", err: safeError{}, wantCalls: 2},
+		{name: "overloaded", mth: "plain", err: oerr, wantCalls: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			next, calls := failing(1, tt.err)
+
+			_, err := testMiddleware(Options{}).HandleBinaryRequest(
+				context.Background(),
+				tt.mth,
+				0,
+				nil,
+				next,
+			)
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantCalls, *calls)
+		})
+	}
+}
+
+func TestMiddleware_DeclaredSafeException(t *testing.T) {
+	var (
+		calls int
+		res   = &response{}
+	)
+
+	_, err := testMiddleware(Options{}).HandleBinaryRequest(
+		context.Background(),
+		"plain",
+		0,
+		nil,
+		func(thrift.Context, thrift.TRequest) (thrift.TResponse, error) {
+			if calls++; calls == 1 {
+				res.Err = safeError{}
+			}
+
+			return res, nil
+		},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Nil(t, res.Err)
+}
+
+func TestMiddleware_MaxAttempts(t *testing.T) {
+	terr := thrift.NewTTransportException(thrift.NOT_OPEN, "broken")
+	next, calls := failing(10, terr)
+
+	_, err := testMiddleware(Options{MaxAttempts: 4}).HandleBinaryRequest(
+		context.Background(),
+		"idempotent",
+		0,
+		nil,
+		next,
+	)
+
+	assert.Equal(t, terr, err)
+	assert.Equal(t, 4, *calls)
+}
+
+func TestMiddleware_Budget(t *testing.T) {
+	var (
+		terr = thrift.NewTTransportException(thrift.NOT_OPEN, "broken")
+		m    = testMiddleware(Options{MaxAttempts: 10, Budget: NewBudget(-1, 3)})
+	)
+
+	next, calls := failing(10, terr)
+
+	_, err := m.HandleBinaryRequest(context.Background(), "idempotent", 0, nil, next)
+
+	assert.Equal(t, terr, err)
+	assert.Equal(t, 4, *calls)
+
+	next, calls = failing(10, terr)
+
+	m.HandleBinaryRequest(context.Background(), "idempotent", 0, nil, next)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestMiddleware_AttemptTimeout(t *testing.T) {
+	var calls int
+
+	_, err := testMiddleware(Options{AttemptTimeout: 10 * time.Millisecond}).HandleBinaryRequest(
+		context.Background(),
+		"idempotent",
+		0,
+		nil,
+		func(ctx thrift.Context, _ thrift.TRequest) (thrift.TResponse, error) {
+			if calls++; calls == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+
+			return &response{}, nil
+		},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestMiddleware_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	next, calls := failing(10, thrift.NewTTransportException(thrift.NOT_OPEN, "broken"))
+
+	_, err := testMiddleware(Options{}).HandleBinaryRequest(ctx, "idempotent", 0, nil, next)
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, *calls)
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import "sync"
+
+// Exporter ships the finished spans to a tracing backend. Export is called
+// synchronously once the call ends, implementations are expected to buffer.
+type Exporter interface {
+	Export(SpanData)
+}
+
+type ExporterFunc func(SpanData)
+
+func (fn ExporterFunc) Export(s SpanData) { fn(s) }
+
+// InMemoryExporter keeps every exported span, it is meant for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) Export(s SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, s)
+}
+
+// Spans returns the spans exported so far, in the order they finished.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
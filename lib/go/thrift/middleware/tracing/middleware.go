@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+type builder struct {
+	kind     SpanKind
+	exporter Exporter
+}
+
+// NewClientMiddlewareBuilder returns a TMiddlewareBuilder starting a client
+// span per call, child of the span found in the call context if any, and
+// propagating it through the traceparent and tracestate THeaders.
+func NewClientMiddlewareBuilder(exp Exporter) thrift.TMiddlewareBuilder {
+	return &builder{kind: Client, exporter: exp}
+}
+
+// NewServerMiddlewareBuilder returns a TMiddlewareBuilder starting a server
+// span per call, child of the one propagated by the client if any. The span
+// is available to the handler through SpanFromContext.
+func NewServerMiddlewareBuilder(exp Exporter) thrift.TMiddlewareBuilder {
+	return &builder{kind: Server, exporter: exp}
+}
+
+func (b *builder) Build(namespace, service string) thrift.TMiddleware {
+	return &middleware{kind: b.kind, exporter: b.exporter, service: namespace + "." + service}
+}
+
+type middleware struct {
+	kind     SpanKind
+	exporter Exporter
+	service  string
+}
+
+func appendKey(keys []string, k string) []string {
+	for _, kk := range keys {
+		if kk == k {
+			return keys
+		}
+	}
+
+	return append(append([]string(nil), keys...), k)
+}
+
+func (m *middleware) start(ctx thrift.Context, mth string) (thrift.Context, *Span) {
+	var parent SpanContext
+
+	switch m.kind {
+	case Client:
+		if s := SpanFromContext(ctx); s != nil {
+			parent = s.SpanContext()
+		}
+	case Server:
+		if v, ok := thrift.GetHeader(ctx, TraceParentHeader); ok {
+			parent, _ = ParseTraceParent(v)
+		}
+
+		if v, ok := thrift.GetHeader(ctx, TraceStateHeader); ok && parent.IsValid() {
+			parent.TraceState = v
+		}
+	}
+
+	s := newSpan(m.service+"/"+mth, m.kind, parent, m.exporter)
+
+	s.SetAttribute("rpc.system", "thrift")
+	s.SetAttribute("rpc.service", m.service)
+	s.SetAttribute("rpc.method", mth)
+
+	ctx = ContextWithSpan(ctx, s)
+
+	if m.kind == Client {
+		sc := s.SpanContext()
+		keys := appendKey(thrift.GetWriteHeaderList(ctx), TraceParentHeader)
+
+		ctx = thrift.SetHeader(ctx, TraceParentHeader, sc.TraceParent())
+
+		if sc.TraceState != "" {
+			ctx = thrift.SetHeader(ctx, TraceStateHeader, sc.TraceState)
+			keys = appendKey(keys, TraceStateHeader)
+		}
+
+		ctx = thrift.SetWriteHeaderList(ctx, keys)
+	}
+
+	return ctx, s
+}
+
+func recordResult(s *Span, res thrift.TResponse, err error) {
+	var aerr thrift.TApplicationException
+
+	if errors.As(err, &aerr) {
+		s.SetAttribute("thrift.exception.type_id", strconv.Itoa(int(aerr.TypeId())))
+	}
+
+	s.RecordError(err)
+
+	if err == nil && res != nil {
+		if rerr := res.GetError(); rerr != nil {
+			s.RecordError(rerr)
+			s.SetAttribute("exception.declared", "true")
+		}
+	}
+}
+
+func (m *middleware) HandleBinaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, s := m.start(ctx, mth)
+	defer s.Finish()
+
+	res, err := next(ctx, req)
+	recordResult(s, res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleUnaryRequest(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, next func(thrift.Context, thrift.TRequest) error) error {
+	ctx, s := m.start(ctx, mth)
+	defer s.Finish()
+
+	err := next(ctx, req)
+	recordResult(s, nil, err)
+
+	return err
+}
+
+// streamInterceptor records the messages of a stream as events of its span.
+func streamInterceptor(s *Span) thrift.TStreamingMiddleware {
+	event := func(name string) func(thrift.Context, thrift.TStreamMessage) {
+		return func(_ thrift.Context, msg thrift.TStreamMessage) {
+			s.AddEvent(name, map[string]string{"message.index": strconv.FormatInt(msg.Index, 10)})
+		}
+	}
+
+	return thrift.NewTStreamInterceptorMiddleware(
+		thrift.TStreamHooks{
+			OnSend:    event("message.sent"),
+			OnReceive: event("message.received"),
+			OnError: func(_ thrift.Context, msg thrift.TStreamMessage, err error) {
+				s.AddEvent(
+					"message.error",
+					map[string]string{
+						"message.direction": msg.Direction.String(),
+						"message.index":     strconv.FormatInt(msg.Index, 10),
+						"exception.type":    fmt.Sprintf("%T", err),
+					},
+				)
+			},
+		},
+	)
+}
+
+type closingInboundStream struct {
+	thrift.TInboundStream
+
+	span *Span
+}
+
+func (s *closingInboundStream) Close() error {
+	defer s.span.Finish()
+	return s.TInboundStream.Close()
+}
+
+type closingOutboundStream struct {
+	thrift.TOutboundStream
+
+	span *Span
+}
+
+func (s *closingOutboundStream) Close() error {
+	defer s.span.Finish()
+	return s.TOutboundStream.Close()
+}
+
+// finish ends the span of a stream call. On the server side, the stream is
+// over once the handler returned. On the client side, the span of a successful
+// call ends once the caller closed the stream, or either half of a bidi
+// stream.
+func (m *middleware) finish(s *Span, res thrift.TResponse, err error) {
+	recordResult(s, res, err)
+
+	if m.kind == Server || err != nil {
+		s.Finish()
+	}
+}
+
+func (m *middleware) HandleInboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, st thrift.TInboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, s := m.start(ctx, mth)
+
+	if m.kind == Client {
+		st = &closingInboundStream{TInboundStream: st, span: s}
+	}
+
+	res, err := streamInterceptor(s).HandleInboundStream(ctx, mth, seqID, req, st, next)
+	m.finish(s, res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleOutboundStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, st thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, s := m.start(ctx, mth)
+
+	if m.kind == Client {
+		st = &closingOutboundStream{TOutboundStream: st, span: s}
+	}
+
+	res, err := streamInterceptor(s).HandleOutboundStream(ctx, mth, seqID, req, st, next)
+	m.finish(s, res, err)
+
+	return res, err
+}
+
+func (m *middleware) HandleBidiStream(ctx thrift.Context, mth string, seqID int32, req thrift.TRequest, is thrift.TInboundStream, os thrift.TOutboundStream, next func(thrift.Context, thrift.TRequest, thrift.TInboundStream, thrift.TOutboundStream) (thrift.TResponse, error)) (thrift.TResponse, error) {
+	ctx, s := m.start(ctx, mth)
+
+	if m.kind == Client {
+		is = &closingInboundStream{TInboundStream: is, span: s}
+		os = &closingOutboundStream{TOutboundStream: os, span: s}
+	}
+
+	res, err := streamInterceptor(s).HandleBidiStream(ctx, mth, seqID, req, is, os, next)
+	m.finish(s, res, err)
+
+	return res, err
+}
@@ -0,0 +1,180 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const v = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceParent(v)
+	require.NoError(t, err)
+
+	assert.True(t, sc.IsSampled())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, v, sc.TraceParent())
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+	} {
+		_, err := ParseTraceParent(v)
+		assert.Equal(t, ErrInvalidTraceParent, err, v)
+	}
+}
+
+// headersSent rebuilds the context a server gets from the THeaders the
+// client context asks to write.
+func headersSent(ctx thrift.Context) thrift.Context {
+	headers := thrift.THeaderMap{}
+
+	for _, k := range thrift.GetWriteHeaderList(ctx) {
+		headers[k], _ = thrift.GetHeader(ctx, k)
+	}
+
+	return thrift.AddReadTHeaderToContext(context.Background(), headers)
+}
+
+func TestMiddleware_Propagation(t *testing.T) {
+	var (
+		exp    = NewInMemoryExporter()
+		client = NewClientMiddlewareBuilder(exp).Build("tracing.test", "Service")
+		server = NewServerMiddlewareBuilder(exp).Build("tracing.test", "Service")
+	)
+
+	_, err := client.HandleBinaryRequest(
+		context.Background(),
+		"get",
+		1,
+		nil,
+		func(ctx thrift.Context, req thrift.TRequest) (thrift.TResponse, error) {
+			return server.HandleBinaryRequest(
+				headersSent(ctx),
+				"get",
+				1,
+				req,
+				func(ctx thrift.Context, _ thrift.TRequest) (thrift.TResponse, error) {
+					assert.NotNil(t, SpanFromContext(ctx))
+
+					return nil, thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "boom")
+				},
+			)
+		},
+	)
+
+	require.Error(t, err)
+
+	spans := exp.Spans()
+	require.Len(t, spans, 2)
+
+	ss, cs := spans[0], spans[1]
+
+	assert.Equal(t, Server, ss.Kind)
+	assert.Equal(t, Client, cs.Kind)
+	assert.Equal(t, "tracing.test.Service/get", cs.Name)
+
+	assert.False(t, cs.Parent.IsValid())
+	assert.Equal(t, cs.TraceID, ss.TraceID)
+	assert.Equal(t, cs.SpanID, ss.Parent.SpanID)
+	assert.NotEqual(t, cs.SpanID, ss.SpanID)
+
+	assert.Equal(t, "6", ss.Attributes["thrift.exception.type_id"])
+	assert.Equal(t, "boom", ss.Attributes["exception.message"])
+}
+
+type nopOutboundStream struct{}
+
+func (nopOutboundStream) Close() error                               { return nil }
+func (nopOutboundStream) Send(thrift.Context, thrift.TRequest) error { return nil }
+
+func TestMiddleware_StreamEvents(t *testing.T) {
+	var (
+		exp    = NewInMemoryExporter()
+		server = NewServerMiddlewareBuilder(exp).Build("tracing.test", "Service").(thrift.TStreamingMiddleware)
+	)
+
+	_, err := server.HandleOutboundStream(
+		context.Background(),
+		"watch",
+		1,
+		nil,
+		nopOutboundStream{},
+		func(ctx thrift.Context, _ thrift.TRequest, s thrift.TOutboundStream) (thrift.TResponse, error) {
+			for i := 0; i < 2; i++ {
+				if err := s.Send(ctx, nil); err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, nil
+		},
+	)
+
+	require.NoError(t, err)
+
+	spans := exp.Spans()
+	require.Len(t, spans, 1)
+	require.Len(t, spans[0].Events, 2)
+	assert.Equal(t, "message.sent", spans[0].Events[1].Name)
+	assert.Equal(t, "2", spans[0].Events[1].Attributes["message.index"])
+}
+
+func TestMiddleware_ClientStreamEndsOnClose(t *testing.T) {
+	var (
+		exp    = NewInMemoryExporter()
+		client = NewClientMiddlewareBuilder(exp).Build("tracing.test", "Service").(thrift.TStreamingMiddleware)
+
+		stream thrift.TOutboundStream
+	)
+
+	_, err := client.HandleOutboundStream(
+		context.Background(),
+		"upload",
+		1,
+		nil,
+		nopOutboundStream{},
+		func(_ thrift.Context, _ thrift.TRequest, s thrift.TOutboundStream) (thrift.TResponse, error) {
+			stream = s
+			return nil, nil
+		},
+	)
+
+	require.NoError(t, err)
+	assert.Empty(t, exp.Spans())
+
+	require.NoError(t, stream.Send(context.Background(), nil))
+	require.NoError(t, stream.Close())
+
+	spans := exp.Spans()
+	require.Len(t, spans, 1)
+	assert.Len(t, spans[0].Events, 1)
+}
+
+func TestSpan_WritesAfterFinish(t *testing.T) {
+	var (
+		exp = NewInMemoryExporter()
+		s   = newSpan("get", Server, SpanContext{}, exp)
+	)
+
+	s.SetAttribute("foo", "bar")
+	s.Finish()
+
+	s.SetAttribute("foo", "baz")
+	s.AddEvent("late", nil)
+
+	spans := exp.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, map[string]string{"foo": "bar"}, spans[0].Attributes)
+	assert.Empty(t, spans[0].Events)
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+)
+
+const (
+	TraceParentHeader = "traceparent"
+	TraceStateHeader  = "tracestate"
+
+	traceParentVersion = "00"
+	sampledFlag        = 0x01
+)
+
+var ErrInvalidTraceParent = errors.New("tracing: invalid traceparent")
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span propagated to the peers, following the
+// W3C Trace Context specification.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&sampledFlag != 0
+}
+
+// TraceParent formats the span context as a traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceParent parses a traceparent header value.
+func ParseTraceParent(v string) (SpanContext, error) {
+	var (
+		sc    SpanContext
+		parts = strings.Split(strings.TrimSpace(v), "-")
+	)
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, ErrInvalidTraceParent
+	}
+
+	// Future versions may append fields, version 00 must have exactly four.
+	if parts[0] == traceParentVersion && len(parts) != 4 {
+		return sc, ErrInvalidTraceParent
+	}
+
+	var flags [1]byte
+
+	for _, f := range []struct {
+		s   string
+		dst []byte
+	}{
+		{s: parts[1], dst: sc.TraceID[:]},
+		{s: parts[2], dst: sc.SpanID[:]},
+		{s: parts[3], dst: flags[:]},
+	} {
+		if len(f.s) != 2*len(f.dst) {
+			return SpanContext{}, ErrInvalidTraceParent
+		}
+
+		if _, err := hex.Decode(f.dst, []byte(f.s)); err != nil {
+			return SpanContext{}, ErrInvalidTraceParent
+		}
+	}
+
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	return sc, nil
+}
+
+type SpanKind int
+
+const (
+	Client SpanKind = iota
+	Server
+)
+
+func (k SpanKind) String() string {
+	if k == Client {
+		return "client"
+	}
+
+	return "server"
+}
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+// SpanData is the snapshot of a finished span handed to the exporter.
+type SpanData struct {
+	Name   string
+	Kind   SpanKind
+	Parent SpanContext
+
+	SpanContext
+
+	Start time.Time
+	End   time.Time
+
+	Attributes map[string]string
+	Events     []Event
+}
+
+// Span is a span in progress.
+type Span struct {
+	exporter Exporter
+
+	mu       sync.Mutex
+	data     SpanData
+	finished bool
+}
+
+func newSpan(name string, kind SpanKind, parent SpanContext, exp Exporter) *Span {
+	sc := SpanContext{Flags: sampledFlag}
+
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		rand.Read(sc.TraceID[:])
+	}
+
+	rand.Read(sc.SpanID[:])
+
+	return &Span{
+		exporter: exp,
+		data: SpanData{
+			Name:        name,
+			Kind:        kind,
+			Parent:      parent,
+			SpanContext: sc,
+			Start:       time.Now(),
+			Attributes:  make(map[string]string),
+		},
+	}
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *Span) SetAttribute(k, v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return
+	}
+
+	s.data.Attributes[k] = v
+}
+
+func (s *Span) AddEvent(name string, attrs map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.finished {
+		return
+	}
+
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+// RecordError records the type and the message of err, a nil error is
+// ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.SetAttribute("exception.type", fmt.Sprintf("%T", err))
+	s.SetAttribute("exception.message", err.Error())
+}
+
+// Finish ends the span and exports it when sampled, the subsequent calls are
+// no-ops, as are the attributes and the events added afterwards.
+func (s *Span) Finish() {
+	s.mu.Lock()
+
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+
+	s.finished = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]string, len(s.data.Attributes))
+
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+
+	s.mu.Unlock()
+
+	if data.IsSampled() && s.exporter != nil {
+		s.exporter.Export(data)
+	}
+}
+
+type spanKey struct{}
+
+func ContextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// SpanFromContext returns the span in progress, nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
@@ -14,9 +14,9 @@ type tOutboundStream struct {
 	messageType TMessageType
 }
 
-func newTClientOutboundStream(name string, seqID int32, in, out TProtocol, cl *TSyncClient) *tOutboundStream {
+func newTClientOutboundStream(name string, seqID int32, in, out TProtocol, release func()) *tOutboundStream {
 	return &tOutboundStream{
-		tBaseStream: newTClientBaseStream(name, seqID, in, out, CLIENT_STREAM_GOAWAY, cl),
+		tBaseStream: newTClientBaseStream(name, seqID, in, out, CLIENT_STREAM_GOAWAY, release),
 		messageType: CLIENT_STREAM_MESSAGE,
 	}
 }
@@ -72,6 +72,10 @@ func (s *tOutboundStream) Close() error {
 	return nil
 }
 
+func (s *tOutboundStream) goAway() {
+	s.Close()
+}
+
 func (s *tOutboundStream) Send(ctx Context, req TRequest) error {
 	return s.write(ctx, s.messageType, req)
 }
@@ -0,0 +1,93 @@
+package thrift
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// TPanicHandler reports a panic recovered while processing a call to method,
+// v is the value given to panic and stack the trace of the panicking
+// goroutine.
+type TPanicHandler func(ctx Context, method string, v interface{}, stack []byte)
+
+// DefaultPanicHandler logs the panic and its stack with the standard logger.
+var DefaultPanicHandler TPanicHandler = func(_ Context, method string, v interface{}, stack []byte) {
+	log.Printf("thrift: panic processing %s: %v\n%s", method, v, stack)
+}
+
+// TPanicError is the error a recovered panic is turned into. The processor
+// writes it back to the client as an INTERNAL_ERROR TApplicationException
+// with a fixed message, the value and the stack are only given to the
+// TPanicHandler and the server middlewares.
+type TPanicError struct {
+	Method string
+	Value  interface{}
+	Stack  []byte
+}
+
+func (e *TPanicError) Error() string {
+	return "internal error"
+}
+
+type tPanicHandlerProvider interface {
+	GetPanicHandler() TPanicHandler
+}
+
+// panicHandler is looked up at panic time since the panic handler of a
+// processor is usually set after the generated code registered its functions.
+func panicHandler(p TProcessor) TPanicHandler {
+	if pp, ok := p.(tPanicHandlerProvider); ok {
+		if h := pp.GetPanicHandler(); h != nil {
+			return h
+		}
+	}
+
+	return DefaultPanicHandler
+}
+
+func reportPanic(ctx Context, p TProcessor, method string, v interface{}) error {
+	err := &TPanicError{Method: method, Value: v, Stack: debug.Stack()}
+
+	panicHandler(p)(ctx, method, v, err.Stack)
+
+	return err
+}
+
+// tRecoveryMiddleware turns the panics of the rest of the chain into a
+// TPanicError. The processor functions put it around the handler, so that
+// the other middlewares see the error, and around the whole chain to cover
+// the middlewares themselves.
+type tRecoveryMiddleware struct {
+	processor TProcessor
+}
+
+func (m tRecoveryMiddleware) recoverPanic(ctx Context, mth string, err *error) {
+	if v := recover(); v != nil {
+		*err = reportPanic(ctx, m.processor, mth, v)
+	}
+}
+
+func (m tRecoveryMiddleware) HandleBinaryRequest(ctx Context, mth string, _ int32, req TRequest, next func(Context, TRequest) (TResponse, error)) (res TResponse, err error) {
+	defer m.recoverPanic(ctx, mth, &err)
+	return next(ctx, req)
+}
+
+func (m tRecoveryMiddleware) HandleUnaryRequest(ctx Context, mth string, _ int32, req TRequest, next func(Context, TRequest) error) (err error) {
+	defer m.recoverPanic(ctx, mth, &err)
+	return next(ctx, req)
+}
+
+func (m tRecoveryMiddleware) HandleInboundStream(ctx Context, mth string, _ int32, req TRequest, s TInboundStream, next func(Context, TRequest, TInboundStream) (TResponse, error)) (res TResponse, err error) {
+	defer m.recoverPanic(ctx, mth, &err)
+	return next(ctx, req, s)
+}
+
+func (m tRecoveryMiddleware) HandleOutboundStream(ctx Context, mth string, _ int32, req TRequest, s TOutboundStream, next func(Context, TRequest, TOutboundStream) (TResponse, error)) (res TResponse, err error) {
+	defer m.recoverPanic(ctx, mth, &err)
+	return next(ctx, req, s)
+}
+
+func (m tRecoveryMiddleware) HandleBidiStream(ctx Context, mth string, _ int32, req TRequest, is TInboundStream, os TOutboundStream, next func(Context, TRequest, TInboundStream, TOutboundStream) (TResponse, error)) (res TResponse, err error) {
+	defer m.recoverPanic(ctx, mth, &err)
+	return next(ctx, req, is, os)
+}
@@ -0,0 +1,242 @@
+package thrift
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panickingBinaryHandler struct{}
+
+func (panickingBinaryHandler) Handle(_ Context, req TRequest) (TResponse, error) {
+	if *req.(*tstring) == "panic" {
+		panic("boom")
+	}
+
+	return req.(TResponse), nil
+}
+
+type panickingStreamServerHandler struct{}
+
+func (panickingStreamServerHandler) Handle(Context, TRequest, TOutboundStream) (TResponse, error) {
+	panic("boom")
+}
+
+type panicRecorder struct {
+	mu     sync.Mutex
+	panics []interface{}
+}
+
+func (r *panicRecorder) handle(_ Context, method string, v interface{}, stack []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.panics = append(r.panics, method+": "+v.(string))
+}
+
+func (r *panicRecorder) values() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.panics
+}
+
+// errorRecorder is a middleware recording the error returned by the rest of
+// the chain.
+type errorRecorder struct {
+	TNopMiddleware
+
+	err error
+}
+
+func (m *errorRecorder) HandleBinaryRequest(ctx Context, mth string, seqID int32, req TRequest, next func(Context, TRequest) (TResponse, error)) (TResponse, error) {
+	res, err := next(ctx, req)
+	m.err = err
+
+	return res, err
+}
+
+func readApplicationException(t *testing.T, prot TProtocol) TApplicationException {
+	t.Helper()
+
+	_, typeID, _, err := prot.ReadMessageBegin()
+	require.NoError(t, err)
+	require.Equal(t, EXCEPTION, typeID)
+
+	var ex tApplicationException
+
+	require.NoError(t, ex.Read(prot))
+	require.NoError(t, prot.ReadMessageEnd())
+
+	return &ex
+}
+
+func TestTBinaryProcessorFunction_Panic(t *testing.T) {
+	var (
+		clientProt, serverProt = processorPipe()
+
+		r   panicRecorder
+		m   errorRecorder
+		ctx = context.Background()
+		p   = NewTStandardProcessor([]TMiddleware{&m})
+	)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(
+			p,
+			"echo",
+			func() TRequest { return newTString("") },
+			panickingBinaryHandler{},
+		),
+	)
+
+	// Set once the functions are registered, as done with generated code.
+	p.PanicHandler = r.handle
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 2; i++ {
+			ok, _ := p.Process(ctx, serverProt, serverProt)
+			assert.True(t, ok)
+		}
+	}()
+
+	rawCall(t, clientProt, "echo", 1, "panic")
+
+	ex := readApplicationException(t, clientProt)
+	assert.Equal(t, int32(INTERNAL_ERROR), ex.TypeId())
+	assert.Contains(t, ex.Error(), "internal error")
+	assert.NotContains(t, ex.Error(), "boom")
+
+	var perr *TPanicError
+
+	require.ErrorAs(t, m.err, &perr)
+	assert.Equal(t, "boom", perr.Value)
+	assert.NotEmpty(t, perr.Stack)
+
+	// The connection is still usable.
+	rawCall(t, clientProt, "echo", 2, "hello")
+
+	_, typeID, seqID, err := clientProt.ReadMessageBegin()
+	require.NoError(t, err)
+	assert.Equal(t, REPLY, typeID)
+	assert.Equal(t, int32(2), seqID)
+
+	var result tstring
+
+	require.NoError(t, result.Read(clientProt))
+	require.NoError(t, clientProt.ReadMessageEnd())
+	assert.Equal(t, tstring("hello"), result)
+
+	<-done
+
+	assert.Equal(t, []interface{}{"echo: boom"}, r.values())
+}
+
+type panickingMiddleware struct {
+	TNopMiddleware
+}
+
+func (panickingMiddleware) HandleBinaryRequest(Context, string, int32, TRequest, func(Context, TRequest) (TResponse, error)) (TResponse, error) {
+	panic("boom")
+}
+
+func TestTBinaryProcessorFunction_MiddlewarePanic(t *testing.T) {
+	var (
+		clientProt, serverProt = processorPipe()
+
+		r   panicRecorder
+		ctx = context.Background()
+		p   = NewTStandardProcessor([]TMiddleware{panickingMiddleware{}})
+	)
+
+	p.PanicHandler = r.handle
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(p, "echo", func() TRequest { return newTString("") }, &binaryHandler{}),
+	)
+
+	go p.Process(ctx, serverProt, serverProt) //nolint:errcheck
+
+	rawCall(t, clientProt, "echo", 1, "hello")
+
+	assert.Equal(t, int32(INTERNAL_ERROR), readApplicationException(t, clientProt).TypeId())
+	assert.Equal(t, []interface{}{"echo: boom"}, r.values())
+}
+
+func TestTStreamServerProcessorFunction_Panic(t *testing.T) {
+	var (
+		pr1, pw1 = io.Pipe()
+		pr2, pw2 = io.Pipe()
+
+		pf = NewTBinaryProtocolFactoryDefault()
+		st = NewStreamTransport(pr2, pw1)
+
+		r   panicRecorder
+		ctx = context.Background()
+		p   = NewTStandardProcessor(nil)
+	)
+
+	p.PanicHandler = r.handle
+
+	p.AddProcessor(
+		"stream_server",
+		NewTStreamServerProcessorFunction(
+			p,
+			"stream_server",
+			func() TRequest { return newTString("") },
+			panickingStreamServerHandler{},
+		),
+	)
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(p, "echo", func() TRequest { return newTString("") }, &binaryHandler{}),
+	)
+
+	processorDone := make(chan struct{})
+
+	go func() {
+		defer close(processorDone)
+
+		// The stream reads and writes concurrently, each direction gets its
+		// own protocol.
+		in, out := pf.GetProtocol(st), pf.GetProtocol(st)
+
+		ok, err := p.Process(ctx, in, out)
+		assert.True(t, ok)
+		assert.Error(t, err)
+
+		ok, err = p.Process(ctx, in, out)
+		assert.True(t, ok)
+		assert.NoError(t, err)
+	}()
+
+	cl := NewTSyncClient(NewStreamTransport(pr1, pw2), pf)
+
+	var resp tstring
+
+	istream, err := cl.StreamServer(ctx, "stream_server", newTString("foo"), &resp)
+
+	var aerr TApplicationException
+
+	require.ErrorAs(t, err, &aerr)
+	assert.Equal(t, int32(INTERNAL_ERROR), aerr.TypeId())
+	assert.NoError(t, istream.Close())
+
+	// The exception ended the stream, the connection is still usable.
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString("hello"), &resp))
+	assert.Equal(t, tstring("hello"), resp)
+
+	<-processorDone
+
+	assert.Equal(t, []interface{}{"stream_server: boom"}, r.values())
+}
@@ -0,0 +1,81 @@
+package thrift
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+)
+
+var lastConnID uint64
+
+func nextConnID() uint64 {
+	return atomic.AddUint64(&lastConnID, 1)
+}
+
+// TPeer describes the connection a call was received on.
+type TPeer struct {
+	// ConnID identifies the connection among the ones served by the
+	// process, zero when unknown.
+	ConnID uint64
+
+	// The addresses are nil when the transport does not expose them.
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+
+	// TLS is the state of the connection, its verified chains and the server
+	// name requested by the client, nil when it does not use TLS.
+	TLS *tls.ConnectionState
+
+	// ClientType is the dialect detected by THeaderProtocol,
+	// THeaderClientUnknown with the other protocols.
+	ClientType THeaderClientType
+}
+
+type peerKey struct{}
+
+// WithPeer returns a copy of ctx carrying p.
+func WithPeer(ctx Context, p TPeer) Context {
+	return context.WithValue(ctx, peerKey{}, &p)
+}
+
+// GetPeer returns the peer the call of ctx was received from. The servers
+// set it before processing the calls, it is available to the middlewares and
+// to the handlers.
+func GetPeer(ctx Context) (TPeer, bool) {
+	if ctx == nil {
+		return TPeer{}, false
+	}
+
+	p, ok := ctx.Value(peerKey{}).(*TPeer)
+
+	if !ok || p == nil {
+		return TPeer{}, false
+	}
+
+	return *p, true
+}
+
+// newTPeer returns the peer of the connection client, the addresses are
+// known for the transports exposing their net.Conn.
+func newTPeer(client TTransport, connID uint64, cs *tls.ConnectionState) TPeer {
+	p := TPeer{ConnID: connID, TLS: cs}
+
+	if c, ok := client.(interface{ Conn() net.Conn }); ok {
+		if conn := c.Conn(); conn != nil {
+			p.RemoteAddr = conn.RemoteAddr()
+			p.LocalAddr = conn.LocalAddr()
+		}
+	}
+
+	return p
+}
+
+// tAddr is an address known only as its string representation.
+type tAddr struct {
+	network string
+	addr    string
+}
+
+func (a tAddr) Network() string { return a.network }
+func (a tAddr) String() string  { return a.addr }
@@ -0,0 +1,115 @@
+package thrift
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPeer(t *testing.T) {
+	_, ok := GetPeer(context.Background())
+	assert.False(t, ok)
+
+	p, ok := GetPeer(WithPeer(context.Background(), TPeer{ConnID: 42}))
+	assert.True(t, ok)
+	assert.Equal(t, TPeer{ConnID: 42}, p)
+}
+
+// peerHandler answers the peer it was called by.
+type peerHandler struct{}
+
+func (peerHandler) Handle(ctx Context, _ TRequest) (TResponse, error) {
+	p, ok := GetPeer(ctx)
+
+	if !ok {
+		return newTString("none"), nil
+	}
+
+	return newTString(
+		fmt.Sprintf("%d %v %v %v", p.ConnID, p.RemoteAddr, p.LocalAddr, p.ClientType),
+	), nil
+}
+
+func TestTSimpleServer_Peer(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		pf         TProtocolFactory
+		clientType THeaderClientType
+	}{
+		{name: "binary", pf: NewTBinaryProtocolFactoryDefault()},
+		{name: "header", pf: NewTHeaderProtocolFactory(), clientType: THeaderClientHeaders},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				s = newTestServer(t, peerHandler{}, &tickerHandler{}, WithProtocolFactory(tt.pf))
+
+				ctx = context.Background()
+				ids = make(map[string]bool)
+			)
+
+			defer s.Stop()
+
+			for i := 0; i < 2; i++ {
+				var (
+					sock = openTestSocket(t, s)
+					cl   = NewTSyncClient(sock, tt.pf)
+					want string
+				)
+
+				for j := 0; j < 2; j++ {
+					var res tstring
+
+					require.NoError(t, cl.CallBinary(ctx, "echo", newTString(""), &res))
+
+					var (
+						id         string
+						remote     string
+						local      string
+						clientType string
+					)
+
+					_, err := fmt.Sscan(string(res), &id, &remote, &local, &clientType)
+					require.NoError(t, err)
+
+					assert.NotEqual(t, "0", id)
+					assert.Equal(t, sock.Conn().LocalAddr().String(), remote)
+					assert.Equal(t, sock.Conn().RemoteAddr().String(), local)
+					assert.Equal(t, tt.clientType.String(), clientType)
+
+					// The calls made on a connection share its identifier.
+					if want == "" {
+						want = id
+					}
+
+					assert.Equal(t, want, id)
+				}
+
+				ids[want] = true
+			}
+
+			assert.Len(t, ids, 2)
+		})
+	}
+}
+
+func TestWithHTTPPeer(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://example.com/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	ctx := withHTTPPeer(r)
+
+	p, ok := GetPeer(ctx)
+	require.True(t, ok)
+
+	assert.Equal(t, "192.0.2.1:1234", p.RemoteAddr.String())
+	assert.Equal(t, "example.com", p.TLS.ServerName)
+	assert.Equal(t, THeaderClientUnknown, p.ClientType)
+
+	cs, ok := GetTLSConnectionState(ctx)
+	assert.True(t, ok)
+	assert.Same(t, r.TLS, cs)
+}
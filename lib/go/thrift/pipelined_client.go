@@ -0,0 +1,494 @@
+package thrift
+
+import "sync"
+
+var errPipelinedClientClosed = NewTTransportException(NOT_OPEN, "pipelined client closed")
+
+type tPipelinedClientFactory struct{}
+
+func NewTPipelinedClientFactory() TClientFactory {
+	return &tPipelinedClientFactory{}
+}
+
+func (*tPipelinedClientFactory) GetClient(t TTransportFactory, p TProtocolFactory, ms []TMiddleware) TClient {
+	return NewTPipelinedClient(t.GetTransport(nil), p, ms...)
+}
+
+type pipelinedCall struct {
+	method string
+
+	// res is reset to nil when the caller gives up on the call, the reader
+	// then drops the reply instead of decoding it.
+	res TResponse
+
+	// headers collects the THeaders read along with the reply, if the caller
+	// asked for them through WithResponseHeaders.
+	headers *TResponseHeaders
+
+	done chan error
+}
+
+// TPipelinedClient is a TStreamingClient letting many calls be in flight on a
+// single connection. A request is written as soon as the connection is
+// available for writing and a reader goroutine, running as long as replies
+// are outstanding, routes every reply back to its caller by sequence ID.
+//
+// The read and write sides of the connection are used concurrently, so each
+// side needs its own protocol state: with THeader, pass the raw transport
+// along with NewTHeaderProtocolFactory rather than a THeaderTransport.
+//
+// Streaming calls take the connection for themselves: they wait for the
+// outstanding replies and hold the connection until the stream is closed.
+type TPipelinedClient struct {
+	trans TTransport
+
+	iprot, oprot TProtocol
+
+	// wsem is held while writing a message, and for the whole lifetime of a
+	// stream.
+	wsem chan struct{}
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	seqID   int32
+	pending map[int32]*pipelinedCall
+	reading bool
+	err     error
+
+	middleware TStreamingMiddleware
+}
+
+func NewTPipelinedClient(t TTransport, f TProtocolFactory, ms ...TMiddleware) *TPipelinedClient {
+	c := &TPipelinedClient{
+		trans:      t,
+		iprot:      f.GetProtocol(t),
+		oprot:      f.GetProtocol(t),
+		wsem:       make(chan struct{}, 1),
+		pending:    make(map[int32]*pipelinedCall),
+		middleware: WrapMiddlewares(ms),
+	}
+
+	c.cond = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Close closes the underlying transport, the pending calls fail with the
+// resulting transport error.
+func (c *TPipelinedClient) Close() error {
+	c.mu.Lock()
+
+	if c.err == nil {
+		c.err = errPipelinedClientClosed
+	}
+
+	c.mu.Unlock()
+
+	return c.trans.Close()
+}
+
+func (c *TPipelinedClient) nextSeqID() int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seqID++
+
+	return c.seqID
+}
+
+func (c *TPipelinedClient) lockWrite(ctx Context) error {
+	select {
+	case c.wsem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *TPipelinedClient) unlockWrite() {
+	<-c.wsem
+}
+
+func (c *TPipelinedClient) fail(err error) {
+	c.mu.Lock()
+
+	if c.err == nil {
+		c.err = err
+	}
+
+	pending := c.pending
+
+	c.pending = make(map[int32]*pipelinedCall)
+	c.reading = false
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	for _, pc := range pending {
+		pc.done <- err
+	}
+
+	c.trans.Close()
+}
+
+// register records the call about to be written under seqID, the sequence ID
+// the middlewares were given, and makes sure a reader is running to collect
+// its reply. A call retried by a middleware reuses the sequence ID, it then
+// takes over the reply of the attempt it gave up on, if still outstanding.
+func (c *TPipelinedClient) register(seqID int32, pc *pipelinedCall) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	c.pending[seqID] = pc
+
+	if !c.reading {
+		c.reading = true
+		go c.readLoop()
+	}
+
+	return nil
+}
+
+func (c *TPipelinedClient) claim(seqID int32) (*pipelinedCall, TResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pc, ok := c.pending[seqID]
+
+	if !ok {
+		return nil, nil, false
+	}
+
+	delete(c.pending, seqID)
+
+	return pc, pc.res, true
+}
+
+// idle stops the reader when no reply is outstanding anymore.
+func (c *TPipelinedClient) idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.pending) > 0 && c.err == nil {
+		return false
+	}
+
+	c.reading = false
+	c.cond.Broadcast()
+
+	return true
+}
+
+func (c *TPipelinedClient) readLoop() {
+	for {
+		name, mType, seqID, err := c.iprot.ReadMessageBegin()
+
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		pc, res, ok := c.claim(seqID)
+
+		if ok && res != nil {
+			readResponseTHeaders(pc.headers, c.iprot)
+
+			err = readReply(c.iprot, pc.method, name, mType, res)
+
+			if aerr, ok := err.(TApplicationException); ok {
+				switch aerr.TypeId() {
+				case WRONG_METHOD_NAME, INVALID_MESSAGE_TYPE_EXCEPTION:
+					if serr := skipMessage(c.iprot); serr != nil {
+						pc.done <- err
+						c.fail(serr)
+						return
+					}
+				}
+			}
+		} else {
+			err = skipMessage(c.iprot)
+		}
+
+		if pc != nil {
+			pc.done <- err
+		}
+
+		switch err.(type) {
+		case TTransportException, TProtocolException:
+			c.fail(err)
+			return
+		}
+
+		if c.idle() {
+			return
+		}
+	}
+}
+
+func skipMessage(iprot TProtocol) error {
+	if err := iprot.Skip(STRUCT); err != nil {
+		return err
+	}
+
+	return iprot.ReadMessageEnd()
+}
+
+func (c *TPipelinedClient) call(ctx Context, seqID int32, method string, req TRequest, res TResponse) error {
+	if err := c.lockWrite(ctx); err != nil {
+		return err
+	}
+
+	headers, _ := GetResponseHeaders(ctx)
+
+	pc := &pipelinedCall{
+		method:  method,
+		res:     res,
+		headers: headers,
+		done:    make(chan error, 1),
+	}
+	err := c.register(seqID, pc)
+
+	if err == nil {
+		if err = send(ctx, c.oprot, seqID, method, req, CALL); err != nil {
+			c.unlockWrite()
+			c.fail(err)
+
+			return err
+		}
+	}
+
+	c.unlockWrite()
+
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-pc.done:
+		return err
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+
+	if _, ok := c.pending[seqID]; ok {
+		pc.res = nil
+		c.mu.Unlock()
+
+		return ctx.Err()
+	}
+
+	c.mu.Unlock()
+
+	// The reader already claimed the reply, res is being decoded.
+	return <-pc.done
+}
+
+func (c *TPipelinedClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
+	seqID := c.nextSeqID()
+
+	r, err := c.middleware.HandleBinaryRequest(
+		ctx,
+		method,
+		seqID,
+		req,
+		func(ctx Context, req TRequest) (TResponse, error) {
+			return res, c.call(ctx, seqID, method, req, res)
+		},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return copyResponse(res, r)
+}
+
+func (c *TPipelinedClient) CallUnary(ctx Context, method string, req TRequest) error {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
+	seqID := c.nextSeqID()
+
+	return c.middleware.HandleUnaryRequest(
+		ctx,
+		method,
+		seqID,
+		req,
+		func(ctx Context, req TRequest) error {
+			if err := c.lockWrite(ctx); err != nil {
+				return err
+			}
+
+			defer c.unlockWrite()
+
+			c.mu.Lock()
+			err := c.err
+			c.mu.Unlock()
+
+			if err != nil {
+				return err
+			}
+
+			if err := send(ctx, c.oprot, seqID, method, req, ONEWAY); err != nil {
+				c.fail(err)
+				return err
+			}
+
+			return nil
+		},
+	)
+}
+
+// acquireStream takes the connection for a stream: it waits for the reader
+// to collect every outstanding reply while blocking new calls.
+func (c *TPipelinedClient) acquireStream(ctx Context) (int32, error) {
+	if err := c.lockWrite(ctx); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for c.reading && c.err == nil {
+		c.cond.Wait()
+	}
+
+	if c.err != nil {
+		c.unlockWrite()
+		return 0, c.err
+	}
+
+	c.seqID++
+
+	return c.seqID, nil
+}
+
+func (c *TPipelinedClient) rpcStream(ctx Context, seqID int32, method string, req TRequest, res TResponse) error {
+	if err := send(ctx, c.oprot, seqID, method, req, CALL); err != nil {
+		return err
+	}
+
+	return recv(ctx, c.iprot, seqID, method, res)
+}
+
+func (c *TPipelinedClient) StreamClient(ctx Context, method string, req TRequest, res TResponse) (TOutboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
+	seqID, err := c.acquireStream(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		originalStream = newTClientOutboundStream(method, seqID, c.iprot, c.oprot, c.unlockWrite)
+
+		wrappedStream TOutboundStream = originalStream
+	)
+
+	_, err = c.middleware.HandleOutboundStream(
+		ctx,
+		method,
+		seqID,
+		req,
+		originalStream,
+		func(ctx Context, req TRequest, s TOutboundStream) (TResponse, error) {
+			wrappedStream = s
+
+			return res, c.rpcStream(ctx, seqID, method, req, res)
+		},
+	)
+
+	if err != nil {
+		originalStream.close()
+		return nil, err
+	}
+
+	originalStream.ready()
+
+	return wrappedStream, nil
+}
+
+func (c *TPipelinedClient) StreamServer(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
+	seqID, err := c.acquireStream(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		originalStream = newTClientInboundStream(method, seqID, c.iprot, c.oprot, c.unlockWrite)
+
+		wrappedStream TInboundStream = originalStream
+	)
+
+	_, err = c.middleware.HandleInboundStream(
+		ctx,
+		method,
+		seqID,
+		req,
+		originalStream,
+		func(ctx Context, req TRequest, s TInboundStream) (TResponse, error) {
+			wrappedStream = s
+
+			return res, c.rpcStream(ctx, seqID, method, req, res)
+		},
+	)
+
+	if err != nil {
+		originalStream.close()
+		return nil, err
+	}
+
+	originalStream.ready()
+
+	return wrappedStream, nil
+}
+
+func (c *TPipelinedClient) StreamBidi(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, TOutboundStream, error) {
+	ctx = withCallMethodDefinition(ctx, method, req)
+
+	seqID, err := c.acquireStream(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		bidiStream = newTClientBidiStream(method, seqID, c.iprot, c.oprot, c.unlockWrite)
+
+		inboundStream  TInboundStream  = &tInboundBidiStream{tBidiStream: bidiStream}
+		outboundStream TOutboundStream = &tOutboundBidiStream{tBidiStream: bidiStream}
+	)
+
+	_, err = c.middleware.HandleBidiStream(
+		ctx,
+		method,
+		seqID,
+		req,
+		inboundStream,
+		outboundStream,
+		func(ctx Context, req TRequest, is TInboundStream, os TOutboundStream) (TResponse, error) {
+			inboundStream = is
+			outboundStream = os
+
+			return res, c.rpcStream(ctx, seqID, method, req, res)
+		},
+	)
+
+	if err != nil {
+		bidiStream.close()
+		return nil, nil, err
+	}
+
+	bidiStream.ready()
+
+	return inboundStream, outboundStream, nil
+}
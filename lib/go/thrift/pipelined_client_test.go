@@ -0,0 +1,398 @@
+package thrift
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tpayload is a single string field struct, replies carrying it can be
+// skipped by the client like any generated result.
+type tpayload struct {
+	v string
+}
+
+func newTPayload(v string) *tpayload {
+	return &tpayload{v: v}
+}
+
+func (p *tpayload) GetError() error        { return nil }
+func (p *tpayload) GetResult() interface{} { return p.v }
+
+func (p *tpayload) String() string { return p.v }
+
+func (p *tpayload) Write(prot TProtocol) error {
+	if err := prot.WriteStructBegin("payload"); err != nil {
+		return err
+	}
+
+	if err := prot.WriteFieldBegin("v", STRING, 1); err != nil {
+		return err
+	}
+
+	if err := prot.WriteString(p.v); err != nil {
+		return err
+	}
+
+	if err := prot.WriteFieldEnd(); err != nil {
+		return err
+	}
+
+	if err := prot.WriteFieldStop(); err != nil {
+		return err
+	}
+
+	return prot.WriteStructEnd()
+}
+
+func (p *tpayload) Read(prot TProtocol) error {
+	if _, err := prot.ReadStructBegin(); err != nil {
+		return err
+	}
+
+	for {
+		_, typeID, id, err := prot.ReadFieldBegin()
+
+		if err != nil {
+			return err
+		}
+
+		if typeID == STOP {
+			break
+		}
+
+		if id == 1 && typeID == STRING {
+			p.v, err = prot.ReadString()
+		} else {
+			err = prot.Skip(typeID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := prot.ReadFieldEnd(); err != nil {
+			return err
+		}
+	}
+
+	return prot.ReadStructEnd()
+}
+
+// pipelinedClientPipe creates a TPipelinedClient wired to an in-process
+// server using the binary protocol. The server reads and writes through
+// distinct protocols so both sides can be driven concurrently.
+func pipelinedClientPipe() (cl *TPipelinedClient, serverIn, serverOut TProtocol, closePipes func()) {
+	pr1, pw1 := io.Pipe()
+	pr2, pw2 := io.Pipe()
+
+	pf := NewTBinaryProtocolFactoryDefault()
+
+	cl = NewTPipelinedClient(NewStreamTransport(pr1, pw2), pf)
+	serverIn = pf.GetProtocol(NewStreamTransport(pr2, nil))
+	serverOut = pf.GetProtocol(NewStreamTransport(nil, pw1))
+
+	closePipes = func() {
+		pw1.Close()
+		pw2.Close()
+	}
+
+	return
+}
+
+type pipelinedRequest struct {
+	name  string
+	seqID int32
+	arg   tpayload
+}
+
+func readPipelinedRequest(t *testing.T, prot TProtocol) pipelinedRequest {
+	t.Helper()
+
+	var (
+		req pipelinedRequest
+		err error
+	)
+
+	req.name, _, req.seqID, err = prot.ReadMessageBegin()
+	require.NoError(t, err)
+	require.NoError(t, req.arg.Read(prot))
+	require.NoError(t, prot.ReadMessageEnd())
+
+	return req
+}
+
+func writePipelinedReply(t *testing.T, prot TProtocol, req pipelinedRequest) {
+	t.Helper()
+
+	require.NoError(t, prot.WriteMessageBegin(req.name, REPLY, req.seqID))
+	require.NoError(t, req.arg.Write(prot))
+	require.NoError(t, prot.WriteMessageEnd())
+	require.NoError(t, prot.Flush())
+}
+
+// TestTPipelinedClient_OutOfOrderReplies verifies that replies are routed to
+// their caller by sequence ID, whatever order the server answers in.
+func TestTPipelinedClient_OutOfOrderReplies(t *testing.T) {
+	cl, serverIn, serverOut, closePipes := pipelinedClientPipe()
+	defer closePipes()
+
+	ctx := context.Background()
+
+	go func() {
+		first := readPipelinedRequest(t, serverIn)
+		second := readPipelinedRequest(t, serverIn)
+
+		writePipelinedReply(t, serverOut, second)
+		writePipelinedReply(t, serverOut, first)
+	}()
+
+	var (
+		wg  sync.WaitGroup
+		res = make([]tpayload, 2)
+		arg = []string{"foo", "bar"}
+	)
+
+	for i := range arg {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			assert.NoError(t, cl.CallBinary(ctx, "echo", newTPayload(arg[i]), &res[i]))
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, []tpayload{{v: "foo"}, {v: "bar"}}, res)
+}
+
+// TestTPipelinedClient_SlowCallDoesNotBlock verifies that a call can
+// complete while an earlier one is still waiting for its reply.
+func TestTPipelinedClient_SlowCallDoesNotBlock(t *testing.T) {
+	cl, serverIn, serverOut, closePipes := pipelinedClientPipe()
+	defer closePipes()
+
+	ctx := context.Background()
+	slowc := make(chan error, 1)
+
+	requests := make(chan pipelinedRequest, 2)
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			requests <- readPipelinedRequest(t, serverIn)
+		}
+	}()
+
+	go func() {
+		var res tpayload
+
+		slowc <- cl.CallBinary(ctx, "slow", newTPayload("slow"), &res)
+	}()
+
+	slow := <-requests
+
+	written := make(chan struct{})
+
+	go func() {
+		writePipelinedReply(t, serverOut, <-requests)
+		close(written)
+	}()
+
+	var res tpayload
+
+	require.NoError(t, cl.CallBinary(ctx, "fast", newTPayload("fast"), &res))
+	assert.Equal(t, "fast", res.v)
+
+	select {
+	case <-slowc:
+		t.Fatal("slow call completed before its reply was sent")
+	default:
+	}
+
+	<-written
+	writePipelinedReply(t, serverOut, slow)
+	assert.NoError(t, <-slowc)
+}
+
+// TestTPipelinedClient_CancelledCall verifies that a cancelled call returns
+// right away and that its late reply does not disturb the next calls.
+func TestTPipelinedClient_CancelledCall(t *testing.T) {
+	cl, serverIn, serverOut, closePipes := pipelinedClientPipe()
+	defer closePipes()
+
+	cctx, cancel := context.WithCancel(context.Background())
+	requests := make(chan pipelinedRequest, 1)
+
+	go func() {
+		requests <- readPipelinedRequest(t, serverIn)
+	}()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		var res tpayload
+
+		errc <- cl.CallBinary(cctx, "echo", newTPayload("cancelled"), &res)
+	}()
+
+	late := <-requests
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errc)
+
+	go func() {
+		writePipelinedReply(t, serverOut, late)
+		writePipelinedReply(t, serverOut, readPipelinedRequest(t, serverIn))
+	}()
+
+	var res tpayload
+
+	require.NoError(t, cl.CallBinary(context.Background(), "echo", newTPayload("next"), &res))
+	assert.Equal(t, "next", res.v)
+}
+
+// TestTPipelinedClient_MiddlewareSeqID verifies that the middlewares are
+// given the sequence ID written on the wire.
+func TestTPipelinedClient_MiddlewareSeqID(t *testing.T) {
+	cl, serverIn, serverOut, closePipes := pipelinedClientPipe()
+	defer closePipes()
+
+	seqIDs := make(chan int32, 2)
+
+	cl.middleware = WrapMiddlewares(
+		[]TMiddleware{
+			&funcMiddleware{
+				handleBinary: func(ctx Context, _ string, seqID int32, req TRequest, next func(Context, TRequest) (TResponse, error)) (TResponse, error) {
+					seqIDs <- seqID
+					return next(ctx, req)
+				},
+				handleUnary: func(ctx Context, _ string, seqID int32, req TRequest, next func(Context, TRequest) error) error {
+					seqIDs <- seqID
+					return next(ctx, req)
+				},
+			},
+		},
+	)
+
+	requests := make(chan pipelinedRequest, 2)
+
+	go func() {
+		req := readPipelinedRequest(t, serverIn)
+		requests <- req
+		writePipelinedReply(t, serverOut, req)
+
+		requests <- readPipelinedRequest(t, serverIn)
+	}()
+
+	var res tpayload
+
+	require.NoError(t, cl.CallBinary(context.Background(), "echo", newTPayload("foo"), &res))
+	require.NoError(t, cl.CallUnary(context.Background(), "notify", newTPayload("bar")))
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, <-seqIDs, (<-requests).seqID)
+	}
+}
+
+// TestTPipelinedClient_ConnectionFailure verifies that pending calls fail
+// when the connection breaks, and that the client reports the failure on
+// later calls.
+func TestTPipelinedClient_ConnectionFailure(t *testing.T) {
+	cl, serverIn, _, closePipes := pipelinedClientPipe()
+
+	go func() {
+		readPipelinedRequest(t, serverIn)
+		closePipes()
+	}()
+
+	var res tpayload
+
+	assert.Error(t, cl.CallBinary(context.Background(), "echo", newTPayload("x"), &res))
+	assert.Error(t, cl.CallBinary(context.Background(), "echo", newTPayload("y"), &res))
+}
+
+// TestTPipelinedClient_StreamServer verifies that a stream takes the
+// connection for itself and hands it back once closed.
+func TestTPipelinedClient_StreamServer(t *testing.T) {
+	clientProt, serverProt := processorPipe()
+
+	ctx := context.Background()
+	p := NewTStandardProcessor(nil)
+
+	var wg sync.WaitGroup
+
+	p.AddProcessor(
+		"stream_server",
+		NewTStreamServerProcessorFunction(
+			p,
+			"stream_server",
+			func() TRequest { return newTString("") },
+			&streamServerHandler{wg: &wg},
+		),
+	)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(
+			p,
+			"echo",
+			func() TRequest { return newTString("") },
+			&binaryHandler{},
+		),
+	)
+
+	go func() {
+		p.Process(ctx, serverProt, serverProt) //nolint:errcheck
+		p.Process(ctx, serverProt, serverProt) //nolint:errcheck
+	}()
+
+	cl := NewTPipelinedClient(NewStreamTransport(nil, nil), NewTBinaryProtocolFactoryDefault())
+	cl.iprot = clientProt
+	cl.oprot = clientProt
+
+	var resp tstring
+
+	istream, err := cl.StreamServer(ctx, "stream_server", newTString("data"), &resp)
+	require.NoError(t, err)
+	assert.Equal(t, tstring("resp"), resp)
+
+	var msgs []string
+
+	for {
+		var v tstring
+
+		if err := istream.Receive(ctx, &v); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+
+		msgs = append(msgs, string(v))
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, []string{"bar", "biz"}, msgs)
+	require.NoError(t, istream.Close())
+
+	callc := make(chan error, 1)
+
+	go func() {
+		var resp tstring
+
+		callc <- cl.CallBinary(ctx, "echo", newTString("after"), &resp)
+	}()
+
+	select {
+	case err := <-callc:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the stream never released the connection")
+	}
+}
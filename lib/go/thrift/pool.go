@@ -1,5 +1,17 @@
 package thrift
 
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/upfluence/errors"
+)
+
+const DefaultPoolMaxIdle = 2
+
+var ErrPoolClosed = errors.New("thrift: pool closed")
+
 type TPoolProvider interface {
 	BuildClient() TClientProvider
 	BuildPool(func() (interface{}, error)) (TPool, error)
@@ -10,3 +22,436 @@ type TPool interface {
 	Put(Context, interface{}) error
 	Discard(Context, interface{}) error
 }
+
+type TPoolOptions struct {
+	// Maximum number of values handed out or idle at once, zero means no
+	// limit.
+	MaxOpen int
+	// Maximum number of idle values kept, zero means DefaultPoolMaxIdle.
+	MaxIdle int
+	// Idle values are closed after this duration, zero means never.
+	IdleTimeout time.Duration
+}
+
+type poolEntry struct {
+	v        interface{}
+	returned time.Time
+}
+
+// poolGrant is handed to a waiter, either an idle value or, when v is nil,
+// the right to open a new one.
+type poolGrant struct {
+	v interface{}
+}
+
+// TStandardPool is a TPool keeping at most MaxOpen values alive. A value is
+// checked for health on checkout when it implements IsOpen() bool, and
+// closed when discarded or evicted if it implements io.Closer.
+type TStandardPool struct {
+	fn   func() (interface{}, error)
+	opts TPoolOptions
+
+	mu      sync.Mutex
+	idle    []poolEntry
+	waiters []chan poolGrant
+	numOpen int
+	closed  bool
+
+	closec chan struct{}
+}
+
+func NewTStandardPool(fn func() (interface{}, error), opts TPoolOptions) *TStandardPool {
+	if opts.MaxIdle == 0 {
+		opts.MaxIdle = DefaultPoolMaxIdle
+	}
+
+	if opts.MaxOpen > 0 && opts.MaxIdle > opts.MaxOpen {
+		opts.MaxIdle = opts.MaxOpen
+	}
+
+	p := &TStandardPool{fn: fn, opts: opts, closec: make(chan struct{})}
+
+	if opts.IdleTimeout > 0 {
+		go p.evictLoop()
+	}
+
+	return p
+}
+
+func closeValue(v interface{}) {
+	if c, ok := v.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func isHealthy(v interface{}) bool {
+	if c, ok := v.(interface{ IsOpen() bool }); ok {
+		return c.IsOpen()
+	}
+
+	return true
+}
+
+func (p *TStandardPool) expired(e poolEntry, now time.Time) bool {
+	return p.opts.IdleTimeout > 0 && now.Sub(e.returned) > p.opts.IdleTimeout
+}
+
+func (p *TStandardPool) evictLoop() {
+	interval := p.opts.IdleTimeout / 2
+
+	if interval <= 0 {
+		interval = p.opts.IdleTimeout
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.closec:
+			return
+		case now := <-t.C:
+			p.evict(now)
+		}
+	}
+}
+
+func (p *TStandardPool) evict(now time.Time) {
+	var evicted []interface{}
+
+	p.mu.Lock()
+
+	// idle is ordered by return time, the oldest values come first.
+	for len(p.idle) > 0 && p.expired(p.idle[0], now) {
+		evicted = append(evicted, p.idle[0].v)
+		p.idle = p.idle[1:]
+		p.releaseLocked()
+	}
+
+	p.mu.Unlock()
+
+	for _, v := range evicted {
+		closeValue(v)
+	}
+}
+
+// releaseLocked frees the slot of a value that got closed, handing it to the
+// first waiter if any.
+func (p *TStandardPool) releaseLocked() {
+	if len(p.waiters) > 0 && !p.closed {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		w <- poolGrant{}
+
+		return
+	}
+
+	p.numOpen--
+}
+
+func (p *TStandardPool) Get(ctx Context) (interface{}, error) {
+	for {
+		v, err := p.get(ctx)
+
+		if err != nil || v != nil {
+			return v, err
+		}
+	}
+}
+
+// get returns a nil value and a nil error when it had to drop an unhealthy
+// idle value, the caller then tries again.
+func (p *TStandardPool) get(ctx Context) (interface{}, error) {
+	p.mu.Lock()
+
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+
+	if n := len(p.idle); n > 0 {
+		e := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+
+		if p.expired(e, time.Now()) || !isHealthy(e.v) {
+			p.releaseLocked()
+			p.mu.Unlock()
+			closeValue(e.v)
+
+			return nil, nil
+		}
+
+		p.mu.Unlock()
+
+		return e.v, nil
+	}
+
+	if p.opts.MaxOpen <= 0 || p.numOpen < p.opts.MaxOpen {
+		p.numOpen++
+		p.mu.Unlock()
+
+		return p.open()
+	}
+
+	w := make(chan poolGrant, 1)
+	p.waiters = append(p.waiters, w)
+	p.mu.Unlock()
+
+	select {
+	case g, ok := <-w:
+		if !ok {
+			return nil, ErrPoolClosed
+		}
+
+		return p.grant(g)
+	case <-ctx.Done():
+	}
+
+	p.mu.Lock()
+
+	for i, ww := range p.waiters {
+		if ww == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			p.mu.Unlock()
+
+			return nil, ctx.Err()
+		}
+	}
+
+	p.mu.Unlock()
+
+	// The grant raced with the cancellation, give it back.
+	if g, ok := <-w; !ok {
+		return nil, ErrPoolClosed
+	} else if g.v != nil {
+		p.Put(ctx, g.v)
+	} else {
+		p.mu.Lock()
+		p.releaseLocked()
+		p.mu.Unlock()
+	}
+
+	return nil, ctx.Err()
+}
+
+func (p *TStandardPool) grant(g poolGrant) (interface{}, error) {
+	if g.v == nil {
+		return p.open()
+	}
+
+	if !isHealthy(g.v) {
+		p.Discard(defaultCtx, g.v)
+		return nil, nil
+	}
+
+	return g.v, nil
+}
+
+func (p *TStandardPool) open() (interface{}, error) {
+	v, err := p.fn()
+
+	if err != nil {
+		p.mu.Lock()
+		p.releaseLocked()
+		p.mu.Unlock()
+
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (p *TStandardPool) Put(_ Context, v interface{}) error {
+	p.mu.Lock()
+
+	if p.closed {
+		p.numOpen--
+		p.mu.Unlock()
+		closeValue(v)
+
+		return nil
+	}
+
+	if len(p.waiters) > 0 {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.mu.Unlock()
+		w <- poolGrant{v: v}
+
+		return nil
+	}
+
+	if len(p.idle) < p.opts.MaxIdle {
+		p.idle = append(p.idle, poolEntry{v: v, returned: time.Now()})
+		p.mu.Unlock()
+
+		return nil
+	}
+
+	p.numOpen--
+	p.mu.Unlock()
+	closeValue(v)
+
+	return nil
+}
+
+func (p *TStandardPool) Discard(_ Context, v interface{}) error {
+	p.mu.Lock()
+	p.releaseLocked()
+	p.mu.Unlock()
+
+	closeValue(v)
+
+	return nil
+}
+
+// Close closes the idle values and makes the subsequent Get calls fail,
+// values currently handed out are closed when they are put back.
+func (p *TStandardPool) Close() error {
+	p.mu.Lock()
+
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.numOpen -= len(idle)
+
+	for _, w := range p.waiters {
+		close(w)
+	}
+
+	p.waiters = nil
+	p.mu.Unlock()
+
+	close(p.closec)
+
+	for _, e := range idle {
+		closeValue(e.v)
+	}
+
+	return nil
+}
+
+// TStandardPoolProvider builds pools of TSyncClient, dialing a new
+// connection from TransportFactory.GetTransport(nil) whenever the pool needs
+// one.
+type TStandardPoolProvider struct {
+	TransportFactory TTransportFactory
+	ProtocolFactory  TProtocolFactory
+
+	Middlewares []TMiddlewareBuilder
+	Options     TPoolOptions
+}
+
+func (p *TStandardPoolProvider) BuildPool(fn func() (interface{}, error)) (TPool, error) {
+	return NewTStandardPool(fn, p.Options), nil
+}
+
+func (p *TStandardPoolProvider) BuildClient() TClientProvider {
+	return &tPoolClientProvider{provider: p}
+}
+
+func (p *TStandardPoolProvider) dial(ms []TMiddleware) (interface{}, error) {
+	trans, err := dialTransport(p.TransportFactory)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTSyncClient(trans, p.ProtocolFactory, ms...), nil
+}
+
+type tPoolClientProvider struct {
+	provider *TStandardPoolProvider
+}
+
+func (cp *tPoolClientProvider) Build(namespace, service string) (TClient, error) {
+	ms := make([]TMiddleware, len(cp.provider.Middlewares))
+
+	for i, b := range cp.provider.Middlewares {
+		ms[i] = b.Build(namespace, service)
+	}
+
+	pool, err := cp.provider.BuildPool(
+		func() (interface{}, error) { return cp.provider.dial(ms) },
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTPoolClient(pool), nil
+}
+
+// TPoolClient is a TClient borrowing a TClient from its pool for every call.
+// The borrowed client is discarded when the call fails in a way that leaves
+// the connection in an unknown state.
+//
+// The TPoolClient owns its pool: the caller must Close it once done to
+// release the pooled clients.
+type TPoolClient struct {
+	pool TPool
+}
+
+func NewTPoolClient(p TPool) *TPoolClient {
+	return &TPoolClient{pool: p}
+}
+
+// Close closes the underlying pool when it implements io.Closer.
+func (c *TPoolClient) Close() error {
+	if cl, ok := c.pool.(io.Closer); ok {
+		return cl.Close()
+	}
+
+	return nil
+}
+
+func isBrokenConnection(err error) bool {
+	var (
+		terr TTransportException
+		perr TProtocolException
+		aerr TApplicationException
+	)
+
+	switch {
+	case errors.As(err, &terr), errors.As(err, &perr):
+		return true
+	case errors.As(err, &aerr):
+		switch aerr.TypeId() {
+		case WRONG_METHOD_NAME, BAD_SEQUENCE_ID, INVALID_MESSAGE_TYPE_EXCEPTION:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *TPoolClient) do(ctx Context, fn func(TClient) error) error {
+	v, err := c.pool.Get(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	err = fn(v.(TClient))
+
+	if isBrokenConnection(err) {
+		c.pool.Discard(ctx, v)
+	} else {
+		c.pool.Put(ctx, v)
+	}
+
+	return err
+}
+
+func (c *TPoolClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	return c.do(ctx, func(cl TClient) error { return cl.CallBinary(ctx, method, req, res) })
+}
+
+func (c *TPoolClient) CallUnary(ctx Context, method string, req TRequest) error {
+	return c.do(ctx, func(cl TClient) error { return cl.CallUnary(ctx, method, req) })
+}
@@ -0,0 +1,222 @@
+package thrift
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type poolValue struct {
+	id     int
+	open   atomic.Bool
+	closed atomic.Bool
+}
+
+func (v *poolValue) IsOpen() bool { return v.open.Load() }
+
+func (v *poolValue) Close() error {
+	v.closed.Store(true)
+	return nil
+}
+
+func poolValueFactory() (func() (interface{}, error), *int32) {
+	var n int32
+
+	return func() (interface{}, error) {
+		v := &poolValue{id: int(atomic.AddInt32(&n, 1))}
+		v.open.Store(true)
+
+		return v, nil
+	}, &n
+}
+
+// TestTStandardPool_Reuse verifies that a value put back is handed out again
+// instead of opening a new one.
+func TestTStandardPool_Reuse(t *testing.T) {
+	fn, n := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{})
+	defer p.Close()
+
+	ctx := context.Background()
+
+	v1, err := p.Get(ctx)
+	require.NoError(t, err)
+	require.NoError(t, p.Put(ctx, v1))
+
+	v2, err := p.Get(ctx)
+	require.NoError(t, err)
+
+	assert.Same(t, v1, v2)
+	assert.Equal(t, int32(1), atomic.LoadInt32(n))
+}
+
+// TestTStandardPool_MaxOpen verifies that Get waits for a value to come back
+// once MaxOpen values are handed out, and gives up with the context.
+func TestTStandardPool_MaxOpen(t *testing.T) {
+	fn, n := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{MaxOpen: 1})
+	defer p.Close()
+
+	ctx := context.Background()
+
+	v1, err := p.Get(ctx)
+	require.NoError(t, err)
+
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = p.Get(tctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	vc := make(chan interface{}, 1)
+
+	go func() {
+		v, err := p.Get(ctx)
+		assert.NoError(t, err)
+		vc <- v
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, p.Put(ctx, v1))
+
+	assert.Same(t, v1, <-vc)
+	assert.Equal(t, int32(1), atomic.LoadInt32(n))
+}
+
+// TestTStandardPool_MaxIdle verifies that values in excess of MaxIdle are
+// closed when put back.
+func TestTStandardPool_MaxIdle(t *testing.T) {
+	fn, _ := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{MaxIdle: 1})
+	defer p.Close()
+
+	ctx := context.Background()
+
+	v1, _ := p.Get(ctx)
+	v2, _ := p.Get(ctx)
+
+	require.NoError(t, p.Put(ctx, v1))
+	require.NoError(t, p.Put(ctx, v2))
+
+	assert.False(t, v1.(*poolValue).closed.Load())
+	assert.True(t, v2.(*poolValue).closed.Load())
+}
+
+// TestTStandardPool_HealthCheck verifies that an idle value whose transport
+// got closed is discarded on checkout.
+func TestTStandardPool_HealthCheck(t *testing.T) {
+	fn, n := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{MaxOpen: 1})
+	defer p.Close()
+
+	ctx := context.Background()
+
+	v1, _ := p.Get(ctx)
+	require.NoError(t, p.Put(ctx, v1))
+
+	v1.(*poolValue).open.Store(false)
+
+	v2, err := p.Get(ctx)
+	require.NoError(t, err)
+
+	assert.NotSame(t, v1, v2)
+	assert.True(t, v1.(*poolValue).closed.Load())
+	assert.Equal(t, int32(2), atomic.LoadInt32(n))
+}
+
+// TestTStandardPool_IdleTimeout verifies that idle values get evicted.
+func TestTStandardPool_IdleTimeout(t *testing.T) {
+	fn, _ := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{IdleTimeout: 10 * time.Millisecond})
+	defer p.Close()
+
+	ctx := context.Background()
+
+	v, _ := p.Get(ctx)
+	require.NoError(t, p.Put(ctx, v))
+
+	assert.Eventually(
+		t,
+		func() bool { return v.(*poolValue).closed.Load() },
+		time.Second,
+		5*time.Millisecond,
+	)
+}
+
+type poolTestClient struct {
+	poolValue
+
+	err error
+}
+
+func (c *poolTestClient) CallBinary(Context, string, TRequest, TResponse) error {
+	return c.err
+}
+
+func (c *poolTestClient) CallUnary(Context, string, TRequest) error {
+	return c.err
+}
+
+// TestTPoolClient_DiscardOnTransportError verifies that a client failing
+// with a transport error is discarded while other errors put it back.
+func TestTPoolClient_DiscardOnTransportError(t *testing.T) {
+	var clients []*poolTestClient
+
+	p := NewTStandardPool(
+		func() (interface{}, error) {
+			c := &poolTestClient{}
+			c.open.Store(true)
+			clients = append(clients, c)
+
+			return c, nil
+		},
+		TPoolOptions{MaxOpen: 1},
+	)
+	defer p.Close()
+
+	ctx := context.Background()
+	cl := NewTPoolClient(p)
+
+	require.NoError(t, cl.CallUnary(ctx, "foo", nil))
+
+	clients[0].err = errors.New("declared exception")
+	assert.Error(t, cl.CallUnary(ctx, "foo", nil))
+	assert.False(t, clients[0].closed.Load())
+
+	clients[0].err = NewTTransportException(END_OF_FILE, "EOF")
+	assert.Error(t, cl.CallUnary(ctx, "foo", nil))
+	assert.True(t, clients[0].closed.Load())
+
+	require.NoError(t, cl.CallUnary(ctx, "foo", nil))
+	assert.Len(t, clients, 2)
+}
+
+// TestTPoolClient_Close verifies that closing the client closes the idle
+// clients of its pool and stops the evict loop.
+func TestTPoolClient_Close(t *testing.T) {
+	fn, _ := poolValueFactory()
+	p := NewTStandardPool(fn, TPoolOptions{IdleTimeout: time.Hour})
+
+	ctx := context.Background()
+
+	v, _ := p.Get(ctx)
+	require.NoError(t, p.Put(ctx, v))
+
+	require.NoError(t, NewTPoolClient(p).Close())
+
+	assert.True(t, v.(*poolValue).closed.Load())
+
+	select {
+	case <-p.closec:
+	default:
+		t.Fatal("evict loop still running")
+	}
+
+	_, err := p.Get(ctx)
+	assert.Error(t, err)
+}
@@ -19,6 +19,7 @@
 package thrift
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/upfluence/errors"
@@ -35,6 +36,10 @@ type TProcessor interface {
 type TStandardProcessor struct {
 	ProcessorMap map[string]TProcessorFunction
 	Middlewares  []TMiddleware
+
+	// PanicHandler reports the panics recovered while processing a call,
+	// DefaultPanicHandler is used when nil.
+	PanicHandler TPanicHandler
 }
 
 func NewTStandardProcessor(ms []TMiddleware) *TStandardProcessor {
@@ -48,13 +53,28 @@ func (p *TStandardProcessor) GetMiddlewares() []TMiddleware {
 	return p.Middlewares
 }
 
+func (p *TStandardProcessor) GetPanicHandler() TPanicHandler {
+	return p.PanicHandler
+}
+
 func (p *TStandardProcessor) AddProcessor(fname string, fn TProcessorFunction) {
 	p.ProcessorMap[fname] = fn
 }
 
-func (p *TStandardProcessor) Process(ctx Context, in, out TProtocol) (bool, TException) {
+func (p *TStandardProcessor) Process(ctx Context, in, out TProtocol) (ok bool, terr TException) {
 	name, _, seqID, err := in.ReadMessageBegin()
 
+	ctx = withWriteResponseTHeaders(ctx)
+
+	// The processor functions recover the panics of the middlewares and the
+	// handler, a panic reaching this point happened while reading or writing
+	// a message so the connection is left in an unknown state.
+	defer func() {
+		if v := recover(); v != nil {
+			ok, terr = false, reportPanic(ctx, p, name, v)
+		}
+	}()
+
 	if err != nil {
 		return false, err
 	}
@@ -66,6 +86,7 @@ func (p *TStandardProcessor) Process(ctx Context, in, out TProtocol) (bool, TExc
 	in.Skip(STRUCT)
 	in.ReadMessageEnd()
 	x5 := NewTApplicationException(UNKNOWN_METHOD, "Unknown function "+name)
+	writeResponseTHeaders(ctx, out)
 	out.WriteMessageBegin(name, EXCEPTION, seqID)
 	x5.Write(out)
 	out.WriteMessageEnd()
@@ -81,14 +102,43 @@ type TBaseProcessorFunction struct {
 	fname      string
 	argBuilder func() TRequest
 	middleware TStreamingMiddleware
+
+	// definition is nil when the function was not found in the registered
+	// services.
+	definition *TMethodDefinition
 }
 
 func NewTBaseProcessorFunction(p TProcessor, fname string, builder func() TRequest) *TBaseProcessorFunction {
-	return &TBaseProcessorFunction{
+	var (
+		rm = tRecoveryMiddleware{processor: p}
+		ms = []TMiddleware{rm}
+	)
+
+	if pms := p.GetMiddlewares(); len(pms) > 0 {
+		ms = append(append(ms, pms...), rm)
+	}
+
+	fn := TBaseProcessorFunction{
 		fname:      fname,
 		argBuilder: builder,
-		middleware: WrapMiddlewares(p.GetMiddlewares()),
+		middleware: WrapMiddlewares(ms),
+	}
+
+	if md, ok := LookupMethodDefinition(fname, builder()); ok {
+		fn.definition = &md
+	}
+
+	return &fn
+}
+
+// withDefinition sets the definition of the function on the context of a
+// call, for the middlewares and the handler to read.
+func (p *TBaseProcessorFunction) withDefinition(ctx Context) Context {
+	if p.definition == nil || ctx == nil {
+		return ctx
 	}
+
+	return context.WithValue(ctx, methodDefinitionKey{}, p.definition)
 }
 
 func (p *TBaseProcessorFunction) readRequest(in TProtocol) (TRequest, error) {
@@ -104,32 +154,42 @@ func (p *TBaseProcessorFunction) readRequest(in TProtocol) (TRequest, error) {
 	return args, nil
 }
 
-func (p *TBaseProcessorFunction) writeResponse(out TProtocol, seqID int32, res TResponse, err error) (bool, error) {
+func (p *TBaseProcessorFunction) writeResponse(ctx Context, out TProtocol, seqID int32, res TResponse, err error) (bool, error) {
 	if err != nil {
-		tid := INTERNAL_ERROR
+		var (
+			tid  int32 = INTERNAL_ERROR
+			aerr TApplicationException
+			msg  = fmt.Sprintf("Internal error processing : %s: %s", p.fname, err.Error())
+		)
 
-		if errors.IsTimeout(err) {
+		switch {
+		case errors.As(err, &aerr):
+			tid = aerr.TypeId()
+
+			// The call got rejected before being handled, there is no
+			// internal error to speak of.
+			if tid == OVERLOADED {
+				msg = err.Error()
+			}
+		case errors.IsTimeout(err):
 			tid = INTERNAL_TIME_OUT_ERROR
 		}
 
-		rerr := p.writeException(
-			out,
-			seqID,
-			int32(tid),
-			fmt.Sprintf("Internal error processing : %s: %s", p.fname, err.Error()),
-		)
+		rerr := p.writeException(ctx, out, seqID, tid, msg)
 
 		return rerr == nil, err
 	}
 
-	return true, p.writeReply(out, seqID, res)
+	return true, p.writeReply(ctx, out, seqID, res)
 }
 
 type protocolWriter interface {
 	Write(TProtocol) error
 }
 
-func (p *TBaseProcessorFunction) write(out TProtocol, seqID int32, mType TMessageType, x protocolWriter) error {
+func (p *TBaseProcessorFunction) write(ctx Context, out TProtocol, seqID int32, mType TMessageType, x protocolWriter) error {
+	writeResponseTHeaders(ctx, out)
+
 	err := out.WriteMessageBegin(p.fname, mType, seqID)
 
 	if err2 := x.Write(out); err == nil && err2 != nil {
@@ -147,12 +207,12 @@ func (p *TBaseProcessorFunction) write(out TProtocol, seqID int32, mType TMessag
 	return err
 }
 
-func (p *TBaseProcessorFunction) writeException(out TProtocol, seqID, tID int32, msg string) error {
-	return p.write(out, seqID, EXCEPTION, NewTApplicationException(tID, msg))
+func (p *TBaseProcessorFunction) writeException(ctx Context, out TProtocol, seqID, tID int32, msg string) error {
+	return p.write(ctx, out, seqID, EXCEPTION, NewTApplicationException(tID, msg))
 }
 
-func (p *TBaseProcessorFunction) writeReply(out TProtocol, seqID int32, resp TResponse) error {
-	return p.write(out, seqID, REPLY, resp)
+func (p *TBaseProcessorFunction) writeReply(ctx Context, out TProtocol, seqID int32, resp TResponse) error {
+	return p.write(ctx, out, seqID, REPLY, resp)
 }
 
 type TBinaryHandler interface {
@@ -174,11 +234,17 @@ func NewTBinaryProcessorFunction(p TProcessor, fname string, builder func() TReq
 func (p *TBinaryProcessorFunction) Process(ctx Context, seqID int32, in, out TProtocol) (bool, TException) {
 	var args, err = p.readRequest(in)
 
+	ctx = p.withDefinition(ctx)
+
 	if err != nil {
-		p.writeException(out, seqID, PROTOCOL_ERROR, err.Error())
+		p.writeException(ctx, out, seqID, PROTOCOL_ERROR, err.Error())
 		return false, err
 	}
 
+	if err := deadlineExceeded(ctx); err != nil {
+		return p.writeResponse(ctx, out, seqID, nil, err)
+	}
+
 	res, err := p.middleware.HandleBinaryRequest(
 		ctx,
 		p.fname,
@@ -189,7 +255,7 @@ func (p *TBinaryProcessorFunction) Process(ctx Context, seqID int32, in, out TPr
 		},
 	)
 
-	return p.writeResponse(out, seqID, res, err)
+	return p.writeResponse(ctx, out, seqID, res, err)
 }
 
 type TUnaryHandler interface {
@@ -211,10 +277,16 @@ func NewTUnaryProcessorFunction(p TProcessor, fname string, builder func() TRequ
 func (p *TUnaryProcessorFunction) Process(ctx Context, seqID int32, in, out TProtocol) (bool, TException) {
 	var args, err = p.readRequest(in)
 
+	ctx = p.withDefinition(ctx)
+
 	if err != nil {
 		return false, err
 	}
 
+	if err := deadlineExceeded(ctx); err != nil {
+		return true, err
+	}
+
 	return true, p.middleware.HandleUnaryRequest(
 		ctx,
 		p.fname,
@@ -245,10 +317,16 @@ func NewTStreamServerProcessorFunction(p TProcessor, fname string, builder func(
 func (p *TStreamServerProcessorFunction) Process(ctx Context, seqID int32, in, out TProtocol) (bool, TException) {
 	var args, err = p.readRequest(in)
 
+	ctx = p.withDefinition(ctx)
+
 	if err != nil {
 		return false, err
 	}
 
+	if err := deadlineExceeded(ctx); err != nil {
+		return p.writeResponse(ctx, out, seqID, nil, err)
+	}
+
 	stream := newTServerOutboundStream(p.fname, seqID, in, out)
 
 	res, err := p.middleware.HandleOutboundStream(
@@ -262,16 +340,19 @@ func (p *TStreamServerProcessorFunction) Process(ctx Context, seqID int32, in, o
 		},
 	)
 
-	ok, err := p.writeResponse(out, seqID, res, err)
-
-	stream.ready()
-
-	defer stream.Close()
+	ok, err := p.writeResponse(ctx, out, seqID, res, err)
 
 	if !ok || err != nil {
+		// The exception ended the call, the stream never started.
+		stream.close()
 		return ok, err
 	}
 
+	stream.ready()
+
+	defer stream.Close()
+	defer trackStream(ctx, stream)()
+
 	select {
 	case <-ctx.Done():
 		return true, ctx.Err()
@@ -299,10 +380,16 @@ func NewTStreamClientProcessorFunction(p TProcessor, fname string, builder func(
 func (p *TStreamClientProcessorFunction) Process(ctx Context, seqID int32, in, out TProtocol) (bool, TException) {
 	var args, err = p.readRequest(in)
 
+	ctx = p.withDefinition(ctx)
+
 	if err != nil {
 		return false, err
 	}
 
+	if err := deadlineExceeded(ctx); err != nil {
+		return p.writeResponse(ctx, out, seqID, nil, err)
+	}
+
 	stream := newTServerInboundStream(p.fname, seqID, in, out)
 
 	res, err := p.middleware.HandleInboundStream(
@@ -315,16 +402,20 @@ func (p *TStreamClientProcessorFunction) Process(ctx Context, seqID int32, in, o
 			return p.handler.Handle(ctx, req, s)
 		},
 	)
-	ok, err := p.writeResponse(out, seqID, res, err)
 
-	stream.ready()
-
-	defer stream.Close()
+	ok, err := p.writeResponse(ctx, out, seqID, res, err)
 
 	if !ok || err != nil {
+		// The exception ended the call, the stream never started.
+		stream.close()
 		return ok, err
 	}
 
+	stream.ready()
+
+	defer stream.Close()
+	defer trackStream(ctx, stream)()
+
 	select {
 	case <-ctx.Done():
 		return true, ctx.Err()
@@ -352,10 +443,16 @@ func NewTStreamBidiProcessorFunction(p TProcessor, fname string, builder func()
 func (p *TStreamBidiProcessorFunction) Process(ctx Context, seqID int32, in, out TProtocol) (bool, TException) {
 	var args, err = p.readRequest(in)
 
+	ctx = p.withDefinition(ctx)
+
 	if err != nil {
 		return false, err
 	}
 
+	if err := deadlineExceeded(ctx); err != nil {
+		return p.writeResponse(ctx, out, seqID, nil, err)
+	}
+
 	bidiStream := newTServerBidiStream(p.fname, seqID, in, out)
 
 	res, err := p.middleware.HandleBidiStream(
@@ -369,16 +466,20 @@ func (p *TStreamBidiProcessorFunction) Process(ctx Context, seqID int32, in, out
 			return p.handler.Handle(ctx, req, is, os)
 		},
 	)
-	ok, err := p.writeResponse(out, seqID, res, err)
 
-	bidiStream.ready()
-
-	defer bidiStream.Close()
+	ok, err := p.writeResponse(ctx, out, seqID, res, err)
 
 	if !ok || err != nil {
+		// The exception ended the call, the stream never started.
+		bidiStream.close()
 		return ok, err
 	}
 
+	bidiStream.ready()
+
+	defer bidiStream.Close()
+	defer trackStream(ctx, bidiStream)()
+
 	select {
 	case <-ctx.Done():
 		return true, ctx.Err()
@@ -167,6 +167,35 @@ func TestTBinaryProcessorFunction_HandlerError(t *testing.T) {
 	assert.Equal(t, int32(INTERNAL_ERROR), ex.TypeId())
 }
 
+// TestTBinaryProcessorFunction_ApplicationException verifies that the type of
+// an application exception returned by the handler is written back.
+func TestTBinaryProcessorFunction_ApplicationException(t *testing.T) {
+	clientProt, serverProt := processorPipe()
+
+	h := &binaryHandler{retErr: NewTApplicationException(OVERLOADED, "busy")}
+	p := NewTStandardProcessor(nil)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(p, "echo", func() TRequest { return newTString("") }, h),
+	)
+
+	go p.Process(context.Background(), serverProt, serverProt) //nolint:errcheck
+
+	rawCall(t, clientProt, "echo", 1, "req")
+
+	_, typeID, _, err := clientProt.ReadMessageBegin()
+	require.NoError(t, err)
+	assert.Equal(t, EXCEPTION, typeID)
+
+	var ex tApplicationException
+
+	require.NoError(t, ex.Read(clientProt))
+	require.NoError(t, clientProt.ReadMessageEnd())
+	assert.Equal(t, int32(OVERLOADED), ex.TypeId())
+	assert.Equal(t, "busy", ex.Error())
+}
+
 // TestTUnaryProcessorFunction_NoReply verifies that a one-way call is handled
 // (handler called) and that no reply frame is written to the output protocol.
 func TestTUnaryProcessorFunction_NoReply(t *testing.T) {
@@ -0,0 +1,235 @@
+package thrift
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReconnectMinBackoff = 100 * time.Millisecond
+	DefaultReconnectMaxBackoff = 10 * time.Second
+)
+
+type TReconnectingClientOptions struct {
+	TransportFactory TTransportFactory
+	ProtocolFactory  TProtocolFactory
+	Middlewares      []TMiddleware
+
+	// After a failed dial, calls fail right away until the backoff elapsed,
+	// the backoff doubles after every failed dial.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type tReconnectingClientFactory struct {
+	opts TReconnectingClientOptions
+}
+
+// NewTReconnectingClientFactory returns a TClientFactory building
+// TReconnectingClient, the transport and protocol factories given to
+// GetClient override the ones of opts.
+func NewTReconnectingClientFactory(opts TReconnectingClientOptions) TClientFactory {
+	return &tReconnectingClientFactory{opts: opts}
+}
+
+func (f *tReconnectingClientFactory) GetClient(t TTransportFactory, p TProtocolFactory, ms []TMiddleware) TClient {
+	opts := f.opts
+
+	opts.TransportFactory = t
+	opts.ProtocolFactory = p
+	opts.Middlewares = ms
+
+	return NewTReconnectingClient(opts)
+}
+
+// TReconnectingClient is a TStreamingClient holding a TSyncClient over a
+// connection dialed from TransportFactory.GetTransport(nil). Once a call
+// fails in a way leaving the connection in an unknown state, the connection
+// is closed and a new one, with brand new protocols, is dialed on the next
+// call. The failed call itself is never sent again: it may have reached the
+// server already.
+type TReconnectingClient struct {
+	opts TReconnectingClientOptions
+
+	mu       sync.Mutex
+	client   *TSyncClient
+	backoff  time.Duration
+	nextDial time.Time
+	dialErr  error
+	closed   bool
+}
+
+func NewTReconnectingClient(opts TReconnectingClientOptions) *TReconnectingClient {
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = DefaultReconnectMinBackoff
+	}
+
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = DefaultReconnectMaxBackoff
+	}
+
+	return &TReconnectingClient{opts: opts}
+}
+
+func dialTransport(f TTransportFactory) (TTransport, error) {
+	trans := f.GetTransport(nil)
+
+	if trans == nil {
+		return nil, NewTTransportException(NOT_OPEN, "transport factory returned no transport")
+	}
+
+	if !trans.IsOpen() {
+		if err := trans.Open(); err != nil {
+			return nil, err
+		}
+	}
+
+	return trans, nil
+}
+
+func (c *TReconnectingClient) getClient() (*TSyncClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, NewTTransportException(NOT_OPEN, "reconnecting client closed")
+	}
+
+	if c.client != nil {
+		if c.client.IsOpen() {
+			return c.client, nil
+		}
+
+		c.client.Close()
+		c.client = nil
+	}
+
+	if now := time.Now(); now.Before(c.nextDial) {
+		return nil, NewTTransportExceptionFromError(c.dialErr)
+	}
+
+	trans, err := dialTransport(c.opts.TransportFactory)
+
+	if err != nil {
+		if c.backoff == 0 {
+			c.backoff = c.opts.MinBackoff
+		} else if c.backoff *= 2; c.backoff > c.opts.MaxBackoff {
+			c.backoff = c.opts.MaxBackoff
+		}
+
+		c.dialErr = err
+		c.nextDial = time.Now().Add(c.backoff)
+
+		return nil, NewTTransportExceptionFromError(err)
+	}
+
+	c.backoff = 0
+	c.client = NewTSyncClient(trans, c.opts.ProtocolFactory, c.opts.Middlewares...)
+
+	return c.client, nil
+}
+
+// report drops the connection of cl when err leaves it in an unknown state,
+// the next call dials a new one.
+func (c *TReconnectingClient) report(cl *TSyncClient, err error) {
+	if !isBrokenConnection(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == cl {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+func (c *TReconnectingClient) CallBinary(ctx Context, method string, req TRequest, res TResponse) error {
+	cl, err := c.getClient()
+
+	if err != nil {
+		return err
+	}
+
+	err = cl.CallBinary(ctx, method, req, res)
+	c.report(cl, err)
+
+	return err
+}
+
+func (c *TReconnectingClient) CallUnary(ctx Context, method string, req TRequest) error {
+	cl, err := c.getClient()
+
+	if err != nil {
+		return err
+	}
+
+	err = cl.CallUnary(ctx, method, req)
+	c.report(cl, err)
+
+	return err
+}
+
+func (c *TReconnectingClient) StreamClient(ctx Context, method string, req TRequest, res TResponse) (TOutboundStream, error) {
+	cl, err := c.getClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := cl.StreamClient(ctx, method, req, res)
+	c.report(cl, err)
+
+	return s, err
+}
+
+func (c *TReconnectingClient) StreamServer(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, error) {
+	cl, err := c.getClient()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := cl.StreamServer(ctx, method, req, res)
+	c.report(cl, err)
+
+	return s, err
+}
+
+func (c *TReconnectingClient) StreamBidi(ctx Context, method string, req TRequest, res TResponse) (TInboundStream, TOutboundStream, error) {
+	cl, err := c.getClient()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	is, os, err := cl.StreamBidi(ctx, method, req, res)
+	c.report(cl, err)
+
+	return is, os, err
+}
+
+func (c *TReconnectingClient) IsOpen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return !c.closed
+}
+
+// Close closes the current connection, the subsequent calls fail.
+func (c *TReconnectingClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	if c.client == nil {
+		return nil
+	}
+
+	err := c.client.Close()
+	c.client = nil
+
+	return err
+}
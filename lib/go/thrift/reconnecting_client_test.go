@@ -0,0 +1,131 @@
+package thrift
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type unreachableTransport struct {
+	*StreamTransport
+}
+
+func (unreachableTransport) IsOpen() bool { return false }
+func (unreachableTransport) Open() error  { return errors.New("connection refused") }
+
+// pipeTransportFactory dials in-process connections, each of them served by
+// an echo server hanging up after answering the given number of calls.
+type pipeTransportFactory struct {
+	t     *testing.T
+	calls int
+
+	mu      sync.Mutex
+	dials   int
+	refused bool
+}
+
+func (f *pipeTransportFactory) GetTransport(TTransport) TTransport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.dials++
+
+	if f.refused {
+		return unreachableTransport{StreamTransport: NewStreamTransport(nil, nil)}
+	}
+
+	pr1, pw1 := io.Pipe()
+	pr2, pw2 := io.Pipe()
+
+	serverProt := NewTBinaryProtocolFactoryDefault().GetProtocol(NewStreamTransport(pr2, pw1))
+
+	go func() {
+		for i := 0; i < f.calls; i++ {
+			serveOneBinary(f.t, serverProt)
+		}
+
+		pw1.Close()
+		pr2.Close()
+	}()
+
+	return NewStreamTransport(pr1, pw2)
+}
+
+func (f *pipeTransportFactory) state() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.dials
+}
+
+func (f *pipeTransportFactory) refuse(v bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refused = v
+}
+
+// TestTReconnectingClient_Redial verifies that a broken connection fails the
+// call using it, without resending it, and that the next call dials again.
+func TestTReconnectingClient_Redial(t *testing.T) {
+	var (
+		ctx = context.Background()
+		f   = &pipeTransportFactory{t: t, calls: 1}
+		cl  = NewTReconnectingClient(
+			TReconnectingClientOptions{
+				TransportFactory: f,
+				ProtocolFactory:  NewTBinaryProtocolFactoryDefault(),
+			},
+		)
+	)
+
+	defer cl.Close()
+
+	var resp tstring
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString("first"), &resp))
+	assert.Equal(t, tstring("first"), resp)
+
+	assert.Error(t, cl.CallBinary(ctx, "echo", newTString("lost"), &resp))
+	assert.Equal(t, 1, f.state())
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString("second"), &resp))
+	assert.Equal(t, tstring("second"), resp)
+	assert.Equal(t, 2, f.state())
+}
+
+// TestTReconnectingClient_Backoff verifies that calls fail fast without
+// dialing while the backoff following a failed dial is not over.
+func TestTReconnectingClient_Backoff(t *testing.T) {
+	var (
+		ctx = context.Background()
+		f   = &pipeTransportFactory{t: t, calls: 1, refused: true}
+		cl  = NewTReconnectingClient(
+			TReconnectingClientOptions{
+				TransportFactory: f,
+				ProtocolFactory:  NewTBinaryProtocolFactoryDefault(),
+				MinBackoff:       50 * time.Millisecond,
+			},
+		)
+	)
+
+	defer cl.Close()
+
+	var resp tstring
+
+	assert.Error(t, cl.CallBinary(ctx, "echo", newTString("x"), &resp))
+	assert.Error(t, cl.CallBinary(ctx, "echo", newTString("x"), &resp))
+	assert.Equal(t, 1, f.state())
+
+	f.refuse(false)
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString("x"), &resp))
+	assert.Equal(t, 2, f.state())
+}
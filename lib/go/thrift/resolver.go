@@ -0,0 +1,80 @@
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TResolver returns the list of endpoint addresses a client can send its
+// calls to.
+type TResolver interface {
+	Resolve(Context) ([]string, error)
+}
+
+type TStaticResolver []string
+
+func NewTStaticResolver(addrs ...string) TStaticResolver {
+	return TStaticResolver(addrs)
+}
+
+func (r TStaticResolver) Resolve(Context) ([]string, error) {
+	return []string(r), nil
+}
+
+// TFileResolver reads the endpoints from a file holding one address per
+// line, blank lines and lines starting with '#' are ignored. The file is
+// parsed again only when its modification time changes.
+type TFileResolver struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	addrs   []string
+}
+
+func NewTFileResolver(path string) *TFileResolver {
+	return &TFileResolver{path: path}
+}
+
+func (r *TFileResolver) Resolve(Context) ([]string, error) {
+	fi, err := os.Stat(r.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.addrs != nil && fi.ModTime().Equal(r.modTime) {
+		return r.addrs, nil
+	}
+
+	buf, err := os.ReadFile(r.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []string{}
+	s := bufio.NewScanner(bytes.NewReader(buf))
+
+	for s.Scan() {
+		if l := strings.TrimSpace(s.Text()); l != "" && !strings.HasPrefix(l, "#") {
+			addrs = append(addrs, l)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	r.modTime = fi.ModTime()
+	r.addrs = addrs
+
+	return addrs, nil
+}
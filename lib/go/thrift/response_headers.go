@@ -0,0 +1,141 @@
+package thrift
+
+import (
+	"context"
+	"sync"
+)
+
+// TResponseHeaders holds the THeaders of a response: the ones a handler sends
+// back to the client, or the ones a client read along with the reply.
+type TResponseHeaders struct {
+	mu      sync.Mutex
+	headers THeaderMap
+
+	// parent is the holder of the context WithResponseHeaders was called
+	// with, it is handed over the headers read as well.
+	parent *TResponseHeaders
+}
+
+func (h *TResponseHeaders) Get(key string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, ok := h.headers[key]
+	return v, ok
+}
+
+func (h *TResponseHeaders) Set(key, value string) {
+	h.mu.Lock()
+
+	if h.headers == nil {
+		h.headers = make(THeaderMap)
+	}
+
+	h.headers[key] = value
+	h.mu.Unlock()
+
+	if h.parent != nil {
+		h.parent.Set(key, value)
+	}
+}
+
+// Headers returns a copy of the headers held.
+func (h *TResponseHeaders) Headers() THeaderMap {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hs := make(THeaderMap, len(h.headers))
+
+	for k, v := range h.headers {
+		hs[k] = v
+	}
+
+	return hs
+}
+
+type writeResponseTHeadersKey struct{}
+type readResponseTHeadersKey struct{}
+
+func withWriteResponseTHeaders(ctx Context) Context {
+	if ctx == nil {
+		return nil
+	}
+
+	return context.WithValue(ctx, writeResponseTHeadersKey{}, &TResponseHeaders{})
+}
+
+// SetResponseHeader sets a THeader sent back to the client along with the
+// response of the call ctx was received for. It reports false when ctx is not
+// the one of a call processed by a TProcessor. The header is only written
+// when the connection speaks THeader.
+func SetResponseHeader(ctx Context, key, value string) bool {
+	if ctx == nil {
+		return false
+	}
+
+	h, ok := ctx.Value(writeResponseTHeadersKey{}).(*TResponseHeaders)
+
+	if ok {
+		h.Set(key, value)
+	}
+
+	return ok
+}
+
+// WithResponseHeaders returns a copy of ctx collecting the THeaders read
+// along with the replies of the calls made with it. The headers are collected
+// by the holder ctx already carries, if any, as well.
+func WithResponseHeaders(ctx Context) (Context, *TResponseHeaders) {
+	var h TResponseHeaders
+
+	h.parent, _ = GetResponseHeaders(ctx)
+
+	return context.WithValue(ctx, readResponseTHeadersKey{}, &h), &h
+}
+
+// GetResponseHeaders returns the headers collected by the calls made with
+// ctx, if it was built by WithResponseHeaders.
+func GetResponseHeaders(ctx Context) (*TResponseHeaders, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	h, ok := ctx.Value(readResponseTHeadersKey{}).(*TResponseHeaders)
+	return h, ok
+}
+
+// writeResponseTHeaders replaces the write headers of oprot, when it speaks
+// THeader, by the ones set with SetResponseHeader.
+func writeResponseTHeaders(ctx Context, oprot TProtocol) {
+	hp, ok := oprot.(*THeaderProtocol)
+
+	if !ok {
+		return
+	}
+
+	hp.ClearWriteHeaders()
+
+	if ctx == nil {
+		return
+	}
+
+	if h, ok := ctx.Value(writeResponseTHeadersKey{}).(*TResponseHeaders); ok {
+		for k, v := range h.Headers() {
+			hp.SetWriteHeader(k, v)
+		}
+	}
+}
+
+// readResponseTHeaders copies the read headers of iprot, when it speaks
+// THeader, into h.
+func readResponseTHeaders(h *TResponseHeaders, iprot TProtocol) {
+	hp, ok := iprot.(*THeaderProtocol)
+
+	if !ok || h == nil {
+		return
+	}
+
+	for k, v := range hp.GetReadHeaders() {
+		h.Set(k, v)
+	}
+}
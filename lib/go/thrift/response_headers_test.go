@@ -0,0 +1,82 @@
+package thrift
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type headerHandler struct{}
+
+func (headerHandler) Handle(ctx Context, req TRequest) (TResponse, error) {
+	SetResponseHeader(ctx, "echo", req.(*tstring).String())
+
+	return req.(TResponse), nil
+}
+
+// TestResponseHeaders verifies that the headers set by a handler reach the
+// client, and only for the response of the call they were set for.
+func TestResponseHeaders(t *testing.T) {
+	pr1, pw1 := io.Pipe()
+	pr2, pw2 := io.Pipe()
+
+	var (
+		client = NewTSyncClient(
+			NewTHeaderTransport(NewStreamTransport(pr1, pw2)),
+			NewTHeaderProtocolFactory(),
+		)
+		serverProt = NewTHeaderProtocol(NewStreamTransport(pr2, pw1))
+
+		p = NewTStandardProcessor(nil)
+	)
+
+	p.AddProcessor(
+		"echo",
+		NewTBinaryProcessorFunction(p, "echo", func() TRequest { return newTString("") }, headerHandler{}),
+	)
+	p.AddProcessor(
+		"plain",
+		NewTBinaryProcessorFunction(p, "plain", func() TRequest { return newTString("") }, &binaryHandler{}),
+	)
+
+	go func() {
+		for {
+			if ok, _ := p.Process(context.Background(), serverProt, serverProt); !ok {
+				return
+			}
+		}
+	}()
+
+	defer pw2.Close()
+
+	ctx, hs := WithResponseHeaders(context.Background())
+
+	require.NoError(t, client.CallBinary(ctx, "echo", newTString("foo"), newTString("")))
+
+	v, ok := hs.Get("echo")
+	assert.True(t, ok)
+	assert.Equal(t, "foo", v)
+
+	ctx, hs = WithResponseHeaders(context.Background())
+
+	require.NoError(t, client.CallBinary(ctx, "plain", newTString("bar"), newTString("")))
+	assert.Empty(t, hs.Headers())
+}
+
+func TestSetResponseHeader_NoCall(t *testing.T) {
+	assert.False(t, SetResponseHeader(context.Background(), "foo", "bar"))
+}
+
+func TestWithResponseHeaders_Nested(t *testing.T) {
+	ctx, outer := WithResponseHeaders(context.Background())
+	_, inner := WithResponseHeaders(ctx)
+
+	inner.Set("foo", "bar")
+
+	v, ok := outer.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", v)
+}
@@ -0,0 +1,211 @@
+package thrift
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	connActive int32 = iota
+	connIdle
+	connClosed
+)
+
+// TServerConn is a connection served by TSimpleServer, it tracks whether a
+// request is in progress on it and the streams it carries for the server to
+// shut down gracefully.
+//
+// The input and output transport factories of the server receive it in
+// place of the accepted transport, Underlying returns the latter.
+type TServerConn struct {
+	TTransport
+
+	id uint64
+
+	state   int32
+	streams tStreamSet
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// workers is the pool of the server, working tells whether the
+	// connection holds one of them.
+	workers chan struct{}
+	working bool
+
+	readTimeout time.Duration
+	// readTimer closes the connection when a read of a request in progress
+	// lasts longer than readTimeout. It is armed for every read.
+	readTimer *time.Timer
+	idleTimer *time.Timer
+}
+
+func newTServerConn(client TTransport) *TServerConn {
+	ctx, cancel := context.WithCancel(defaultCtx)
+
+	return &TServerConn{TTransport: client, id: nextConnID(), ctx: ctx, cancel: cancel}
+}
+
+// Underlying returns the transport accepted by the server.
+func (c *TServerConn) Underlying() TTransport {
+	return c.TTransport
+}
+
+func (c *TServerConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 && atomic.LoadInt32(&c.state) == connActive && !c.streams.open() {
+		if c.readTimer == nil {
+			c.readTimer = time.AfterFunc(c.readTimeout, c.forceClose)
+		} else {
+			c.readTimer.Reset(c.readTimeout)
+		}
+
+		defer c.readTimer.Stop()
+	}
+
+	n, err := c.TTransport.Read(b)
+
+	if n > 0 && !c.activate() {
+		return 0, NewTTransportExceptionFromError(io.EOF)
+	}
+
+	return n, err
+}
+
+// activate marks a request as in progress, once a worker is available, it
+// fails when the connection was closed.
+func (c *TServerConn) activate() bool {
+	if !atomic.CompareAndSwapInt32(&c.state, connIdle, connActive) {
+		return atomic.LoadInt32(&c.state) == connActive
+	}
+
+	c.stopIdleTimer()
+
+	if c.workers == nil {
+		return true
+	}
+
+	select {
+	case c.workers <- struct{}{}:
+		c.working = true
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+func (c *TServerConn) releaseWorker() {
+	if c.working {
+		<-c.workers
+		c.working = false
+	}
+}
+
+func (c *TServerConn) stopIdleTimer() {
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+}
+
+func (c *TServerConn) closed() bool {
+	return atomic.LoadInt32(&c.state) == connClosed
+}
+
+// interrupt closes the connection underneath while it is in use, the
+// sockets are interrupted rather than closed for that matter.
+func (c *TServerConn) interrupt() {
+	if i, ok := c.TTransport.(interface{ Interrupt() error }); ok {
+		i.Interrupt()
+		return
+	}
+
+	c.TTransport.Close()
+}
+
+// closeIfIdle closes the connection if it is waiting for a request.
+func (c *TServerConn) closeIfIdle() {
+	if atomic.CompareAndSwapInt32(&c.state, connIdle, connClosed) {
+		c.interrupt()
+	}
+}
+
+// forceClose closes the connection and cancels the context of the request in
+// progress.
+func (c *TServerConn) forceClose() {
+	atomic.StoreInt32(&c.state, connClosed)
+	c.cancel()
+	c.interrupt()
+}
+
+type streamGoAwayer interface {
+	// goAway ends the stream on behalf of the server, the peer is told to
+	// stop sending and receiving messages.
+	goAway()
+}
+
+// tStreamSet is the set of the streams open on a connection.
+type tStreamSet struct {
+	mu        sync.Mutex
+	streams   map[streamGoAwayer]struct{}
+	goingAway bool
+}
+
+func (ss *tStreamSet) add(s streamGoAwayer) func() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.goingAway {
+		go s.goAway()
+		return func() {}
+	}
+
+	if ss.streams == nil {
+		ss.streams = make(map[streamGoAwayer]struct{})
+	}
+
+	ss.streams[s] = struct{}{}
+
+	return func() {
+		ss.mu.Lock()
+		delete(ss.streams, s)
+		ss.mu.Unlock()
+	}
+}
+
+func (ss *tStreamSet) open() bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	return len(ss.streams) > 0
+}
+
+// goAway ends the streams open and the ones opened later.
+func (ss *tStreamSet) goAway() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ss.goingAway = true
+
+	for s := range ss.streams {
+		go s.goAway()
+	}
+}
+
+type streamSetKey struct{}
+
+func withStreamSet(ctx Context, ss *tStreamSet) Context {
+	return context.WithValue(ctx, streamSetKey{}, ss)
+}
+
+// trackStream adds s to the streams of the connection serving ctx, if any,
+// it returns the function removing it.
+func trackStream(ctx Context, s streamGoAwayer) func() {
+	if ss, ok := ctx.Value(streamSetKey{}).(*tStreamSet); ok {
+		return ss.add(s)
+	}
+
+	return func() {}
+}
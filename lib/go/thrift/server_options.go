@@ -0,0 +1,112 @@
+package thrift
+
+import (
+	"log"
+	"time"
+)
+
+// TServerOption configures the server built by NewTServer.
+type TServerOption func(*TSimpleServer)
+
+// WithTransportFactory sets the factory wrapping the connections, for both
+// reading and writing, it defaults to NewTTransportFactory().
+func WithTransportFactory(f TTransportFactory) TServerOption {
+	return WithTransportFactories(f, f)
+}
+
+// WithTransportFactories sets the factories wrapping the connections for
+// reading and for writing. The factories receive the connections as a
+// *TServerConn, whose Underlying method returns the accepted transport.
+func WithTransportFactories(in, out TTransportFactory) TServerOption {
+	return func(p *TSimpleServer) {
+		p.inputTransportFactory = in
+		p.outputTransportFactory = out
+	}
+}
+
+// WithProtocolFactory sets the protocol of the server, it defaults to the
+// binary protocol.
+func WithProtocolFactory(f TProtocolFactory) TServerOption {
+	return WithProtocolFactories(f, f)
+}
+
+// WithProtocolFactories sets the protocols used for reading the requests and
+// for writing the responses. The input protocol is used both ways when it is
+// a THeaderProtocol, for the responses to be in the dialect of the requests.
+func WithProtocolFactories(in, out TProtocolFactory) TServerOption {
+	return func(p *TSimpleServer) {
+		p.inputProtocolFactory = in
+		p.outputProtocolFactory = out
+	}
+}
+
+// WithForwardHeaders sets the headers forwarded while using THeaderProtocol,
+// see SetForwardHeaders.
+func WithForwardHeaders(headers ...string) TServerOption {
+	return func(p *TSimpleServer) { p.SetForwardHeaders(headers) }
+}
+
+// WithMaxConnections bounds the number of connections served at once, the
+// connections beyond it wait to be accepted. Zero means no limit.
+func WithMaxConnections(n int) TServerOption {
+	return func(p *TSimpleServer) { p.maxConns = n }
+}
+
+// WithMaxWorkers bounds the number of requests processed at once across the
+// connections, a request waits for a worker once its first bytes were read.
+// A stream holds its worker until it ends. Zero means no limit.
+func WithMaxWorkers(n int) TServerOption {
+	return func(p *TSimpleServer) { p.maxWorkers = n }
+}
+
+// WithReadTimeout bounds the time a connection may stall while a request is
+// being read, the connection is closed beyond it. The streams are not
+// subject to it. Zero means no limit.
+func WithReadTimeout(d time.Duration) TServerOption {
+	return func(p *TSimpleServer) { p.readTimeout = d }
+}
+
+// WithIdleTimeout bounds the time a connection may wait for its next
+// request, the connection is closed beyond it. Zero means no limit.
+func WithIdleTimeout(d time.Duration) TServerOption {
+	return func(p *TSimpleServer) { p.idleTimeout = d }
+}
+
+// WithErrorLogger sets the function the errors the server runs into are
+// reported to, they are logged with the standard logger by default.
+func WithErrorLogger(fn func(*TServerError)) TServerOption {
+	return func(p *TSimpleServer) { p.errorLogger = fn }
+}
+
+// WithConnOpenHook sets a function called with every connection accepted,
+// before any of its requests is processed.
+func WithConnOpenHook(fn func(client TTransport)) TServerOption {
+	return func(p *TSimpleServer) { p.onConnOpen = fn }
+}
+
+// WithConnCloseHook sets a function called with every connection once done
+// serving it, along with the error that ended it, if any.
+func WithConnCloseHook(fn func(client TTransport, err error)) TServerOption {
+	return func(p *TSimpleServer) { p.onConnClose = fn }
+}
+
+// TServerError is an error the server ran into, while accepting the
+// connections or while processing the requests of one of them.
+type TServerError struct {
+	// Op is either "accept" or "process".
+	Op string
+	// Client is the connection the error happened on, nil for the accept
+	// errors.
+	Client TTransport
+	Err    error
+}
+
+func (e *TServerError) Error() string {
+	return "thrift: " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *TServerError) Unwrap() error { return e.Err }
+
+func defaultServerErrorLogger(e *TServerError) {
+	log.Println(e)
+}
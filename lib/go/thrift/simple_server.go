@@ -20,9 +20,11 @@
 package thrift
 
 import (
-	"log"
+	"context"
+	"crypto/tls"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 /*
@@ -32,9 +34,16 @@ import (
  */
 type TSimpleServer struct {
 	closed int32
+	closec chan struct{}
 	wg     sync.WaitGroup
 	mu     sync.Mutex
 
+	connMu sync.Mutex
+	conns  map[*TServerConn]struct{}
+
+	connSlots chan struct{}
+	workers   chan struct{}
+
 	processorFactory       TProcessorFactory
 	serverTransport        TServerTransport
 	inputTransportFactory  TTransportFactory
@@ -44,7 +53,51 @@ type TSimpleServer struct {
 
 	// Headers to auto forward in THeaderProtocol
 	forwardHeaders []string
-	errorLogger    *func(error)
+	errorLogger    func(*TServerError)
+
+	maxConns    int
+	maxWorkers  int
+	readTimeout time.Duration
+	idleTimeout time.Duration
+
+	onConnOpen  func(TTransport)
+	onConnClose func(TTransport, error)
+}
+
+// NewTServer returns a server processing the requests with processor, it is
+// configured by opts and defaults to the binary protocol over unwrapped
+// connections.
+func NewTServer(processor TProcessor, serverTransport TServerTransport, opts ...TServerOption) *TSimpleServer {
+	return NewTServerFactory(NewTProcessorFactory(processor), serverTransport, opts...)
+}
+
+// NewTServerFactory returns a server processing the requests of every
+// connection with the processor processorFactory returns for it.
+func NewTServerFactory(processorFactory TProcessorFactory, serverTransport TServerTransport, opts ...TServerOption) *TSimpleServer {
+	p := TSimpleServer{
+		closec:                 make(chan struct{}),
+		processorFactory:       processorFactory,
+		serverTransport:        serverTransport,
+		inputTransportFactory:  NewTTransportFactory(),
+		outputTransportFactory: NewTTransportFactory(),
+		inputProtocolFactory:   NewTBinaryProtocolFactoryDefault(),
+		outputProtocolFactory:  NewTBinaryProtocolFactoryDefault(),
+		errorLogger:            defaultServerErrorLogger,
+	}
+
+	for _, opt := range opts {
+		opt(&p)
+	}
+
+	if p.maxConns > 0 {
+		p.connSlots = make(chan struct{}, p.maxConns)
+	}
+
+	if p.maxWorkers > 0 {
+		p.workers = make(chan struct{}, p.maxWorkers)
+	}
+
+	return &p
 }
 
 func NewTSimpleServer2(processor TProcessor, serverTransport TServerTransport) *TSimpleServer {
@@ -90,18 +143,18 @@ func NewTSimpleServerFactory4(processorFactory TProcessorFactory, serverTranspor
 }
 
 func NewTSimpleServerFactory6(processorFactory TProcessorFactory, serverTransport TServerTransport, inputTransportFactory TTransportFactory, outputTransportFactory TTransportFactory, inputProtocolFactory TProtocolFactory, outputProtocolFactory TProtocolFactory) *TSimpleServer {
-	return &TSimpleServer{
-		processorFactory:       processorFactory,
-		serverTransport:        serverTransport,
-		inputTransportFactory:  inputTransportFactory,
-		outputTransportFactory: outputTransportFactory,
-		inputProtocolFactory:   inputProtocolFactory,
-		outputProtocolFactory:  outputProtocolFactory,
-	}
+	return NewTServerFactory(
+		processorFactory,
+		serverTransport,
+		WithTransportFactories(inputTransportFactory, outputTransportFactory),
+		WithProtocolFactories(inputProtocolFactory, outputProtocolFactory),
+	)
 }
 
+// SetErrorLogger sets the function the errors the server runs into are
+// reported to, without their context, see WithErrorLogger.
 func (p *TSimpleServer) SetErrorLogger(fn func(error)) {
-	p.errorLogger = &fn
+	p.errorLogger = func(e *TServerError) { fn(e.Err) }
 }
 
 func (p *TSimpleServer) ProcessorFactory() TProcessorFactory {
@@ -152,6 +205,27 @@ func (p *TSimpleServer) SetForwardHeaders(headers []string) {
 	p.forwardHeaders = keys
 }
 
+// acquireConnSlot waits for the number of connections served to get below
+// the limit, it fails once the server is stopped.
+func (p *TSimpleServer) acquireConnSlot() bool {
+	if p.connSlots == nil {
+		return true
+	}
+
+	select {
+	case p.connSlots <- struct{}{}:
+		return true
+	case <-p.closec:
+		return false
+	}
+}
+
+func (p *TSimpleServer) releaseConnSlot() {
+	if p.connSlots != nil {
+		<-p.connSlots
+	}
+}
+
 func (p *TSimpleServer) innerAccept() (int32, error) {
 	client, err := p.serverTransport.Accept()
 	p.mu.Lock()
@@ -163,30 +237,43 @@ func (p *TSimpleServer) innerAccept() (int32, error) {
 	if err != nil {
 		return 0, err
 	}
-	if client != nil {
-		p.wg.Add(1)
-		go func() {
-			defer p.wg.Done()
-			if err := p.processRequests(client); err != nil {
-				log.Println("error processing request:", err)
-			}
-		}()
+	if client == nil {
+		p.releaseConnSlot()
+		return 0, nil
 	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.releaseConnSlot()
+
+		if p.onConnOpen != nil {
+			p.onConnOpen(client)
+		}
+
+		err := p.processRequests(client)
+
+		if err != nil {
+			p.errorLogger(&TServerError{Op: "process", Client: client, Err: err})
+		}
+
+		if p.onConnClose != nil {
+			p.onConnClose(client, err)
+		}
+	}()
 	return 0, nil
 }
 
 func (p *TSimpleServer) AcceptLoop() error {
 	for {
+		if !p.acquireConnSlot() {
+			return nil
+		}
 		closed, err := p.innerAccept()
 		if closed != 0 {
 			return nil
 		}
 		if err != nil {
-			if p.errorLogger != nil {
-				(*p.errorLogger)(err)
-			} else {
-				log.Println("error accepting request:", err)
-			}
+			p.errorLogger(&TServerError{Op: "accept", Err: err})
 			return err
 		}
 	}
@@ -201,22 +288,120 @@ func (p *TSimpleServer) Serve() error {
 	return nil
 }
 
+// Stop stops the server, it waits for the requests in progress to be
+// processed, see Shutdown.
 func (p *TSimpleServer) Stop() error {
+	return p.Shutdown(context.Background())
+}
+
+// Shutdown stops the server gracefully: it stops accepting connections,
+// closes the idle ones, tells the streams in progress to go away and waits
+// for the requests in progress to be processed. Once ctx is done, the
+// connections left are closed, the context of their requests is canceled and
+// the error of ctx is returned.
+func (p *TSimpleServer) Shutdown(ctx Context) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if atomic.LoadInt32(&p.closed) != 0 {
+	if atomic.LoadInt32(&p.closed) == 0 {
+		atomic.StoreInt32(&p.closed, 1)
+		close(p.closec)
+		p.serverTransport.Interrupt()
+	}
+	p.mu.Unlock()
+
+	p.connMu.Lock()
+	for c := range p.conns {
+		c.closeIfIdle()
+		c.streams.goAway()
+	}
+	p.connMu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
 		return nil
+	case <-ctx.Done():
 	}
-	atomic.StoreInt32(&p.closed, 1)
-	p.serverTransport.Interrupt()
-	p.wg.Wait()
-	return nil
+
+	p.connMu.Lock()
+	for c := range p.conns {
+		c.forceClose()
+	}
+	p.connMu.Unlock()
+
+	return ctx.Err()
+}
+
+func (p *TSimpleServer) trackConn(client TTransport) (*TServerConn, bool) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return nil, false
+	}
+
+	if p.conns == nil {
+		p.conns = make(map[*TServerConn]struct{})
+	}
+
+	c := newTServerConn(client)
+	c.workers = p.workers
+	c.readTimeout = p.readTimeout
+	p.conns[c] = struct{}{}
+
+	return c, true
+}
+
+func (p *TSimpleServer) untrackConn(c *TServerConn) {
+	p.connMu.Lock()
+	delete(p.conns, c)
+	p.connMu.Unlock()
+
+	c.releaseWorker()
+	c.stopIdleTimer()
+	c.cancel()
+}
+
+// waitRequest marks c as idle, waiting for its next request, it fails once the
+// server is stopped.
+func (p *TSimpleServer) waitRequest(c *TServerConn) bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return false
+	}
+
+	c.releaseWorker()
+
+	if !atomic.CompareAndSwapInt32(&c.state, connActive, connIdle) {
+		return false
+	}
+
+	if p.idleTimeout > 0 {
+		c.idleTimer = time.AfterFunc(p.idleTimeout, c.closeIfIdle)
+	}
+
+	return true
 }
 
 func (p *TSimpleServer) processRequests(client TTransport) error {
+	c, ok := p.trackConn(client)
+
+	if !ok {
+		return client.Close()
+	}
+
+	defer p.untrackConn(c)
+
 	processor := p.processorFactory.GetProcessor(client)
-	inputTransport := p.inputTransportFactory.GetTransport(client)
-	outputTransport := p.outputTransportFactory.GetTransport(client)
+	inputTransport := p.inputTransportFactory.GetTransport(c)
+	outputTransport := p.outputTransportFactory.GetTransport(c)
 	inputProtocol := p.inputProtocolFactory.GetProtocol(inputTransport)
 	var outputProtocol TProtocol
 
@@ -230,19 +415,32 @@ func (p *TSimpleServer) processRequests(client TTransport) error {
 		outputProtocol = p.outputProtocolFactory.GetProtocol(outputTransport)
 	}
 
-
 	if inputTransport != nil {
 		defer inputTransport.Close()
 	}
 	if outputTransport != nil {
 		defer outputTransport.Close()
 	}
+	var tlsState *tls.ConnectionState
+
+	if sslSocket, ok := client.(*TSSLSocket); ok {
+		cs, err := sslSocket.ConnectionState()
+
+		if err != nil {
+			return err
+		}
+
+		tlsState = &cs
+	}
+
+	peer := newTPeer(client, c.id, tlsState)
+
 	for {
-		if atomic.LoadInt32(&p.closed) != 0 {
+		if !p.waitRequest(c) {
 			return nil
 		}
 
-		ctx := defaultCtx
+		ctx := withStreamSet(c.ctx, &c.streams)
 		if headerProtocol != nil {
 			// We need to call ReadFrame here, otherwise we won't
 			// get any headers on the AddReadTHeaderToContext call.
@@ -251,25 +449,42 @@ func (p *TSimpleServer) processRequests(client TTransport) error {
 			// won't break when it's called again later when we
 			// actually start to read the message.
 			if err := headerProtocol.ReadFrame(); err != nil {
+				if c.closed() {
+					return nil
+				}
 				return err
 			}
-			ctx = AddReadTHeaderToContext(defaultCtx, headerProtocol.GetReadHeaders())
+			// The frame may have been read along with the previous one.
+			if headerProtocol.transport.hasPendingRead() && !c.activate() {
+				return nil
+			}
+			ctx = AddReadTHeaderToContext(ctx, headerProtocol.GetReadHeaders())
 			ctx = SetWriteHeaderList(ctx, p.forwardHeaders)
 		}
 
+		if tlsState != nil {
+			ctx = SetTLSConnectionState(ctx, tlsState)
+		}
+
+		if headerProtocol != nil {
+			peer.ClientType = headerProtocol.transport.ClientType()
+		}
+		ctx = WithPeer(ctx, peer)
+
+		ctx, cancel := withTHeaderDeadline(ctx)
 		ok, err := processor.Process(ctx, inputProtocol, outputProtocol)
+		cancel()
+
 		if terr, ok2 := err.(TTransportException); ok2 && terr.TypeId() == END_OF_FILE {
 			return nil
 		}
+		if err != nil && !ok && c.closed() {
+			return nil
+		}
 		if err != nil && !ok {
 			// Only close the connection on errors where the processor could not
 			// produce a response (ok=false). When ok=true the processor already
 			// wrote an EXCEPTION frame to the client; keep the connection open.
-			if p.errorLogger != nil {
-				(*p.errorLogger)(err)
-			} else {
-				log.Println("error processing request:", err)
-			}
 			return err
 		}
 		if err, ok := err.(TApplicationException); ok && err.TypeId() == UNKNOWN_METHOD {
@@ -20,9 +20,15 @@
 package thrift
 
 import (
-	"testing"
+	"context"
 	"errors"
+	"io"
 	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockServerTransport struct {
@@ -154,3 +160,426 @@ func TestNoHangDuringStopFromDanglingLockAcquireDuringAcceptLoop(t *testing.T) {
 	runtime.Gosched()
 	serv.Stop()
 }
+
+// blockingHandler echoes its request once released.
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{started: make(chan struct{}, 8), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) Handle(ctx Context, req TRequest) (TResponse, error) {
+	h.started <- struct{}{}
+
+	select {
+	case <-h.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return req.(TResponse), nil
+}
+
+// tickerHandler streams messages until the stream is closed.
+type tickerHandler struct {
+	done chan error
+}
+
+func (h *tickerHandler) Handle(_ Context, _ TRequest, s TOutboundStream) (TResponse, error) {
+	go func() {
+		for {
+			if err := s.Send(context.Background(), newTString("tick")); err != nil {
+				h.done <- err
+				return
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	return newTString("resp"), nil
+}
+
+func newTestServer(t *testing.T, h TBinaryHandler, sh TStreamServerHandler, opts ...TServerOption) *TSimpleServer {
+	t.Helper()
+
+	p := NewTStandardProcessor(nil)
+	builder := func() TRequest { return newTString("") }
+
+	p.AddProcessor("echo", NewTBinaryProcessorFunction(p, "echo", builder, h))
+	p.AddProcessor("ticker", NewTStreamServerProcessorFunction(p, "ticker", builder, sh))
+
+	trans, err := NewTServerSocket("127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, trans.Listen())
+
+	s := NewTServer(p, trans, opts...)
+	go s.Serve()
+
+	return s
+}
+
+func dialBinaryTestServer(t *testing.T, s *TSimpleServer) *TSyncClient {
+	t.Helper()
+
+	return dialTestServer(t, s, NewTBinaryProtocolFactoryDefault())
+}
+
+func dialTestServer(t *testing.T, s *TSimpleServer, pf TProtocolFactory) *TSyncClient {
+	t.Helper()
+
+	return NewTSyncClient(openTestSocket(t, s), pf)
+}
+
+func openTestSocket(t *testing.T, s *TSimpleServer) *TSocket {
+	t.Helper()
+
+	sock, err := NewTSocket(s.ServerTransport().(*TServerSocket).Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, sock.Open())
+
+	t.Cleanup(func() { sock.Close() })
+
+	return sock
+}
+
+func TestTSimpleServer_Shutdown_Idle(t *testing.T) {
+	h := newBlockingHandler()
+	close(h.release)
+
+	s := newTestServer(t, h, &tickerHandler{})
+	cl := dialBinaryTestServer(t, s)
+
+	var res tstring
+
+	require.NoError(t, cl.CallBinary(context.Background(), "echo", newTString("foo"), &res))
+	assert.Equal(t, "foo", string(res))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, s.Shutdown(ctx))
+	assert.Error(t, cl.CallBinary(context.Background(), "echo", newTString("foo"), &res))
+}
+
+func TestTSimpleServer_Shutdown_InFlight(t *testing.T) {
+	var (
+		h  = newBlockingHandler()
+		s  = newTestServer(t, h, &tickerHandler{})
+		cl = dialBinaryTestServer(t, s)
+
+		res   tstring
+		callc = make(chan error, 1)
+		stopc = make(chan error, 1)
+	)
+
+	go func() {
+		callc <- cl.CallBinary(context.Background(), "echo", newTString("foo"), &res)
+	}()
+
+	<-h.started
+
+	go func() { stopc <- s.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-stopc:
+		t.Fatalf("Shutdown returned before the request was processed: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(h.release)
+
+	require.NoError(t, <-callc)
+	assert.Equal(t, "foo", string(res))
+	assert.NoError(t, <-stopc)
+}
+
+func TestTSimpleServer_Shutdown_Deadline(t *testing.T) {
+	var (
+		h  = newBlockingHandler()
+		s  = newTestServer(t, h, &tickerHandler{})
+		cl = dialBinaryTestServer(t, s)
+
+		callc = make(chan error, 1)
+	)
+
+	go func() {
+		var res tstring
+
+		callc <- cl.CallBinary(context.Background(), "echo", newTString("foo"), &res)
+	}()
+
+	<-h.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, s.Shutdown(ctx))
+	assert.Error(t, <-callc)
+}
+
+func TestTSimpleServer_Shutdown_Stream(t *testing.T) {
+	var (
+		sh = tickerHandler{done: make(chan error, 1)}
+		s  = newTestServer(t, newBlockingHandler(), &sh)
+		cl = dialBinaryTestServer(t, s)
+
+		ctx = context.Background()
+		res tstring
+	)
+
+	is, err := cl.StreamServer(ctx, "ticker", newTString("foo"), &res)
+	require.NoError(t, err)
+
+	var v tstring
+
+	require.NoError(t, is.Receive(ctx, &v))
+	assert.Equal(t, "tick", string(v))
+
+	stopc := make(chan error, 1)
+
+	go func() { stopc <- s.Shutdown(ctx) }()
+
+	for {
+		if err := is.Receive(ctx, &v); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+
+	assert.NoError(t, is.Close())
+	assert.Equal(t, io.EOF, <-sh.done)
+	assert.NoError(t, <-stopc)
+}
+
+// connHooks records the connections opened and closed.
+type connHooks struct {
+	opened chan TTransport
+	closed chan error
+}
+
+func newConnHooks() *connHooks {
+	return &connHooks{opened: make(chan TTransport, 8), closed: make(chan error, 8)}
+}
+
+func (h *connHooks) options() []TServerOption {
+	return []TServerOption{
+		WithConnOpenHook(func(c TTransport) { h.opened <- c }),
+		WithConnCloseHook(func(_ TTransport, err error) { h.closed <- err }),
+	}
+}
+
+func TestNewTServer_MaxConnections(t *testing.T) {
+	h := newBlockingHandler()
+	close(h.release)
+
+	var (
+		hooks = newConnHooks()
+		s     = newTestServer(t, h, &tickerHandler{}, append(hooks.options(), WithMaxConnections(1))...)
+		sock  = openTestSocket(t, s)
+		cl    = NewTSyncClient(sock, NewTBinaryProtocolFactoryDefault())
+
+		ctx = context.Background()
+		res tstring
+	)
+
+	defer s.Stop()
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString("foo"), &res))
+	<-hooks.opened
+
+	var (
+		sock2 = openTestSocket(t, s)
+		callc = make(chan error, 1)
+	)
+
+	go func() {
+		var res tstring
+
+		callc <- NewTSyncClient(sock2, NewTBinaryProtocolFactoryDefault()).CallBinary(ctx, "echo", newTString("bar"), &res)
+	}()
+
+	select {
+	case err := <-callc:
+		t.Fatalf("the second connection was served beyond the limit: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sock.Close()
+
+	assert.NoError(t, <-hooks.closed)
+	assert.NoError(t, <-callc)
+}
+
+func TestNewTServer_MaxWorkers(t *testing.T) {
+	var (
+		h  = newBlockingHandler()
+		s  = newTestServer(t, h, &tickerHandler{}, WithMaxWorkers(1))
+		cl = dialBinaryTestServer(t, s)
+
+		ctx   = context.Background()
+		callc = make(chan error, 2)
+	)
+
+	defer s.Stop()
+
+	for _, cl := range []*TSyncClient{cl, dialTestServer(t, s, NewTBinaryProtocolFactoryDefault())} {
+		go func(cl *TSyncClient) {
+			var res tstring
+
+			callc <- cl.CallBinary(ctx, "echo", newTString("foo"), &res)
+		}(cl)
+	}
+
+	<-h.started
+
+	select {
+	case <-h.started:
+		t.Fatal("two requests processed beyond the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(h.release)
+
+	assert.NoError(t, <-callc)
+	assert.NoError(t, <-callc)
+}
+
+func TestNewTServer_IdleTimeout(t *testing.T) {
+	h := newBlockingHandler()
+	close(h.release)
+
+	var (
+		hooks = newConnHooks()
+		s     = newTestServer(t, h, &tickerHandler{}, append(hooks.options(), WithIdleTimeout(20*time.Millisecond))...)
+		cl    = dialBinaryTestServer(t, s)
+
+		res tstring
+	)
+
+	defer s.Stop()
+
+	require.NoError(t, cl.CallBinary(context.Background(), "echo", newTString("foo"), &res))
+
+	select {
+	case err := <-hooks.closed:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle connection was not closed")
+	}
+
+	assert.Error(t, cl.CallBinary(context.Background(), "echo", newTString("foo"), &res))
+}
+
+func TestNewTServer_ReadTimeout(t *testing.T) {
+	var (
+		hooks = newConnHooks()
+		s     = newTestServer(t, newBlockingHandler(), &tickerHandler{}, append(hooks.options(), WithReadTimeout(20*time.Millisecond))...)
+
+		sock = openTestSocket(t, s)
+		prot = NewTBinaryProtocolTransport(sock)
+	)
+
+	defer s.Stop()
+
+	<-hooks.opened
+
+	// The request stalls after its message header.
+	require.NoError(t, prot.WriteMessageBegin("echo", CALL, 1))
+	require.NoError(t, prot.Flush())
+
+	select {
+	case <-hooks.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stalled connection was not closed")
+	}
+}
+
+// forwardHandler answers the value of the header foo and whether it is
+// forwarded.
+type forwardHandler struct{}
+
+func (forwardHandler) Handle(ctx Context, _ TRequest) (TResponse, error) {
+	v, _ := GetHeader(ctx, "foo")
+
+	for _, k := range GetWriteHeaderList(ctx) {
+		if k == "foo" {
+			v += " forwarded"
+		}
+	}
+
+	return newTString(v), nil
+}
+
+func TestNewTServer_HeaderProtocol(t *testing.T) {
+	var (
+		pf = NewTHeaderProtocolFactory()
+		s  = newTestServer(t, forwardHandler{}, &tickerHandler{}, WithProtocolFactory(pf), WithForwardHeaders("foo"))
+		cl = dialTestServer(t, s, pf)
+
+		res tstring
+		ctx = SetWriteHeaderList(SetHeader(context.Background(), "foo", "bar"), []string{"foo"})
+	)
+
+	defer s.Stop()
+
+	require.NoError(t, cl.CallBinary(ctx, "echo", newTString(""), &res))
+	assert.Equal(t, "bar forwarded", string(res))
+}
+
+type recordingTransportFactory struct {
+	transc chan TTransport
+}
+
+func (f *recordingTransportFactory) GetTransport(trans TTransport) TTransport {
+	f.transc <- trans
+	return trans
+}
+
+func TestNewTServer_TransportFactory(t *testing.T) {
+	var (
+		tf = &recordingTransportFactory{transc: make(chan TTransport, 2)}
+		s  = newTestServer(t, forwardHandler{}, &tickerHandler{}, WithTransportFactory(tf))
+		cl = dialBinaryTestServer(t, s)
+
+		res tstring
+	)
+
+	defer s.Stop()
+
+	require.NoError(t, cl.CallBinary(context.Background(), "echo", newTString("x"), &res))
+
+	for i := 0; i < 2; i++ {
+		c, ok := (<-tf.transc).(*TServerConn)
+		require.True(t, ok)
+		assert.IsType(t, &TSocket{}, c.Underlying())
+	}
+}
+
+func TestNewTServer_ErrorLogger(t *testing.T) {
+	var (
+		errs []*TServerError
+
+		trans = &mockServerTransport{
+			ListenFunc:    func() error { return nil },
+			AcceptFunc:    func() (TTransport, error) { return nil, errors.New("no sir") },
+			CloseFunc:     func() error { return nil },
+			InterruptFunc: func() error { return nil },
+		}
+
+		s = NewTServer(
+			&mockProcessor{},
+			trans,
+			WithErrorLogger(func(e *TServerError) { errs = append(errs, e) }),
+		)
+	)
+
+	assert.Error(t, s.AcceptLoop())
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "accept", errs[0].Op)
+	assert.Equal(t, "thrift: accept: no sir", errs[0].Error())
+}
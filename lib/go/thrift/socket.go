@@ -60,6 +60,25 @@ func NewTSocketFromConnTimeout(conn net.Conn, timeout time.Duration) *TSocket {
 	return &TSocket{conn: conn, addr: conn.RemoteAddr(), timeout: timeout}
 }
 
+type tSocketTransportFactory struct {
+	addr    net.Addr
+	timeout time.Duration
+}
+
+// NewTSocketTransportFactory creates a TTransportFactory returning a new,
+// not yet opened, TSocket to hostPort for every transport requested.
+func NewTSocketTransportFactory(hostPort string, timeout time.Duration) (TTransportFactory, error) {
+	addr, err := net.ResolveTCPAddr("tcp", hostPort)
+	if err != nil {
+		return nil, err
+	}
+	return &tSocketTransportFactory{addr: addr, timeout: timeout}, nil
+}
+
+func (f *tSocketTransportFactory) GetTransport(_ TTransport) TTransport {
+	return NewTSocketFromAddrTimeout(f.addr, f.timeout)
+}
+
 func (p *TSocket) WriteContext(_ Context) error { return nil }
 
 // Sets the socket timeout
@@ -121,6 +121,24 @@ func (p *TSSLSocket) Conn() net.Conn {
 	return p.conn
 }
 
+// ConnectionState completes the TLS handshake if needed and returns the state
+// of the connection.
+func (p *TSSLSocket) ConnectionState() (tls.ConnectionState, error) {
+	tc, ok := p.conn.(*tls.Conn)
+
+	if !ok {
+		return tls.ConnectionState{}, NewTTransportException(NOT_OPEN, "Connection not open")
+	}
+
+	p.pushDeadline(true, true)
+
+	if err := tc.Handshake(); err != nil {
+		return tls.ConnectionState{}, NewTTransportExceptionFromError(err)
+	}
+
+	return tc.ConnectionState(), nil
+}
+
 // Returns true if the connection is open
 func (p *TSSLSocket) IsOpen() bool {
 	if p.conn == nil {
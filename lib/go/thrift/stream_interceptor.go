@@ -0,0 +1,203 @@
+package thrift
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+type TStreamDirection int
+
+const (
+	TStreamSend TStreamDirection = iota
+	TStreamReceive
+)
+
+func (d TStreamDirection) String() string {
+	if d == TStreamSend {
+		return "send"
+	}
+
+	return "receive"
+}
+
+// TStreamMessage describes a message going through a stream.
+type TStreamMessage struct {
+	Method    string
+	SeqID     int32
+	Direction TStreamDirection
+
+	// Index of the message among the ones going in the same direction
+	// through the stream, starting at 1.
+	Index int64
+
+	Message TRequest
+}
+
+// Size returns the size of the message encoded with the binary protocol, it
+// is an estimate of the size on the wire whatever the protocol in use.
+func (m TStreamMessage) Size() int {
+	if m.Message == nil {
+		return 0
+	}
+
+	buf, err := NewTSerializer().Write(defaultCtx, m.Message)
+
+	if err != nil {
+		return 0
+	}
+
+	return len(buf)
+}
+
+// TStreamInterceptor hooks every message sent or received through a stream.
+// For a received message, next decodes it in msg.Message.
+type TStreamInterceptor interface {
+	InterceptSend(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error
+	InterceptReceive(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error
+}
+
+type TMultiStreamInterceptor []TStreamInterceptor
+
+func (is TMultiStreamInterceptor) InterceptSend(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	for i := len(is); i > 0; i-- {
+		call := next
+		i := i
+		next = func(ctx Context, req TRequest) error {
+			msg := msg
+			msg.Message = req
+
+			return is[i-1].InterceptSend(ctx, msg, call)
+		}
+	}
+
+	return next(ctx, msg.Message)
+}
+
+func (is TMultiStreamInterceptor) InterceptReceive(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	for i := len(is); i > 0; i-- {
+		call := next
+		i := i
+		next = func(ctx Context, req TRequest) error {
+			msg := msg
+			msg.Message = req
+
+			return is[i-1].InterceptReceive(ctx, msg, call)
+		}
+	}
+
+	return next(ctx, msg.Message)
+}
+
+// TStreamHooks is a TStreamInterceptor calling its functions, when set, once
+// a message went through the stream. OnError is called instead of OnSend or
+// OnReceive when the message failed, io.EOF marking the end of an inbound
+// stream is not a failure.
+type TStreamHooks struct {
+	OnSend    func(Context, TStreamMessage)
+	OnReceive func(Context, TStreamMessage)
+	OnError   func(Context, TStreamMessage, error)
+}
+
+func (h TStreamHooks) report(ctx Context, msg TStreamMessage, fn func(Context, TStreamMessage), err error) {
+	switch {
+	case err == nil:
+		if fn != nil {
+			fn(ctx, msg)
+		}
+	case err != io.EOF && h.OnError != nil:
+		h.OnError(ctx, msg, err)
+	}
+}
+
+func (h TStreamHooks) InterceptSend(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	err := next(ctx, msg.Message)
+	h.report(ctx, msg, h.OnSend, err)
+
+	return err
+}
+
+func (h TStreamHooks) InterceptReceive(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	err := next(ctx, msg.Message)
+	h.report(ctx, msg, h.OnReceive, err)
+
+	return err
+}
+
+type tInterceptedInboundStream struct {
+	TInboundStream
+
+	method      string
+	seqID       int32
+	interceptor TStreamInterceptor
+	count       int64
+}
+
+func (s *tInterceptedInboundStream) Receive(ctx Context, req TRequest) error {
+	return s.interceptor.InterceptReceive(
+		ctx,
+		TStreamMessage{
+			Method:    s.method,
+			SeqID:     s.seqID,
+			Direction: TStreamReceive,
+			Index:     atomic.AddInt64(&s.count, 1),
+			Message:   req,
+		},
+		s.TInboundStream.Receive,
+	)
+}
+
+type tInterceptedOutboundStream struct {
+	TOutboundStream
+
+	method      string
+	seqID       int32
+	interceptor TStreamInterceptor
+	count       int64
+}
+
+func (s *tInterceptedOutboundStream) Send(ctx Context, req TRequest) error {
+	return s.interceptor.InterceptSend(
+		ctx,
+		TStreamMessage{
+			Method:    s.method,
+			SeqID:     s.seqID,
+			Direction: TStreamSend,
+			Index:     atomic.AddInt64(&s.count, 1),
+			Message:   req,
+		},
+		s.TOutboundStream.Send,
+	)
+}
+
+type tStreamInterceptorMiddleware struct {
+	TNopMiddleware
+
+	interceptor TStreamInterceptor
+}
+
+// NewTStreamInterceptorMiddleware returns a TStreamingMiddleware running the
+// messages of every stream it sees through the given interceptors, the first
+// one being the outermost. It works on both the client and the server side.
+func NewTStreamInterceptorMiddleware(is ...TStreamInterceptor) TStreamingMiddleware {
+	return &tStreamInterceptorMiddleware{interceptor: TMultiStreamInterceptor(is)}
+}
+
+func (m *tStreamInterceptorMiddleware) inbound(mth string, seqID int32, s TInboundStream) TInboundStream {
+	return &tInterceptedInboundStream{TInboundStream: s, method: mth, seqID: seqID, interceptor: m.interceptor}
+}
+
+func (m *tStreamInterceptorMiddleware) outbound(mth string, seqID int32, s TOutboundStream) TOutboundStream {
+	return &tInterceptedOutboundStream{TOutboundStream: s, method: mth, seqID: seqID, interceptor: m.interceptor}
+}
+
+func (m *tStreamInterceptorMiddleware) HandleInboundStream(ctx Context, mth string, seqID int32, req TRequest, s TInboundStream, next func(Context, TRequest, TInboundStream) (TResponse, error)) (TResponse, error) {
+	return next(ctx, req, m.inbound(mth, seqID, s))
+}
+
+func (m *tStreamInterceptorMiddleware) HandleOutboundStream(ctx Context, mth string, seqID int32, req TRequest, s TOutboundStream, next func(Context, TRequest, TOutboundStream) (TResponse, error)) (TResponse, error) {
+	return next(ctx, req, m.outbound(mth, seqID, s))
+}
+
+func (m *tStreamInterceptorMiddleware) HandleBidiStream(ctx Context, mth string, seqID int32, req TRequest, is TInboundStream, os TOutboundStream, next func(Context, TRequest, TInboundStream, TOutboundStream) (TResponse, error)) (TResponse, error) {
+	return next(ctx, req, m.inbound(mth, seqID, is), m.outbound(mth, seqID, os))
+}
@@ -0,0 +1,141 @@
+package thrift
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInboundStream struct {
+	msgs []string
+}
+
+func (s *fakeInboundStream) Close() error { return nil }
+
+func (s *fakeInboundStream) Receive(_ Context, req TRequest) error {
+	if len(s.msgs) == 0 {
+		return io.EOF
+	}
+
+	req.(*tpayload).v = s.msgs[0]
+	s.msgs = s.msgs[1:]
+
+	return nil
+}
+
+type fakeOutboundStream struct {
+	err error
+}
+
+func (s *fakeOutboundStream) Close() error                 { return nil }
+func (s *fakeOutboundStream) Send(Context, TRequest) error { return s.err }
+
+type recordingInterceptor struct {
+	name  string
+	calls *[]string
+}
+
+func (i recordingInterceptor) InterceptSend(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	*i.calls = append(*i.calls, i.name+":send")
+	return next(ctx, msg.Message)
+}
+
+func (i recordingInterceptor) InterceptReceive(ctx Context, msg TStreamMessage, next func(Context, TRequest) error) error {
+	*i.calls = append(*i.calls, i.name+":receive")
+	return next(ctx, msg.Message)
+}
+
+func TestTStreamInterceptorMiddleware_Hooks(t *testing.T) {
+	var (
+		ctx = context.Background()
+
+		received []TStreamMessage
+		sent     []TStreamMessage
+		errs     []error
+
+		serr = errors.New("broken")
+
+		m = NewTStreamInterceptorMiddleware(
+			TStreamHooks{
+				OnReceive: func(_ Context, msg TStreamMessage) { received = append(received, msg) },
+				OnSend:    func(_ Context, msg TStreamMessage) { sent = append(sent, msg) },
+				OnError:   func(_ Context, _ TStreamMessage, err error) { errs = append(errs, err) },
+			},
+		)
+
+		os = &fakeOutboundStream{}
+	)
+
+	_, err := m.HandleBidiStream(
+		ctx,
+		"bidi",
+		1,
+		nil,
+		&fakeInboundStream{msgs: []string{"foo", "bar"}},
+		os,
+		func(ctx Context, _ TRequest, is TInboundStream, os TOutboundStream) (TResponse, error) {
+			var p tpayload
+
+			for is.Receive(ctx, &p) == nil {
+				require.NoError(t, os.Send(ctx, newTPayload(p.v)))
+			}
+
+			return nil, nil
+		},
+	)
+
+	require.NoError(t, err)
+
+	require.Len(t, received, 2)
+	assert.Equal(t, int64(2), received[1].Index)
+	assert.Equal(t, TStreamReceive, received[1].Direction)
+	assert.Equal(t, "bidi", received[1].Method)
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, int64(1), sent[0].Index)
+	assert.Equal(t, "foo", sent[0].Message.(*tpayload).v)
+	assert.Equal(t, 11, sent[0].Size())
+
+	assert.Empty(t, errs)
+
+	os.err = serr
+
+	_, err = m.HandleOutboundStream(
+		ctx,
+		"out",
+		2,
+		nil,
+		os,
+		func(ctx Context, _ TRequest, os TOutboundStream) (TResponse, error) {
+			return nil, os.Send(ctx, newTPayload("x"))
+		},
+	)
+
+	assert.Equal(t, serr, err)
+	assert.Equal(t, []error{serr}, errs)
+}
+
+func TestTMultiStreamInterceptor_Order(t *testing.T) {
+	var calls []string
+
+	is := TMultiStreamInterceptor{
+		recordingInterceptor{name: "outer", calls: &calls},
+		recordingInterceptor{name: "inner", calls: &calls},
+	}
+
+	err := is.InterceptSend(
+		context.Background(),
+		TStreamMessage{},
+		func(Context, TRequest) error {
+			calls = append(calls, "send")
+			return nil
+		},
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer:send", "inner:send", "send"}, calls)
+}
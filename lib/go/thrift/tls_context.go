@@ -0,0 +1,23 @@
+package thrift
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+type tlsConnectionStateKey struct{}
+
+// SetTLSConnectionState sets the state of the TLS connection a call was
+// received on in the context.
+func SetTLSConnectionState(ctx context.Context, cs *tls.ConnectionState) context.Context {
+	return context.WithValue(ctx, tlsConnectionStateKey{}, cs)
+}
+
+// GetTLSConnectionState returns the state of the TLS connection a call was
+// received on from the context. TSimpleServer sets it for the calls received
+// on a TSSLSocket, the HTTP handlers for the ones received over HTTPS, along
+// with the rest of the TPeer.
+func GetTLSConnectionState(ctx context.Context) (*tls.ConnectionState, bool) {
+	cs, ok := ctx.Value(tlsConnectionStateKey{}).(*tls.ConnectionState)
+	return cs, ok && cs != nil
+}
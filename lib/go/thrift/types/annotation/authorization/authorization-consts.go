@@ -0,0 +1,30 @@
+// Autogenerated by Thrift Compiler (2.7.0-upfluence)
+// DO NOT EDIT UNLESS YOU ARE SURE THAT YOU KNOW WHAT YOU ARE DOING
+
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/upfluence/thrift/lib/go/thrift"
+	"io"
+	"reflect"
+)
+
+// (needed to ensure safety because of naive import list construction.)
+var _ = thrift.ZERO
+var _ = fmt.Printf
+var _ = context.Background
+var _ = reflect.DeepEqual
+var _ = bytes.Equal
+var _ = io.EOF
+
+var GoUnusedProtection__ int
+
+const Namespace = "types.annotation.authorization"
+
+func init() {
+	thrift.RegisterStruct((*RequireScopes)(nil))
+	thrift.RegisterStruct((*Public)(nil))
+}
@@ -0,0 +1,41 @@
+package authorization
+
+import "github.com/upfluence/thrift/lib/go/thrift"
+
+// IsPublic reports whether the function, or the service defining it, is
+// annotated with Public.
+func IsPublic(sd thrift.ServiceDefinition, fd thrift.FunctionDefinition) bool {
+	return thrift.HasStructuredAnnotation(fd.AnnotatedDefinition, &Public{}) ||
+		thrift.HasStructuredAnnotation(sd.AnnotatedDefinition, &Public{})
+}
+
+// RequiredScopes returns the scopes required by the RequireScopes annotations
+// of the service and of the function, without duplicates.
+func RequiredScopes(sd thrift.ServiceDefinition, fd thrift.FunctionDefinition) []string {
+	var (
+		scopes []string
+		seen   = make(map[string]struct{})
+	)
+
+	for _, as := range [][]thrift.RegistrableStruct{
+		sd.StructuredAnnotations,
+		fd.StructuredAnnotations,
+	} {
+		for _, a := range as {
+			rs, ok := a.(*RequireScopes)
+
+			if !ok {
+				continue
+			}
+
+			for _, s := range rs.GetScopes() {
+				if _, ok := seen[s]; !ok {
+					seen[s] = struct{}{}
+					scopes = append(scopes, s)
+				}
+			}
+		}
+	}
+
+	return scopes
+}
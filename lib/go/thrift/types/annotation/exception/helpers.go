@@ -0,0 +1,19 @@
+package exception
+
+import (
+	"github.com/upfluence/errors"
+
+	"github.com/upfluence/thrift/lib/go/thrift"
+)
+
+// IsSafe reports whether err is, or wraps, an exception annotated with Safe:
+// the call failed before having any side effect and can be sent again.
+func IsSafe(err error) bool {
+	var rs thrift.RegistrableStruct
+
+	if !errors.As(err, &rs) {
+		return false
+	}
+
+	return thrift.HasStructuredAnnotation(rs.StructDefinition().AnnotatedDefinition, &Safe{})
+}
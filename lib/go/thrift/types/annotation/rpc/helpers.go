@@ -0,0 +1,17 @@
+package rpc
+
+import "github.com/upfluence/thrift/lib/go/thrift"
+
+// IsIdempotent reports whether the function, or the service defining it, is
+// annotated with Idempotent. ReadOnly functions are idempotent as well.
+func IsIdempotent(sd thrift.ServiceDefinition, fd thrift.FunctionDefinition) bool {
+	return thrift.HasStructuredAnnotation(fd.AnnotatedDefinition, &Idempotent{}) ||
+		thrift.HasStructuredAnnotation(sd.AnnotatedDefinition, &Idempotent{})
+}
+
+// IsReadOnly reports whether the function, or the service defining it, is
+// annotated with ReadOnly.
+func IsReadOnly(sd thrift.ServiceDefinition, fd thrift.FunctionDefinition) bool {
+	return thrift.HasStructuredAnnotation(fd.AnnotatedDefinition, &ReadOnly{}) ||
+		thrift.HasStructuredAnnotation(sd.AnnotatedDefinition, &ReadOnly{})
+}
@@ -0,0 +1,23 @@
+namespace * types.annotation.authorization
+
+// RequireScopes restricts an RPC function (or all functions of a service) to
+// the callers granted every listed scope.
+//
+// Placement:
+//   - On a function: only that specific function requires the scopes.
+//   - On a service: every function in the service requires the scopes.
+//
+// When present on both, a caller needs the scopes of the service and the ones
+// of the function.
+struct RequireScopes {
+  1: required list<string> scopes;
+}
+
+// Public exempts an RPC function (or all functions of a service) from
+// authentication: anonymous callers are let through and the scopes required
+// by RequireScopes are not enforced.
+//
+// Placement:
+//   - On a function: only that specific function is public.
+//   - On a service: every function in the service is public.
+struct Public {}